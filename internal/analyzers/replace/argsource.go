@@ -0,0 +1,297 @@
+package replace
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Arguments are copied into replacements from the source of the call rather than printed from their
+// syntax, so that the comments around them and the line breaks inside them survive. The functions in
+// this file work out which comments go with which argument, and how the arguments of a call that
+// spans several lines are laid out.
+
+// comment is a comment in the source of a call.
+type comment struct {
+	pos, end token.Pos
+	text     string
+}
+
+func (c comment) isLine() bool {
+	return strings.HasPrefix(c.text, "//")
+}
+
+// argSource is the source of an argument of the call being replaced, along with its comments: those
+// before it (after the previous argument's line) and those after it on the line it ends on.
+type argSource struct {
+	leading  []comment
+	pos      token.Pos
+	text     string
+	trailing []comment
+}
+
+// inline returns the argument with its comments on the same line, which works wherever the argument
+// ends up.
+func (a argSource) inline() string {
+	parts := make([]string, 0, len(a.leading)+len(a.trailing)+1)
+	for _, c := range a.leading {
+		parts = append(parts, blockComment(c))
+	}
+	parts = append(parts, a.text)
+	for _, c := range a.trailing {
+		parts = append(parts, blockComment(c))
+	}
+	return strings.Join(parts, " ")
+}
+
+// blockComment returns c as a /* */ comment, so that code can follow it on the same line.
+func blockComment(c comment) string {
+	if !c.isLine() {
+		return c.text
+	}
+
+	text := strings.TrimSpace(strings.TrimPrefix(c.text, "//"))
+	return "/* " + strings.ReplaceAll(text, "*/", "* /") + " */"
+}
+
+// hasSource reports whether the source of the call is available. It isn't when a call site is made
+// up without a file behind it, in which case arguments are printed from their syntax.
+func (site callSite) hasSource() bool {
+	return site.src != nil && site.call != nil && site.call.Lparen.IsValid() && site.call.Rparen.IsValid()
+}
+
+// argSource returns the source of the ith argument of the call.
+func (site callSite) argSource(i int) argSource {
+	args := site.call.Args
+	tf := site.fset.File(site.call.Pos())
+
+	if name, ok := site.hoisted[i]; ok {
+		// The argument's comments went with it.
+		return argSource{pos: args[i].Pos(), text: name}
+	}
+
+	a := argSource{
+		pos:  args[i].Pos(),
+		text: string(site.src[tf.Offset(args[i].Pos()):tf.Offset(args[i].End())]),
+	}
+
+	// Comments between the previous argument and this one belong to the previous argument if
+	// they're on the line it ends on and this argument starts on a later line.
+	if i == 0 {
+		for _, c := range site.commentsBetween(site.call.Lparen+1, args[0].Pos()) {
+			if !site.multiline() || tf.Line(c.pos) != tf.Line(site.call.Lparen) {
+				a.leading = append(a.leading, c)
+			}
+		}
+	} else {
+		for _, c := range site.commentsBetween(args[i-1].End(), args[i].Pos()) {
+			if !site.trailsArg(i-1, c) {
+				a.leading = append(a.leading, c)
+			}
+		}
+	}
+
+	if i == len(args)-1 {
+		a.trailing = site.commentsBetween(args[i].End(), site.call.Rparen)
+	} else {
+		for _, c := range site.commentsBetween(args[i].End(), args[i+1].Pos()) {
+			if site.trailsArg(i, c) {
+				a.trailing = append(a.trailing, c)
+			}
+		}
+	}
+
+	return a
+}
+
+// trailsArg reports whether c, a comment between the ith argument and the next one, belongs to the
+// ith argument.
+func (site callSite) trailsArg(i int, c comment) bool {
+	tf := site.fset.File(site.call.Pos())
+	args := site.call.Args
+	return tf.Line(c.pos) == tf.Line(args[i].End()) && tf.Line(args[i+1].Pos()) > tf.Line(c.end)
+}
+
+// openComments returns the comments after the call's opening parenthesis on the same line, when the
+// arguments are laid out on lines of their own.
+func (site callSite) openComments() []comment {
+	tf := site.fset.File(site.call.Pos())
+
+	var open []comment
+	for _, c := range site.commentsBetween(site.call.Lparen+1, site.call.Args[0].Pos()) {
+		if tf.Line(c.pos) == tf.Line(site.call.Lparen) {
+			open = append(open, c)
+		}
+	}
+	return open
+}
+
+func (site callSite) commentsBetween(start, end token.Pos) []comment {
+	tf := site.fset.File(start)
+	src := site.src[tf.Offset(start):tf.Offset(end)]
+
+	fset := token.NewFileSet()
+	f := fset.AddFile("", -1, len(src))
+
+	var s scanner.Scanner
+	s.Init(f, src, nil, scanner.ScanComments)
+
+	var comments []comment
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return comments
+		}
+		if tok == token.COMMENT {
+			p := start + token.Pos(f.Offset(pos))
+			comments = append(comments, comment{pos: p, end: p + token.Pos(len(lit)), text: lit})
+		}
+	}
+}
+
+// multiline reports whether the arguments of the call start on a line after its opening parenthesis,
+// in which case replacements put each argument they pass along on a line of its own too.
+func (site callSite) multiline() bool {
+	if !site.hasSource() || len(site.call.Args) == 0 {
+		return false
+	}
+
+	tf := site.fset.File(site.call.Pos())
+	return tf.Line(site.call.Lparen) < tf.Line(site.call.Args[0].Pos())
+}
+
+// indentAt returns the whitespace the line containing pos starts with.
+func (site callSite) indentAt(pos token.Pos) string {
+	tf := site.fset.File(pos)
+	line := string(site.src[tf.Offset(tf.LineStart(tf.Line(pos))):tf.Offset(pos)])
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// argWriter writes a replacement for a call whose arguments are on lines of their own, putting the
+// arguments it passes along on lines of their own in the same way.
+type argWriter struct {
+	site   callSite
+	sb     strings.Builder
+	indent string
+
+	// wroteOpen is set once the comments after the call's opening parenthesis have been written.
+	wroteOpen bool
+
+	// afterArg is set when the last thing written was an argument, and pending holds its trailing
+	// comments until it's known whether they can go at the end of a line.
+	afterArg bool
+	pending  []comment
+}
+
+func newArgWriter(site callSite) *argWriter {
+	return &argWriter{
+		site:   site,
+		indent: site.indentAt(site.call.Args[0].Pos()),
+	}
+}
+
+// writeArg writes the ith argument of the call. If it starts an argument list in the replacement, or
+// follows a comma, it goes on a line of its own.
+func (w *argWriter) writeArg(i int) {
+	a := w.site.argSource(i)
+
+	written := strings.TrimRight(w.sb.String(), " ")
+	if !opensArgs(written) && !strings.HasSuffix(written, ",") {
+		w.flushInline()
+		w.sb.WriteString(a.inline())
+		w.afterArg = false
+		return
+	}
+
+	w.sb.Reset()
+	w.sb.WriteString(written)
+	if opensArgs(written) && !w.wroteOpen {
+		w.wroteOpen = true
+		w.pending = append(w.pending, w.site.openComments()...)
+	}
+	w.flushLine()
+	w.sb.WriteString("\n" + w.indent)
+
+	tf := w.site.fset.File(w.site.call.Pos())
+	for _, c := range a.leading {
+		w.sb.WriteString(c.text)
+		if tf.Line(c.end) == tf.Line(a.pos) {
+			w.sb.WriteString(" ")
+		} else {
+			w.sb.WriteString("\n" + w.indent)
+		}
+	}
+	w.sb.WriteString(a.text)
+
+	w.afterArg = true
+	w.pending = a.trailing
+}
+
+// opensArgs reports whether s ends with the opening parenthesis of a call's arguments, rather than
+// of a parenthesized expression.
+func opensArgs(s string) bool {
+	before, ok := strings.CutSuffix(s, "(")
+	if !ok || before == "" {
+		return false
+	}
+
+	r, _ := utf8.DecodeLastRuneInString(before)
+	return r == '_' || r == ')' || r == ']' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// writeString writes the rest of the replacement. If it ends the argument list the last argument was
+// in, the closing parenthesis goes on a line of its own.
+func (w *argWriter) writeString(s string) {
+	if w.afterArg {
+		w.afterArg = false
+		switch {
+		case strings.HasPrefix(s, ","):
+			w.sb.WriteString(",")
+			s = s[1:]
+			if strings.TrimSpace(s) == "" {
+				// Most likely another argument follows, which will put the comments at the end of
+				// this line.
+				w.sb.WriteString(s)
+				return
+			}
+		case strings.HasPrefix(s, ")"):
+			w.sb.WriteString(",")
+			w.flushLine()
+			w.sb.WriteString("\n" + w.site.indentAt(w.site.call.Rparen))
+		}
+	}
+
+	w.flushInline()
+	w.sb.WriteString(s)
+}
+
+// flushLine writes the pending comments where the line is about to end.
+func (w *argWriter) flushLine() {
+	for i, c := range w.pending {
+		if i > 0 {
+			w.sb.WriteString("\n" + w.indent)
+		} else {
+			w.sb.WriteString(" ")
+		}
+		w.sb.WriteString(c.text)
+	}
+	w.pending = nil
+}
+
+// flushInline writes the pending comments where code is about to follow on the same line.
+func (w *argWriter) flushInline() {
+	for _, c := range w.pending {
+		if !strings.HasSuffix(w.sb.String(), " ") {
+			w.sb.WriteString(" ")
+		}
+		w.sb.WriteString(blockComment(c) + " ")
+	}
+	w.pending = nil
+}
+
+func (w *argWriter) String() string {
+	w.flushInline()
+	return strings.TrimRight(w.sb.String(), " ")
+}
@@ -0,0 +1,128 @@
+package replace
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// ForEachAssignment calls yield for each place in files where the value of an expression is
+// implicitly assigned to a location of a known type: assignments, variable declarations, call
+// arguments, return statements and struct literals. It stops when yield returns false.
+func ForEachAssignment(info *types.Info, files []*ast.File, yield func(file *ast.File, target types.Type, e ast.Expr) bool) {
+	forEachAssignment(info, inspector.New(files), yield)
+}
+
+func forEachAssignment(info *types.Info, inspector *inspector.Inspector, yield func(file *ast.File, target types.Type, e ast.Expr) bool) {
+	stopped := false
+	assign := func(file *ast.File, target types.Type, e ast.Expr) {
+		if !stopped && target != nil {
+			stopped = !yield(file, target, e)
+		}
+	}
+
+	inspector.WithStack(
+		[]ast.Node{&ast.AssignStmt{}, &ast.ValueSpec{}, &ast.CallExpr{}, &ast.ReturnStmt{}, &ast.CompositeLit{}},
+		func(n ast.Node, push bool, stack []ast.Node) bool {
+			if !push || stopped {
+				return false
+			}
+
+			file := stack[0].(*ast.File)
+
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if n.Tok == token.DEFINE || len(n.Lhs) != len(n.Rhs) {
+					return true
+				}
+				for i, rhs := range n.Rhs {
+					assign(file, info.TypeOf(n.Lhs[i]), rhs)
+				}
+			case *ast.ValueSpec:
+				if n.Type == nil || len(n.Names) != len(n.Values) {
+					return true
+				}
+				for _, v := range n.Values {
+					assign(file, info.TypeOf(n.Type), v)
+				}
+			case *ast.CallExpr:
+				if info.Types[n.Fun].IsType() {
+					// Conversions are explicit.
+					return true
+				}
+
+				fun := info.TypeOf(n.Fun)
+				if fun == nil {
+					return true
+				}
+
+				sig, ok := fun.Underlying().(*types.Signature)
+				if !ok {
+					return true
+				}
+
+				for i, arg := range n.Args {
+					assign(file, paramType(sig, i, n.Ellipsis.IsValid()), arg)
+				}
+			case *ast.ReturnStmt:
+				results := enclosingResults(info, stack)
+				if results == nil || results.Len() != len(n.Results) {
+					return true
+				}
+				for i, res := range n.Results {
+					assign(file, results.At(i).Type(), res)
+				}
+			case *ast.CompositeLit:
+				typ := info.TypeOf(n)
+				if typ == nil {
+					return true
+				}
+
+				st, ok := analyzeutil.Deref(typ).Underlying().(*types.Struct)
+				if !ok {
+					return true
+				}
+				for i, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if key, ok := kv.Key.(*ast.Ident); ok {
+							if field, ok := info.ObjectOf(key).(*types.Var); ok {
+								assign(file, field.Type(), kv.Value)
+							}
+						}
+					} else if i < st.NumFields() {
+						assign(file, st.Field(i).Type(), elt)
+					}
+				}
+			}
+
+			return true
+		},
+	)
+}
+
+// paramType returns the type of the parameter that the ith argument of a call to sig is passed to.
+func paramType(sig *types.Signature, i int, hasEllipsis bool) types.Type {
+	params := sig.Params()
+	if params.Len() == 0 {
+		return nil
+	}
+
+	if sig.Variadic() && i >= params.Len()-1 {
+		last := params.At(params.Len() - 1).Type()
+		if hasEllipsis {
+			return last
+		}
+		if s, ok := last.(*types.Slice); ok {
+			return s.Elem()
+		}
+		return nil
+	}
+
+	if i >= params.Len() {
+		return nil
+	}
+	return params.At(i).Type()
+}
@@ -0,0 +1,104 @@
+package replace
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+)
+
+// NewChecker returns an analyzer that runs the rules of a recipe without fixing anything, so that
+// finished migrations can be enforced as lint rules. Every reference a rule would change is reported,
+// prefixed with the name of the rule, along with the fix the rule suggests for it.
+func NewChecker() *analysis.Analyzer {
+	var flags struct {
+		rules string
+	}
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flagSet.StringVar(&flags.rules, "rules", "", "A YAML recipe of replacecall and replacetype rules to check for.")
+
+	// The recipe is the same for every package, so only load it once.
+	loadRecipe := sync.OnceValues(func() (Recipe, error) {
+		return LoadRecipe(flags.rules)
+	})
+
+	return &analysis.Analyzer{
+		Name:  "check",
+		Doc:   "Report code that the rules of a recipe would still change.",
+		Flags: *flagSet,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			if flags.rules == "" {
+				return nil, errors.New("rules must be provided")
+			}
+
+			recipe, err := loadRecipe()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, r := range recipe.Rules {
+				err := r.check(pass)
+				if err != nil {
+					return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+				}
+			}
+
+			return nil, nil
+		},
+		Requires: []*analysis.Analyzer{
+			inspect.Analyzer,
+		},
+	}
+}
+
+// check runs the analyzer of the rule over the package of pass and reports what it finds as the
+// rule's diagnostics.
+func (r Rule) check(pass *analysis.Pass) error {
+	var diags, imports []analysis.Diagnostic
+	rulePass := *pass
+	rulePass.Analyzer = r.analyzer
+	rulePass.Report = func(d analysis.Diagnostic) {
+		if d.Message == analyzeutil.ImportsMessage {
+			imports = append(imports, d)
+			return
+		}
+		diags = append(diags, d)
+	}
+
+	_, err := r.analyzer.Run(&rulePass)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range diags {
+		// The imports aren't something to report on their own, but each fix needs the imports it uses
+		// so that it can be applied on its own, like from an editor.
+		tf := pass.Fset.File(d.Pos)
+		for i, fix := range d.SuggestedFixes {
+			for _, imp := range imports {
+				if pass.Fset.File(imp.Pos) != tf {
+					continue
+				}
+				for _, f := range imp.SuggestedFixes {
+					fix.TextEdits = append(fix.TextEdits, f.TextEdits...)
+				}
+			}
+			d.SuggestedFixes[i] = fix
+		}
+
+		d.Category = r.Name
+		if r.Message != "" {
+			d.Message = fmt.Sprintf("%s: %s (%s)", r.Name, r.Message, d.Message)
+		} else {
+			d.Message = r.Name + ": " + d.Message
+		}
+		pass.Report(d)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package replace
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/analysis"
+)
+
+// docLinkRE matches the text between the brackets of something that looks like a doc link, like
+// [pkg.Name] or [*Name.Method]. Whether it really is one is worked out by parseDocLink.
+var docLinkRE = regexp.MustCompile(`\[(\*?[^\[\]\s]+)\]`)
+
+// docLink is a doc link in a comment, resolved to the symbol it points to.
+type docLink struct {
+	pos, end token.Pos
+	star     bool
+
+	// path is the import path of the package the link points into.
+	path string
+
+	// name is the package-level symbol the link points to, and method is set if it points to a
+	// method or field of it.
+	name, method string
+}
+
+// linkTarget is a symbol to point doc links at.
+type linkTarget struct {
+	path string
+
+	// pkgName is the name of the package at path, in case it can't be worked out from the imports.
+	pkgName string
+
+	name, method string
+}
+
+// commentUpdater rewrites the doc links to a replaced symbol in comments and reports the other
+// mentions of it for a human to look at.
+type commentUpdater struct {
+	pass     *analysis.Pass
+	importer *analyzeutil.Importer
+	spec     SymbolSpec
+
+	// isType is set when spec is a type, in which case links to its methods are kept pointing to
+	// methods of the same name on the replacement.
+	isType bool
+
+	// to is the symbol links should point to instead. If it's nil, links are reported instead of
+	// rewritten.
+	to *linkTarget
+}
+
+func (cu *commentUpdater) run() error {
+	own := cu.ownDocs()
+
+	for _, file := range cu.pass.Files {
+		for _, group := range file.Comments {
+			if own[group] {
+				continue
+			}
+
+			for _, c := range group.List {
+				err := cu.updateComment(file, c)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (cu *commentUpdater) updateComment(file *ast.File, c *ast.Comment) error {
+	// Mentions inside doc links are dealt with as links, so they're blanked out of the prose.
+	prose := []byte(c.Text)
+
+	for _, m := range docLinkRE.FindAllStringSubmatchIndex(c.Text, -1) {
+		if m[1] < len(c.Text) && c.Text[m[1]] == ':' {
+			// A link definition, like [Text]: https://example.com.
+			continue
+		}
+
+		l, ok := cu.parseDocLink(file, c.Text[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+
+		for i := m[0]; i < m[1]; i++ {
+			prose[i] = ' '
+		}
+
+		if !cu.refersTo(l) {
+			continue
+		}
+
+		l.pos, l.end = c.Slash+token.Pos(m[2]), c.Slash+token.Pos(m[3])
+		err := cu.rewrite(file, l)
+		if err != nil {
+			return err
+		}
+	}
+
+	mentionRE := regexp.MustCompile(`(?:^|[^\pL\pN_])(` + regexp.QuoteMeta(cu.spec.Name()) + `)(?:$|[^\pL\pN_])`)
+	for _, m := range mentionRE.FindAllSubmatchIndex(prose, -1) {
+		cu.pass.Report(analysis.Diagnostic{
+			Pos:     c.Slash + token.Pos(m[2]),
+			End:     c.Slash + token.Pos(m[3]),
+			Message: "comment mentions " + cu.spec.Name() + "; check whether it needs updating",
+		})
+	}
+
+	return nil
+}
+
+// parseDocLink resolves the text between the brackets of a doc link the way go doc does: it's
+// [Name], [Name.Method], [pkg.Name] or [pkg.Name.Method], where pkg is the name of one of the file's
+// imports or a full import path. Unqualified names have to be declared in the package.
+func (cu *commentUpdater) parseDocLink(file *ast.File, text string) (docLink, bool) {
+	var l docLink
+	l.star = strings.HasPrefix(text, "*")
+	text = strings.TrimPrefix(text, "*")
+
+	if slash := strings.LastIndex(text, "/"); slash != -1 {
+		dot := strings.Index(text[slash:], ".")
+		if dot == -1 {
+			return docLink{}, false
+		}
+		l.path, text = text[:slash+dot], text[slash+dot+1:]
+	}
+
+	parts := strings.Split(text, ".")
+	for _, p := range parts {
+		if !token.IsIdentifier(p) {
+			return docLink{}, false
+		}
+	}
+
+	switch {
+	case l.path != "":
+	case len(parts) == 3 || len(parts) == 2 && cu.importedAs(file, parts[0]) != "":
+		l.path = cu.importedAs(file, parts[0])
+		if l.path == "" {
+			return docLink{}, false
+		}
+		parts = parts[1:]
+	default:
+		if cu.pass.Pkg.Scope().Lookup(parts[0]) == nil {
+			return docLink{}, false
+		}
+		l.path = cu.pass.Pkg.Path()
+	}
+
+	switch len(parts) {
+	case 1:
+		l.name = parts[0]
+	case 2:
+		l.name, l.method = parts[0], parts[1]
+	default:
+		return docLink{}, false
+	}
+
+	return l, true
+}
+
+// importedAs returns the path of the package file imports under name.
+func (cu *commentUpdater) importedAs(file *ast.File, name string) string {
+	for _, spec := range file.Imports {
+		pkgName := cu.pass.TypesInfo.PkgNameOf(spec)
+		if pkgName != nil && pkgName.Name() == name {
+			return pkgName.Imported().Path()
+		}
+	}
+	return ""
+}
+
+// refersTo reports whether l points to the symbol being replaced.
+func (cu *commentUpdater) refersTo(l docLink) bool {
+	if l.path != cu.spec.Pkg {
+		return false
+	}
+
+	switch {
+	case cu.isType:
+		return l.name == cu.spec.name
+	case cu.spec.recv != "":
+		return l.name == cu.spec.recv && l.method == cu.spec.name
+	default:
+		return l.name == cu.spec.name && l.method == ""
+	}
+}
+
+// rewrite points l at the replacement, or reports it if there's nothing to point it at.
+func (cu *commentUpdater) rewrite(file *ast.File, l docLink) error {
+	if cu.to == nil {
+		cu.pass.Report(analysis.Diagnostic{
+			Pos:     l.pos,
+			End:     l.end,
+			Message: "doc link to " + cu.spec.Name() + " can't be updated because the replacement isn't a call to a single function",
+		})
+		return nil
+	}
+
+	to := *cu.to
+	if cu.isType {
+		to.method = l.method
+	}
+
+	var sb strings.Builder
+	if l.star {
+		sb.WriteString("*")
+	}
+	if q := cu.qualifier(file, to); q != "" {
+		sb.WriteString(q + ".")
+	}
+	sb.WriteString(to.name)
+	if to.method != "" {
+		sb.WriteString("." + to.method)
+	}
+
+	return analyzeutil.ReplaceRange(cu.pass, l.pos, l.end, sb.String())
+}
+
+// qualifier returns what a doc link in file has to qualify names from the package of to with: nothing
+// inside the package itself, the name of the package if the file imports it and its full path if it
+// doesn't.
+func (cu *commentUpdater) qualifier(file *ast.File, to linkTarget) string {
+	if to.path == cu.pass.Pkg.Path() {
+		return ""
+	}
+
+	name, ok := cu.importer.Imported(cu.pass.Fset, file, to.path)
+	switch {
+	case !ok || name == "_" || name == ".":
+		return to.path
+	case name != "":
+		return name
+	}
+
+	for _, imp := range cu.pass.Pkg.Imports() {
+		if imp.Path() == to.path {
+			return imp.Name()
+		}
+	}
+	if to.pkgName != "" {
+		return to.pkgName
+	}
+	return to.path
+}
+
+// ownDocs returns the doc comments of the declarations of the symbol being replaced (and of its
+// methods, if it's a type), which are about the symbol rather than references to it.
+func (cu *commentUpdater) ownDocs() map[*ast.CommentGroup]bool {
+	own := make(map[*ast.CommentGroup]bool)
+	if cu.pass.Pkg.Path() != cu.spec.Pkg {
+		return own
+	}
+
+	info := cu.pass.TypesInfo
+	for _, file := range cu.pass.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				fn, ok := info.Defs[decl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+
+				if cu.isType {
+					recv := fn.Signature().Recv()
+					if recv == nil {
+						continue
+					}
+					named, ok := analyzeutil.Deref(recv.Type()).(*types.Named)
+					if !ok || !cu.spec.matchesTopLevelSymbol(named.Obj()) {
+						continue
+					}
+				} else if !cu.spec.matchesFunc(fn) {
+					continue
+				}
+
+				own[decl.Doc] = true
+			case *ast.GenDecl:
+				if !cu.isType {
+					continue
+				}
+
+				for _, spec := range decl.Specs {
+					spec, ok := spec.(*ast.TypeSpec)
+					if !ok || !cu.spec.matchesTopLevelSymbol(info.Defs[spec.Name]) {
+						continue
+					}
+
+					own[spec.Doc] = true
+					if len(decl.Specs) == 1 {
+						own[decl.Doc] = true
+					}
+				}
+			}
+		}
+	}
+
+	delete(own, nil)
+	return own
+}
@@ -0,0 +1,189 @@
+package replace
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+func NewCallDeleter() *analysis.Analyzer {
+	var flags struct {
+		function string
+	}
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flagSet.StringVar(&flags.function, "func", "", "The function to delete calls to. Format is 'github.com/package/path.FunctionName'")
+
+	return &analysis.Analyzer{
+		Name:  "deletecall",
+		Doc:   "Delete calls to a function.",
+		Flags: *flagSet,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			if flags.function == "" {
+				return nil, errors.New("func must be provided")
+			}
+
+			importer := &analyzeutil.Importer{}
+			inspector := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+			spec, err := ParseSymbolSpec(flags.function)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing func: %w", err)
+			}
+
+			err = doCallDeletion(pass, spec, inspector, importer)
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, importer.Rewrite(pass)
+		},
+		Requires: []*analysis.Analyzer{
+			inspect.Analyzer,
+		},
+	}
+}
+
+func doCallDeletion(
+	pass *analysis.Pass,
+	parsedFunc SymbolSpec,
+	inspector *inspector.Inspector,
+	importer *analyzeutil.Importer,
+) error {
+	deleted := make(map[*ast.File][]ast.Stmt)
+
+	var err error
+	inspector.WithStack(
+		[]ast.Node{&ast.CallExpr{}},
+		func(n ast.Node, push bool, stack []ast.Node) bool {
+			if !push || err != nil {
+				return false
+			}
+
+			callExpr := n.(*ast.CallExpr)
+			if !parsedFunc.matchesFunc(callee(pass.TypesInfo, callExpr)) {
+				return true
+			}
+
+			stmt, reason := deletableStmt(pass.TypesInfo, callExpr, stack)
+			if stmt == nil {
+				pass.Report(analysis.Diagnostic{
+					Pos:     callExpr.Pos(),
+					End:     callExpr.End(),
+					Message: "not deleting call: " + reason,
+				})
+				return true
+			}
+
+			err = analyzeutil.DeleteStmt(pass, stmt)
+			if err != nil {
+				return false
+			}
+
+			file := stack[0].(*ast.File)
+			deleted[file] = append(deleted[file], stmt)
+
+			return false
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	// Find every use of each imported package so that we can tell which imports were only used by the
+	// statements we deleted.
+	pkgUses := make(map[*types.PkgName][]token.Pos)
+	for id, obj := range pass.TypesInfo.Uses {
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			pkgUses[pkgName] = append(pkgUses[pkgName], id.Pos())
+		}
+	}
+
+	for file, stmts := range deleted {
+		for _, spec := range file.Imports {
+			pkgName := pass.TypesInfo.PkgNameOf(spec)
+			if pkgName == nil || (spec.Name != nil && (spec.Name.Name == "_" || spec.Name.Name == ".")) {
+				continue
+			}
+
+			if usedOnlyIn(pkgUses[pkgName], stmts) {
+				importer.Remove(pass.Fset, file, pkgName.Imported().Path())
+			}
+		}
+	}
+
+	return nil
+}
+
+// deletableStmt returns the statement that can be deleted to remove call. If there isn't one, it
+// returns the reason why.
+func deletableStmt(info *types.Info, call *ast.CallExpr, stack []ast.Node) (ast.Stmt, string) {
+	if len(stack) < 3 {
+		return nil, "its result is used"
+	}
+
+	var stmt ast.Stmt
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.ExprStmt:
+		stmt = parent
+	case *ast.DeferStmt:
+		stmt = parent
+	case *ast.GoStmt:
+		stmt = parent
+	default:
+		return nil, "its result is used"
+	}
+
+	switch stack[len(stack)-3].(type) {
+	case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+	case *ast.LabeledStmt:
+		return nil, "the statement is labeled"
+	default:
+		return nil, "the statement can't be removed from where it appears"
+	}
+
+	// Deleting the call would also skip evaluating its receiver and arguments.
+	for _, e := range append([]ast.Expr{call.Fun}, call.Args...) {
+		if classify(info, e) == impure {
+			return nil, "its receiver or arguments have side effects"
+		}
+	}
+
+	return stmt, ""
+}
+
+// usedOnlyIn reports whether every one of uses is inside one of stmts. It's false if there are no
+// uses at all, because then the import wasn't used by any of stmts either.
+func usedOnlyIn(uses []token.Pos, stmts []ast.Stmt) bool {
+	if len(uses) == 0 {
+		return false
+	}
+
+	for _, pos := range uses {
+		inside := false
+		for _, stmt := range stmts {
+			if stmt.Pos() <= pos && pos < stmt.End() {
+				inside = true
+				break
+			}
+		}
+
+		if !inside {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,126 @@
+package replace
+
+import (
+	"go/ast"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+)
+
+// An embedded field is implicitly named after its type, so replacing the type of an embedded field
+// renames the field too. The functions in this file keep the code referring to the field compiling,
+// and report the methods that are no longer promoted through it.
+
+// renamesEmbedded reports whether replacing the type renames the fields that embed it.
+func (tr *typeReplacer) renamesEmbedded() bool {
+	return tr.spec.name != tr.replacement.name
+}
+
+// embeddedField returns the field id refers to if it's a field that embeds the type being replaced,
+// like the Logger in s.Logger or in the key of Service{Logger: l}.
+func (tr *typeReplacer) embeddedField(id *ast.Ident) *types.Var {
+	v, ok := tr.pass.TypesInfo.Uses[id].(*types.Var)
+	if !ok || !v.Embedded() || !tr.isTargetOrInstance(analyzeutil.Deref(v.Type())) {
+		return nil
+	}
+	return v
+}
+
+// replaceEmbeddedFieldRef renames a reference to a field embedding the type being replaced.
+func (tr *typeReplacer) replaceEmbeddedFieldRef(id *ast.Ident) error {
+	return analyzeutil.ReplaceNode(tr.pass, id, tr.replacement.name)
+}
+
+// reportLostMethods reports the methods of the type being replaced that won't be promoted through
+// the embedded field any more, because the replacement type doesn't have them. The replacement type
+// can only be inspected when its package is a dependency of the package being analyzed.
+func (tr *typeReplacer) reportLostMethods(n ast.Expr) {
+	lost := tr.lostMethods()
+	if len(lost) == 0 {
+		return
+	}
+
+	tr.report(n, "embedding %s instead of %s no longer promotes %s", tr.replacement.name, tr.spec.name, strings.Join(lost, ", "))
+}
+
+// reportLostMethodUse reports sel if it selects a method that was promoted through a field embedding
+// the type being replaced and that the replacement type doesn't have.
+func (tr *typeReplacer) reportLostMethodUse(sel *ast.SelectorExpr) {
+	selection, ok := tr.pass.TypesInfo.Selections[sel]
+	if !ok || len(selection.Index()) < 2 {
+		// Not promoted.
+		return
+	}
+
+	fn, ok := selection.Obj().(*types.Func)
+	if !ok {
+		return
+	}
+
+	recv := fn.Signature().Recv()
+	if recv == nil || !tr.isTargetOrInstance(analyzeutil.Deref(recv.Type())) {
+		return
+	}
+
+	if !slices.Contains(tr.lostMethods(), fn.Name()) {
+		return
+	}
+
+	tr.report(sel, "%s is promoted from %s, which %s doesn't have", fn.Name(), tr.spec.name, tr.replacement.name)
+}
+
+// lostMethods returns the names of the exported methods of the type being replaced that the
+// replacement type doesn't have.
+func (tr *typeReplacer) lostMethods() []string {
+	replacementType := lookupType(tr.pass.Pkg, tr.replacement.Pkg, tr.replacement.name)
+	if replacementType == nil {
+		return nil
+	}
+
+	orig := lookupType(tr.pass.Pkg, tr.spec.Pkg, tr.spec.name)
+	if orig == nil {
+		return nil
+	}
+
+	have := types.NewMethodSet(types.NewPointer(replacementType.Type()))
+
+	var lost []string
+	origMethods := types.NewMethodSet(types.NewPointer(orig.Type()))
+	for i := range origMethods.Len() {
+		name := origMethods.At(i).Obj().Name()
+		if !ast.IsExported(name) {
+			continue
+		}
+
+		if have.Lookup(replacementType.Pkg(), name) == nil {
+			lost = append(lost, name)
+		}
+	}
+
+	return lost
+}
+
+// isTargetOrInstance is like isTarget, but also matches instantiations of a generic type being
+// replaced.
+func (tr *typeReplacer) isTargetOrInstance(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && tr.spec.matchesTopLevelSymbol(named.Origin().Obj())
+}
+
+// isEmbedding reports whether n, the last element of stack, is the type of an embedded field.
+func isEmbedding(n ast.Expr, stack []ast.Node) bool {
+	i := len(stack) - 2
+	if star, ok := stack[i].(*ast.StarExpr); ok && star.X == n {
+		n, i = star, i-1
+	}
+
+	field, ok := stack[i].(*ast.Field)
+	if !ok || len(field.Names) != 0 || field.Type != n {
+		return false
+	}
+
+	_, ok = stack[i-2].(*ast.StructType)
+	return ok
+}
@@ -0,0 +1,337 @@
+package replace
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/analysis"
+)
+
+// A replacement can evaluate the arguments of a call in a different order than the call did, or more
+// than once, like Replaced($arg1, $arg0) or $arg0 + $arg0. That's only safe for arguments without
+// side effects. The functions in this file find the arguments that aren't, so that they can be moved
+// into variables before the call, or the call reported if that isn't possible.
+
+// purity is what evaluating an expression can do.
+type purity int
+
+const (
+	// constant expressions evaluate to the same thing however many times and in whatever order
+	// they're evaluated, like literals, constants and function names.
+	constant purity = iota
+
+	// pure expressions have no side effects, but read variables that side effects could change.
+	pure
+
+	// impure expressions have side effects, like calls and channel receives.
+	impure
+)
+
+// pureBuiltins are the builtin functions that have no side effects.
+var pureBuiltins = map[string]bool{
+	"cap": true, "complex": true, "imag": true, "len": true, "max": true, "min": true, "real": true,
+}
+
+// classify works out what evaluating e can do.
+func classify(info *types.Info, e ast.Expr) purity {
+	if tv, ok := info.Types[e]; ok && (tv.Value != nil || tv.IsNil() || tv.IsType()) {
+		return constant
+	}
+
+	switch e := ast.Unparen(e).(type) {
+	case *ast.FuncLit:
+		return constant
+	case *ast.Ident:
+		if _, ok := info.Uses[e].(*types.Var); !ok {
+			// A function, a package or something else that can't change.
+			return constant
+		}
+	}
+
+	p := pure
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// The body doesn't run when the literal is evaluated.
+			return false
+		case *ast.CallExpr:
+			if info.Types[n.Fun].IsType() {
+				// A conversion.
+				return true
+			}
+			if id, ok := ast.Unparen(n.Fun).(*ast.Ident); ok {
+				if b, ok := info.Uses[id].(*types.Builtin); ok && pureBuiltins[b.Name()] {
+					return true
+				}
+			}
+			p = impure
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				p = impure
+			}
+		}
+		return p != impure
+	})
+
+	return p
+}
+
+// argUses returns the indices of the arguments the replacement uses, in the order it uses them, for a
+// call with nargs arguments.
+func (pr parsedReplacement) argUses(nargs int) []int {
+	var uses []int
+	for _, r := range pr.replacers {
+		switch r := r.(type) {
+		case argReplacer:
+			uses = append(uses, r.index)
+		case argsReplacer:
+			for i := range nargs {
+				uses = append(uses, i)
+			}
+		case returnReplacer:
+			uses = append(uses, r.value.argUses(nargs)...)
+		}
+	}
+	return uses
+}
+
+// evalOrderProblem returns why evaluating the arguments of call where the replacement does would
+// change what the call does, or an empty string if it wouldn't. An argument with side effects has to
+// be evaluated exactly once, and can't be evaluated in a different order relative to another argument
+// that isn't constant.
+func (pr parsedReplacement) evalOrderProblem(fset *token.FileSet, info *types.Info, call *ast.CallExpr) string {
+	uses := pr.argUses(len(call.Args))
+
+	count := make(map[int]int)
+	firstUse := make(map[int]int)
+	for i, arg := range uses {
+		if arg >= len(call.Args) {
+			// The replacement fails to print anyway.
+			return ""
+		}
+
+		count[arg]++
+		if count[arg] == 1 {
+			firstUse[arg] = i
+		}
+	}
+
+	for i, arg := range call.Args {
+		if classify(info, arg) != impure {
+			continue
+		}
+		switch {
+		case count[i] > 1:
+			return fmt.Sprintf("evaluates $arg%d (%s) more than once", i, formatArg(fset, arg))
+		case count[i] == 0:
+			return fmt.Sprintf("doesn't evaluate $arg%d (%s), which has side effects", i, formatArg(fset, arg))
+		}
+	}
+
+	for i, a := range call.Args {
+		for j := i + 1; j < len(call.Args); j++ {
+			b := call.Args[j]
+			if count[i] == 0 || count[j] == 0 || firstUse[i] < firstUse[j] {
+				continue
+			}
+
+			ci, cj := classify(info, a), classify(info, b)
+			if ci == impure && cj != constant || cj == impure && ci != constant {
+				return fmt.Sprintf(
+					"evaluates $arg%d (%s) before $arg%d (%s)",
+					j, formatArg(fset, b), i, formatArg(fset, a),
+				)
+			}
+		}
+	}
+
+	return ""
+}
+
+func formatArg(fset *token.FileSet, e ast.Expr) string {
+	s, err := analyzeutil.FormatNode(fset, e)
+	if err != nil {
+		return "?"
+	}
+	return s
+}
+
+// hoister moves the arguments of calls into variables declared before the statements enclosing the
+// calls. It remembers the variables it declared, so that it doesn't declare one twice in a scope.
+type hoister struct {
+	info     *types.Info
+	fset     *token.FileSet
+	pkg      *types.Package
+	declared map[*types.Scope]map[string]bool
+}
+
+func newHoister(pass *analysis.Pass) *hoister {
+	return &hoister{
+		info:     pass.TypesInfo,
+		fset:     pass.Fset,
+		pkg:      pass.Pkg,
+		declared: make(map[*types.Scope]map[string]bool),
+	}
+}
+
+// hoist picks the arguments of call to move into variables declared before stmt, so that they're
+// evaluated in their original order and only once. Those are the arguments before the last one with
+// side effects, except for constants, and the last one too unless the replacement evaluates it once
+// and before the others. Arguments the replacement doesn't use are evaluated for their side effects
+// alone. It returns the names of the variables by argument index, and the declarations of them. The
+// names are based on the names of the parameters of the function being called, and don't clash with
+// anything in scope. The types in the declarations are qualified with qualifier.
+func (h *hoister) hoist(
+	r parsedReplacement,
+	call *ast.CallExpr,
+	stmt ast.Stmt,
+	stack []ast.Node,
+	src []byte,
+	qualifier types.Qualifier,
+) (map[int]string, []string, bool) {
+	if assign, ok := stmt.(*ast.AssignStmt); ok {
+		// The left-hand side is evaluated before the call.
+		for _, lhs := range assign.Lhs {
+			if classify(h.info, lhs) == impure {
+				return nil, nil, false
+			}
+		}
+	}
+
+	last := -1
+	for i, arg := range call.Args {
+		if classify(h.info, arg) == impure {
+			last = i
+		}
+	}
+
+	// The last argument with side effects can stay where it is if it's evaluated once, before any
+	// other argument that's left.
+	keepLast := false
+	uses := r.argUses(len(call.Args))
+	for _, i := range uses {
+		if i >= last && classify(h.info, call.Args[i]) != constant {
+			keepLast = i == last && slices.Index(uses[slices.Index(uses, i)+1:], last) == -1
+			break
+		}
+	}
+
+	var scope *types.Scope
+	for i := len(stack) - 1; i >= 0 && scope == nil; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncDecl:
+			// The scope of a function's body belongs to its type.
+			scope = h.info.Scopes[n.Type]
+		case *ast.FuncLit:
+			scope = h.info.Scopes[n.Type]
+		default:
+			scope = h.info.Scopes[n]
+		}
+	}
+	if scope == nil {
+		return nil, nil, false
+	}
+	if h.declared[scope] == nil {
+		h.declared[scope] = make(map[string]bool)
+	}
+	declared := h.declared[scope]
+
+	var params *types.Tuple
+	if fn, ok := callee(h.info, call).(*types.Func); ok {
+		params = fn.Signature().Params()
+	}
+	// The signature of the call itself has the type arguments of a generic function filled in.
+	sig, _ := h.info.TypeOf(call.Fun).(*types.Signature)
+
+	site := callSite{fset: h.fset, call: call, src: src}
+
+	names := make(map[int]string)
+	var decls []string
+	for i := 0; i <= last; i++ {
+		if classify(h.info, call.Args[i]) == constant {
+			continue
+		}
+		if i == last && keepLast {
+			continue
+		}
+		if !slices.Contains(uses, i) {
+			if classify(h.info, call.Args[i]) != impure {
+				continue
+			}
+			if _, ok := h.info.TypeOf(call.Args[i]).(*types.Tuple); ok {
+				// A call with no results, or with several, is only a statement of its own.
+				decls = append(decls, site.argSource(i).inline())
+			} else {
+				decls = append(decls, "_ = "+site.argSource(i).inline())
+			}
+			continue
+		}
+
+		base := "arg" + strconv.Itoa(i)
+		if params != nil && i < params.Len() && params.At(i).Name() != "" && params.At(i).Name() != "_" {
+			base = params.At(i).Name()
+		}
+
+		name := base
+		for n := 2; declared[name] || inScope(scope, name); n++ {
+			name = base + strconv.Itoa(n)
+		}
+		declared[name] = true
+
+		names[i] = name
+		if typ := h.declType(sig, call, i); typ != nil {
+			decls = append(decls, "var "+name+" "+types.TypeString(typ, qualifier)+" = "+site.argSource(i).inline())
+		} else {
+			decls = append(decls, name+" := "+site.argSource(i).inline())
+		}
+	}
+
+	return names, decls, true
+}
+
+// declType returns the type to declare the variable holding the ith argument of call with, or nil if
+// := gives it the type of the parameter it's passed to already. That's not the case for an untyped
+// expression, like a comparison passed as a named bool type, which := gives its default type.
+func (h *hoister) declType(sig *types.Signature, call *ast.CallExpr, i int) types.Type {
+	if sig == nil {
+		return nil
+	}
+	param := paramType(sig, i, call.Ellipsis.IsValid())
+	if param == nil {
+		return nil
+	}
+
+	arg := call.Args[i]
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	err := types.CheckExpr(h.fset, h.pkg, arg.Pos(), arg, info)
+	if err != nil {
+		return nil
+	}
+
+	if types.Identical(types.Default(info.TypeOf(arg)), param) {
+		return nil
+	}
+	return param
+}
+
+// inScope reports whether name is declared in scope or the scopes around it, anywhere in them, so
+// that declaring it again would either clash or shadow something.
+func inScope(scope *types.Scope, name string) bool {
+	_, obj := scope.LookupParent(name, token.NoPos)
+	return obj != nil
+}
+
+// writeDecls writes the declarations of hoisted arguments as statements, each followed by indent.
+func writeDecls(decls []string, indent string) string {
+	var sb strings.Builder
+	for _, d := range decls {
+		sb.WriteString(d + "\n" + indent)
+	}
+	return sb.String()
+}
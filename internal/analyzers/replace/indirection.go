@@ -0,0 +1,265 @@
+package replace
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/analysis"
+)
+
+// PointerShape describes how the indirection of a type changes when it's replaced.
+type PointerShape string
+
+const (
+	// ShapeSame replaces A with B and *A with *B.
+	ShapeSame PointerShape = ""
+	// ShapePointerToValue replaces *A with B.
+	ShapePointerToValue PointerShape = "ptr-to-value"
+	// ShapeValueToPointer replaces A with *B.
+	ShapeValueToPointer PointerShape = "value-to-ptr"
+)
+
+func ParsePointerShape(s string) (PointerShape, error) {
+	switch shape := PointerShape(s); shape {
+	case ShapeSame, ShapePointerToValue, ShapeValueToPointer:
+		return shape, nil
+	default:
+		return "", fmt.Errorf("unknown pointer shape %q; expected %s or %s", s, ShapePointerToValue, ShapeValueToPointer)
+	}
+}
+
+// typeName returns the replacement for a reference to the type being replaced that appears where a
+// type is expected, like a field type or a parameter type.
+func (tr *typeReplacer) typeName(file *ast.File) string {
+	if tr.shape == ShapeValueToPointer {
+		return "*" + tr.name(file)
+	}
+	return tr.name(file)
+}
+
+// replaceIndirection handles the expressions whose indirection changes along with the type: pointer
+// types, address-of and dereference expressions, composite literals and calls to new. It reports
+// whether n was handled completely, in which case there's no need to descend into it. Parts of n that
+// were handled without handling all of n are added to tr.skip.
+func (tr *typeReplacer) replaceIndirection(file *ast.File, n ast.Node, stack []ast.Node) (bool, error) {
+	info := tr.pass.TypesInfo
+
+	switch n := n.(type) {
+	case *ast.StarExpr:
+		if info.Types[n].IsType() {
+			if !tr.matches(n.X) {
+				return false, nil
+			}
+
+			if tr.shape == ShapeValueToPointer {
+				// This is already a pointer, so collapse *A into *B rather than producing **B.
+				return true, analyzeutil.ReplaceNode(tr.pass, n, tr.typeName(file))
+			}
+			return true, analyzeutil.ReplaceNode(tr.pass, n, tr.name(file))
+		}
+
+		// A dereference.
+		if !tr.isPointerToTarget(info.TypeOf(n.X)) {
+			return false, nil
+		}
+
+		switch tr.shape {
+		case ShapePointerToValue:
+			if isAssignedTo(n, stack) {
+				tr.report(n, "assignment through a pointer to %s now assigns to a copy", tr.spec.name)
+			}
+			if paren, ok := stack[len(stack)-2].(*ast.ParenExpr); ok {
+				if id, ok := n.X.(*ast.Ident); ok {
+					// (*c).F becomes c.F rather than (c).F.
+					return true, analyzeutil.ReplaceNode(tr.pass, paren, id.Name)
+				}
+			}
+			return false, analyzeutil.ReplaceRange(tr.pass, n.Star, n.X.Pos(), "")
+		case ShapeValueToPointer:
+			tr.report(n, "dereferencing a pointer to %s now produces a %s value rather than a %s", tr.spec.name, tr.name(file), tr.typeName(file))
+		}
+		return false, nil
+	case *ast.UnaryExpr:
+		if n.Op != token.AND {
+			return false, nil
+		}
+
+		if lit, ok := ast.Unparen(n.X).(*ast.CompositeLit); ok && lit.Type != nil && tr.matches(lit.Type) {
+			tr.skip[lit.Type] = true
+			if tr.shape == ShapePointerToValue {
+				// &A{...} becomes B{...}.
+				return false, analyzeutil.ReplaceRange(tr.pass, n.Pos(), lit.Type.End(), tr.name(file))
+			}
+			// &A{...} becomes &B{...}, which is already the pointer we want.
+			return false, analyzeutil.ReplaceNode(tr.pass, lit.Type, tr.name(file))
+		}
+
+		if tr.shape == ShapeValueToPointer && tr.isTarget(info.TypeOf(n.X)) {
+			tr.report(n, "taking the address of a %s value now produces a pointer to %s", tr.spec.name, tr.typeName(file))
+		}
+		return false, nil
+	case *ast.CompositeLit:
+		if tr.shape != ShapeValueToPointer || n.Type == nil || !tr.matches(n.Type) {
+			return false, nil
+		}
+
+		if tr.skip[n.Type] {
+			// The literal's address is already taken.
+			return false, nil
+		}
+
+		// A{...} becomes &B{...}.
+		tr.skip[n.Type] = true
+		return false, analyzeutil.ReplaceNode(tr.pass, n.Type, "&"+tr.name(file))
+	case *ast.CallExpr:
+		if info.Types[n.Fun].IsType() && tr.matches(n.Fun) {
+			// A conversion to the type being replaced.
+			if tr.shape == ShapeValueToPointer {
+				tr.report(n, "conversion to %s can't be rewritten to a conversion to %s", tr.spec.name, tr.typeName(file))
+				return true, nil
+			}
+			return false, nil
+		}
+
+		if !isBuiltinNew(info, n) || !tr.matches(n.Args[0]) {
+			return false, nil
+		}
+
+		switch tr.shape {
+		case ShapePointerToValue:
+			return true, analyzeutil.ReplaceNode(tr.pass, n, tr.zeroValue(file))
+		case ShapeValueToPointer:
+			tr.skip[n.Args[0]] = true
+			return true, analyzeutil.ReplaceNode(tr.pass, n.Args[0], tr.name(file))
+		}
+		return false, nil
+	case *ast.BinaryExpr:
+		if tr.shape != ShapePointerToValue || (n.Op != token.EQL && n.Op != token.NEQ) {
+			return false, nil
+		}
+
+		if (isNil(info, n.X) && tr.isPointerToTarget(info.TypeOf(n.Y))) ||
+			(isNil(info, n.Y) && tr.isPointerToTarget(info.TypeOf(n.X))) {
+			tr.report(n, "%s values can't be compared with nil", tr.name(file))
+		}
+		return false, nil
+	default:
+		return false, nil
+	}
+}
+
+// reportCopy reports expressions whose sharing semantics change along with the type. When a pointer
+// becomes a value, passing it around copies the value where it used to share it. When a value
+// becomes a pointer, the opposite happens.
+func (tr *typeReplacer) reportCopy(e ast.Expr, stack []ast.Node) {
+	if !isCopied(e, stack) {
+		return
+	}
+
+	typ := tr.pass.TypesInfo.TypeOf(e)
+
+	switch {
+	case tr.shape == ShapePointerToValue && tr.isPointerToTarget(typ):
+		tr.report(e, "this was a shared pointer to %s but is now a copied value", tr.spec.name)
+	case tr.shape == ShapeValueToPointer && tr.isTarget(typ):
+		tr.report(e, "this was a copied %s value but is now a shared pointer", tr.spec.name)
+	}
+}
+
+func (tr *typeReplacer) zeroValue(file *ast.File) string {
+	if tn := lookupType(tr.pass.Pkg, tr.replacement.Pkg, tr.replacement.name); tn != nil {
+		switch tn.Type().Underlying().(type) {
+		case *types.Struct, *types.Array:
+			return tr.name(file) + "{}"
+		}
+	}
+
+	return "*new(" + tr.name(file) + ")"
+}
+
+func (tr *typeReplacer) isTarget(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && tr.spec.matchesTopLevelSymbol(named.Obj())
+}
+
+func (tr *typeReplacer) isPointerToTarget(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	return ok && tr.isTarget(ptr.Elem())
+}
+
+func (tr *typeReplacer) report(n ast.Node, format string, args ...any) {
+	tr.pass.Report(analysis.Diagnostic{
+		Pos:     n.Pos(),
+		End:     n.End(),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// isCopied reports whether e, the last element of stack, is a variable or field whose value is
+// copied somewhere else: assigned, passed as an argument, returned or put in a composite literal.
+func isCopied(e ast.Expr, stack []ast.Node) bool {
+	if len(stack) < 2 {
+		return false
+	}
+
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.AssignStmt:
+		for _, rhs := range parent.Rhs {
+			if rhs == e {
+				return true
+			}
+		}
+	case *ast.ValueSpec:
+		for _, v := range parent.Values {
+			if v == e {
+				return true
+			}
+		}
+	case *ast.CallExpr:
+		for _, arg := range parent.Args {
+			if arg == e {
+				return true
+			}
+		}
+	case *ast.ReturnStmt, *ast.CompositeLit:
+		return true
+	case *ast.KeyValueExpr:
+		return parent.Value == e
+	}
+
+	return false
+}
+
+// isAssignedTo reports whether e, the last element of stack, is on the left-hand side of an
+// assignment.
+func isAssignedTo(e ast.Expr, stack []ast.Node) bool {
+	assign, ok := stack[len(stack)-2].(*ast.AssignStmt)
+	if !ok {
+		return false
+	}
+
+	for _, lhs := range assign.Lhs {
+		if lhs == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isBuiltinNew(info *types.Info, call *ast.CallExpr) bool {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+
+	b, ok := info.Uses[id].(*types.Builtin)
+	return ok && b.Name() == "new"
+}
+
+func isNil(info *types.Info, e ast.Expr) bool {
+	return info.Types[e].IsNil()
+}
@@ -0,0 +1,127 @@
+package replace
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// NewConversionInserter returns an analyzer that runs after a type has been replaced. Wherever a value
+// now flows between the replacement type and a type that isn't identical to it, it wraps the value in
+// an explicit conversion. It runs despite type errors because fixing them is the whole point.
+func NewConversionInserter() *analysis.Analyzer {
+	var flags struct {
+		typeName string
+	}
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flagSet.StringVar(&flags.typeName, "type", "", "The type that was put in place by a replacement. Format is 'github.com/package/path.TypeName'")
+
+	return &analysis.Analyzer{
+		Name:             "insertconv",
+		Doc:              "Insert conversions to and from a replaced type where they're needed.",
+		Flags:            *flagSet,
+		RunDespiteErrors: true,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			if flags.typeName == "" {
+				return nil, errors.New("type is required")
+			}
+
+			importer := &analyzeutil.Importer{}
+			inspector := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+			spec, err := ParseSymbolSpec(flags.typeName)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing type: %w", err)
+			}
+			if spec.IsPattern() {
+				return nil, errors.New("type must name one type, not a pattern")
+			}
+
+			err = doConversionInsertion(pass, spec, inspector, importer)
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, importer.Rewrite(pass)
+		},
+		Requires: []*analysis.Analyzer{
+			inspect.Analyzer,
+		},
+	}
+}
+
+func doConversionInsertion(
+	pass *analysis.Pass,
+	spec SymbolSpec,
+	inspector *inspector.Inspector,
+	importer *analyzeutil.Importer,
+) error {
+	info := pass.TypesInfo
+
+	var err error
+	// convert inserts a conversion of e to target if e's value can't be used as a target as it is.
+	convert := func(file *ast.File, target types.Type, e ast.Expr) {
+		if err != nil || target == nil || !involves(spec, target, info.TypeOf(e)) {
+			return
+		}
+
+		typ := info.TypeOf(e)
+		if !isValid(typ) || !isValid(target) || types.AssignableTo(typ, target) {
+			return
+		}
+
+		qualifier := importer.Qualifier(pass.Fset, file, pass.Pkg)
+		if !types.ConvertibleTo(typ, target) {
+			pass.Report(analysis.Diagnostic{
+				Pos:     e.Pos(),
+				End:     e.End(),
+				Message: fmt.Sprintf("cannot convert %s to %s", types.TypeString(typ, qualifier), types.TypeString(target, qualifier)),
+			})
+			return
+		}
+
+		var formatted string
+		formatted, err = analyzeutil.FormatNode(pass.Fset, e)
+		if err != nil {
+			return
+		}
+
+		conversion := types.TypeString(target, qualifier)
+		switch target.(type) {
+		case *types.Pointer, *types.Signature, *types.Chan:
+			// These types need parentheses to be converted to.
+			conversion = "(" + conversion + ")"
+		}
+
+		err = analyzeutil.ReplaceNode(pass, e, conversion+"("+formatted+")")
+	}
+
+	forEachAssignment(info, inspector, func(file *ast.File, target types.Type, e ast.Expr) bool {
+		convert(file, target, e)
+		return err == nil
+	})
+
+	return err
+}
+
+// involves reports whether either of the types is the type described by spec.
+func involves(spec SymbolSpec, ts ...types.Type) bool {
+	for _, t := range ts {
+		if named, ok := t.(*types.Named); ok && spec.matchesTopLevelSymbol(named.Obj()) {
+			return true
+		}
+	}
+	return false
+}
+
+func isValid(t types.Type) bool {
+	return t != nil && t != types.Typ[types.Invalid]
+}
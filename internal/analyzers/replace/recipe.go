@@ -0,0 +1,193 @@
+package replace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v3"
+)
+
+// Recipe is a list of replacecall and replacetype rules read from a YAML file. The options of each rule
+// are the flags of the analyzer of the same name:
+//
+//	rules:
+//	  - name: old-client
+//	    message: use newclient.New instead
+//	    replacecall:
+//	      func: example.com/old.NewClient
+//	      replacement: $pkg(example.com/newclient,newclient).New($arg0)
+//	  - name: old-config
+//	    replacetype:
+//	      type: example.com/old.Config
+//	      replacement: example.com/newclient.Config
+type Recipe struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is one rule of a recipe.
+type Rule struct {
+	// Name identifies the rule in the diagnostics it reports.
+	Name string `yaml:"name"`
+	// Message is an optional explanation added to the diagnostics of the rule.
+	Message     string            `yaml:"message"`
+	ReplaceCall map[string]string `yaml:"replacecall"`
+	ReplaceType map[string]string `yaml:"replacetype"`
+
+	analyzer *analysis.Analyzer
+}
+
+// LoadRecipe reads the recipe at path and checks that each of its rules is complete. Template paths are
+// relative to the directory of the recipe.
+func LoadRecipe(path string) (Recipe, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	var recipe Recipe
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	dec.KnownFields(true)
+	err = dec.Decode(&recipe)
+	if err != nil {
+		return Recipe{}, fmt.Errorf("recipe %s: %w", path, err)
+	}
+	if len(recipe.Rules) == 0 {
+		return Recipe{}, fmt.Errorf("recipe %s: no rules", path)
+	}
+
+	seen := make(map[string]bool, len(recipe.Rules))
+	for i := range recipe.Rules {
+		r := &recipe.Rules[i]
+		if r.Name == "" {
+			return Recipe{}, fmt.Errorf("recipe %s: rule %d has no name", path, i+1)
+		}
+		if seen[r.Name] {
+			return Recipe{}, fmt.Errorf("recipe %s: there is more than one rule called %s", path, r.Name)
+		}
+		seen[r.Name] = true
+
+		err := r.prepare(filepath.Dir(abs))
+		if err != nil {
+			return Recipe{}, fmt.Errorf("recipe %s: rule %s: %w", path, r.Name, err)
+		}
+	}
+
+	return recipe, nil
+}
+
+// prepare validates the options of the rule and sets up its analyzer.
+func (r *Rule) prepare(dir string) error {
+	var opts map[string]string
+	switch {
+	case r.ReplaceCall != nil && r.ReplaceType != nil:
+		return errors.New("replacecall cannot be combined with replacetype")
+	case r.ReplaceCall != nil:
+		r.analyzer, opts = NewFuncReplacer(), r.ReplaceCall
+		if t := opts["template"]; t != "" && !filepath.IsAbs(t) {
+			opts["template"] = filepath.Join(dir, t)
+		}
+	case r.ReplaceType != nil:
+		r.analyzer, opts = NewTypeReplacer(), r.ReplaceType
+	default:
+		return errors.New("either replacecall or replacetype is required")
+	}
+
+	for name, val := range opts {
+		err := r.analyzer.Flags.Set(name, val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.analyzer.Name, err)
+		}
+	}
+
+	if r.ReplaceCall != nil {
+		return checkCallRule(opts)
+	}
+	return r.checkTypeRule(opts)
+}
+
+func checkCallRule(opts map[string]string) error {
+	hasReplacement := opts["replacement"] != "" || opts["stmt-replacement"] != ""
+	if opts["template"] != "" {
+		if opts["func"] != "" || hasReplacement {
+			return errors.New("template cannot be combined with func, replacement or stmt-replacement")
+		}
+		return nil
+	}
+	if opts["func"] == "" || !hasReplacement {
+		return errors.New("either template or both func and replacement (or stmt-replacement) are required")
+	}
+
+	spec, err := ParseSymbolSpec(opts["func"])
+	if err != nil {
+		return fmt.Errorf("func: %w", err)
+	}
+	if spec.IsPattern() && opts["update-comments"] == "true" {
+		return errors.New("update-comments cannot be used with a pattern")
+	}
+
+	return ValidateReplacements(opts["replacement"], opts["stmt-replacement"], opts["value-replacement"])
+}
+
+func (r *Rule) checkTypeRule(opts map[string]string) error {
+	if opts["type"] == "" || opts["replacement"] == "" {
+		return errors.New("type and replacement are required")
+	}
+
+	typ, err := ParseSymbolSpec(opts["type"])
+	if err != nil {
+		return fmt.Errorf("type: %w", err)
+	}
+	replacement, err := ParseSymbolSpec(opts["replacement"])
+	if err != nil {
+		return fmt.Errorf("replacement: %w", err)
+	}
+	if typ.IsPattern() || replacement.IsPattern() {
+		return errors.New("type and replacement must each name one type, not a pattern")
+	}
+
+	_, err = ParsePointerShape(opts["shape"])
+	if err != nil {
+		return err
+	}
+	_, err = ParseTypeScope(opts["only"], opts["within"])
+	if err != nil {
+		return err
+	}
+
+	if opts["replacement-package-name"] != "" {
+		return nil
+	}
+
+	// The name is only needed to write the fixes, but it's worked out here so that it's done once
+	// rather than for every package.
+	name, err := packageName(replacement.Pkg)
+	if err != nil {
+		return err
+	}
+	return r.analyzer.Flags.Set("replacement-package-name", name)
+}
+
+// packageName returns the name of the package with the given path.
+func packageName(path string) (string, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName}, path)
+	if err != nil {
+		return "", err
+	}
+	if len(pkgs) != 1 {
+		return "", fmt.Errorf("expected one package for %s but loaded %d", path, len(pkgs))
+	}
+	if len(pkgs[0].Errors) > 0 {
+		return "", fmt.Errorf("loading %s: %w", path, pkgs[0].Errors[0])
+	}
+	return pkgs[0].Name, nil
+}
@@ -0,0 +1,156 @@
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRecipe(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "recipe.yaml")
+	err := os.WriteFile(path, []byte(`
+rules:
+  - name: old-open
+    message: use Open instead
+    replacecall:
+      func: example.com/old.MustOpen
+      replacement: Open($arg0)
+      update-comments: true
+  - name: from-template
+    replacecall:
+      template: rules/open.go
+  - name: old-config
+    replacetype:
+      type: example.com/old.Config
+      replacement: example.com/newer.Config
+      replacement-package-name: newer
+`), 0o644)
+	require.NoError(t, err)
+
+	recipe, err := LoadRecipe(path)
+	require.NoError(t, err)
+	require.Len(t, recipe.Rules, 3)
+
+	assert.Equal(t, "old-open", recipe.Rules[0].Name)
+	assert.Equal(t, "use Open instead", recipe.Rules[0].Message)
+	assert.Equal(t, "replacecall", recipe.Rules[0].analyzer.Name)
+	assert.Equal(t, "true", recipe.Rules[0].analyzer.Flags.Lookup("update-comments").Value.String())
+
+	// Template paths are relative to the recipe.
+	assert.Equal(t, filepath.Join(dir, "rules", "open.go"), recipe.Rules[1].analyzer.Flags.Lookup("template").Value.String())
+
+	assert.Equal(t, "replacetype", recipe.Rules[2].analyzer.Name)
+	assert.Equal(t, "newer", recipe.Rules[2].analyzer.Flags.Lookup("replacement-package-name").Value.String())
+}
+
+func TestLoadRecipe_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		recipe string
+		expErr string
+	}{{
+		name:   "no rules",
+		recipe: "rules: []",
+		expErr: "no rules",
+	}, {
+		name:   "unknown field",
+		recipe: "rule: []",
+		expErr: "field rule not found",
+	}, {
+		name: "no name",
+		recipe: `
+rules:
+  - replacecall:
+      func: example.com/old.F
+      replacement: G()`,
+		expErr: "rule 1 has no name",
+	}, {
+		name: "duplicate name",
+		recipe: `
+rules:
+  - name: f
+    replacecall: {func: example.com/old.F, replacement: G()}
+  - name: f
+    replacecall: {func: example.com/old.H, replacement: G()}`,
+		expErr: "there is more than one rule called f",
+	}, {
+		name: "no kind",
+		recipe: `
+rules:
+  - name: f`,
+		expErr: "rule f: either replacecall or replacetype is required",
+	}, {
+		name: "both kinds",
+		recipe: `
+rules:
+  - name: f
+    replacecall: {func: example.com/old.F, replacement: G()}
+    replacetype: {type: example.com/old.T, replacement: example.com/newer.T}`,
+		expErr: "rule f: replacecall cannot be combined with replacetype",
+	}, {
+		name: "unknown option",
+		recipe: `
+rules:
+  - name: f
+    replacecall: {function: example.com/old.F, replacement: G()}`,
+		expErr: "rule f: replacecall: no such flag -function",
+	}, {
+		name: "no replacement",
+		recipe: `
+rules:
+  - name: f
+    replacecall: {func: example.com/old.F}`,
+		expErr: "rule f: either template or both func and replacement (or stmt-replacement) are required",
+	}, {
+		name: "bad func",
+		recipe: `
+rules:
+  - name: f
+    replacecall: {func: F, replacement: G()}`,
+		expErr: "rule f: func: invalid spec",
+	}, {
+		name: "bad replacement",
+		recipe: `
+rules:
+  - name: f
+    replacecall: {func: example.com/old.F, replacement: G($arg)}`,
+		expErr: "rule f: invalid replacement",
+	}, {
+		name: "pattern with update-comments",
+		recipe: `
+rules:
+  - name: f
+    replacecall: {func: example.com/old.*, replacement: G(), update-comments: true}`,
+		expErr: "rule f: update-comments cannot be used with a pattern",
+	}, {
+		name: "type pattern",
+		recipe: `
+rules:
+  - name: t
+    replacetype: {type: example.com/old.*, replacement: example.com/newer.T}`,
+		expErr: "rule t: type and replacement must each name one type, not a pattern",
+	}, {
+		name: "bad shape",
+		recipe: `
+rules:
+  - name: t
+    replacetype: {type: example.com/old.T, replacement: example.com/newer.T, shape: sideways}`,
+		expErr: `rule t: unknown pointer shape "sideways"`,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "recipe.yaml")
+			err := os.WriteFile(path, []byte(tc.recipe), 0o644)
+			require.NoError(t, err)
+
+			_, err = LoadRecipe(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr)
+		})
+	}
+}
@@ -5,7 +5,12 @@ import (
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
 	"golang.org/x/tools/go/analysis"
@@ -15,31 +20,70 @@ import (
 
 func NewFuncReplacer() *analysis.Analyzer {
 	var flags struct {
-		function    string
-		replacement string
+		function       string
+		replacement    string
+		stmt           string
+		value          string
+		template       string
+		updateComments bool
 	}
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	flagSet.StringVar(&flags.function, "func", "", "The function to replace. Format is 'github.com/package/path.FunctionName'")
+	flagSet.StringVar(&flags.function, "func", "", "The function to replace. Format is 'github.com/package/path.FunctionName', which may contain the wildcards * and ?")
 	flagSet.StringVar(&flags.replacement, "replacement", "", "The replacement string. Placeholders are available (like $arg0).")
+	flagSet.StringVar(&flags.stmt, "stmt-replacement", "", "A replacement for the whole statement enclosing the call. $lhs and $return(...) are available in addition to the usual placeholders.")
+	flagSet.StringVar(&flags.value, "value-replacement", "", "A replacement for references to the function that aren't calls, like function values and method values. If empty, those references are reported.")
+	flagSet.StringVar(&flags.template, "template", "", "A Go file declaring before and after functions. Takes the place of func and replacement.")
+	flagSet.BoolVar(&flags.updateComments, "update-comments", false, "Also rewrite doc links to the function in comments, and report other mentions of it.")
+
+	// The template is the same for every package, so only load it once.
+	loadTemplate := sync.OnceValues(func() (Template, error) {
+		return LoadTemplate(flags.template)
+	})
 
 	return &analysis.Analyzer{
 		Name:  "replacecall",
 		Doc:   "Replace a function call with something else.",
 		Flags: *flagSet,
 		Run: func(pass *analysis.Pass) (interface{}, error) {
-			if flags.function == "" {
+			function, replacement := flags.function, flags.replacement
+			if flags.template != "" {
+				if function != "" || replacement != "" {
+					return nil, errors.New("template cannot be combined with func or replacement")
+				}
+
+				t, err := loadTemplate()
+				if err != nil {
+					return nil, err
+				}
+
+				function, replacement = t.Func, t.Replacement
+			}
+
+			if function == "" {
 				return nil, errors.New("func must be provided")
 			}
 
 			importer := &analyzeutil.Importer{}
 			inspector := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 
-			spec, err := ParseSymbolSpec(flags.function)
+			spec, err := ParseSymbolSpec(function)
 			if err != nil {
 				return nil, fmt.Errorf("error parsing func: %w", err)
 			}
 
-			r, err := parseReplacement(flags.replacement)
+			var r parsedReplacement
+			switch {
+			case flags.stmt != "" && replacement != "":
+				return nil, errors.New("stmt-replacement cannot be combined with replacement")
+			case flags.stmt != "":
+				r, err = parseStmtReplacement(flags.stmt)
+			default:
+				r, err = parseReplacement(replacement)
+			}
+			if err != nil {
+				return nil, err
+			}
+			err = r.checkMatches(spec)
 			if err != nil {
 				return nil, err
 			}
@@ -49,6 +93,44 @@ func NewFuncReplacer() *analysis.Analyzer {
 				return nil, err
 			}
 
+			var valueReplacement *parsedReplacement
+			if flags.value != "" {
+				vr, err := parseValueReplacement(flags.value)
+				if err != nil {
+					return nil, err
+				}
+				err = vr.checkMatches(spec)
+				if err != nil {
+					return nil, err
+				}
+				valueReplacement = &vr
+			}
+
+			err = doValueReplacement(pass, spec, inspector, importer, valueReplacement)
+			if err != nil {
+				return nil, err
+			}
+
+			if flags.updateComments {
+				if spec.IsPattern() {
+					return nil, errors.New("update-comments cannot be used with a pattern")
+				}
+
+				cu := &commentUpdater{
+					pass:     pass,
+					importer: importer,
+					spec:     spec,
+				}
+				if to, ok := r.linkTarget(spec); ok {
+					cu.to = &to
+				}
+
+				err = cu.run()
+				if err != nil {
+					return nil, err
+				}
+			}
+
 			importer.Rewrite(pass)
 
 			return nil, nil
@@ -66,52 +148,513 @@ func doFunctionReplacement(
 	importer *analyzeutil.Importer,
 	r parsedReplacement,
 ) error {
+	h := newHoister(pass)
+
 	var err error
 	inspector.WithStack(
 		[]ast.Node{&ast.CallExpr{}},
 		func(n ast.Node, push bool, stack []ast.Node) bool {
-			if !push {
+			if !push || err != nil {
 				return false
 			}
 
 			callExpr := n.(*ast.CallExpr)
+			obj := callee(pass.TypesInfo, callExpr)
+			matches, ok := parsedFunc.match(obj)
+			if !ok {
+				return true
+			}
 
-			var name *ast.Ident
-			switch fn := callExpr.Fun.(type) {
-			case *ast.Ident:
-				name = fn
-			case *ast.SelectorExpr:
-				name = fn.Sel
-			default:
+			file := stack[0].(*ast.File)
+			site := callSite{
+				fset:     pass.Fset,
+				call:     callExpr,
+				pkgNames: r.pkgNames(importer, pass.Fset, file),
+				obj:      obj,
+				matches:  matches,
+			}
+
+			if r.stmt {
+				var replaced bool
+				replaced, err = replaceStmt(pass, importer, h, r, site, stack)
+				if replaced {
+					r.addImports(importer, pass.Fset, file)
+
+					// The statement was replaced wholesale, so there's nothing left to replace inside it
+					// but the calls copied along with the arguments.
+					reportCopied(pass, parsedFunc, callExpr)
+					return false
+				}
+				return err == nil
+			}
+
+			site.src, err = pass.ReadFile(pass.Fset.File(callExpr.Pos()).Name())
+			if err != nil {
+				return false
+			}
+
+			problem := r.evalOrderProblem(pass.Fset, pass.TypesInfo, callExpr)
+			if problem == "" {
+				var replacement string
+				replacement, err = r.print(site)
+				if err != nil {
+					return false
+				}
+				replacement, err = analyzeutil.FormatExpr(pass, callExpr.Pos(), replacement)
+				if err != nil {
+					return false
+				}
+
+				err = analyzeutil.ReplaceNode(pass, callExpr, replacement)
+				if err != nil {
+					return false
+				}
+
+				r.addImports(importer, pass.Fset, file)
 				return true
 			}
 
-			obj := pass.TypesInfo.ObjectOf(name)
+			// The arguments have to be moved into variables before the statement, in the order the
+			// call evaluated them.
+			stmt, ok := enclosingStmt(callExpr, stack)
+			var decls []string
+			qualifier, addQualified := importer.DeferredQualifier(pass.Fset, file, pass.Pkg)
+			if ok {
+				site.hoisted, decls, ok = h.hoist(r, callExpr, stmt, stack, site.src, qualifier)
+			}
+			if !ok {
+				reportEvalOrder(pass, callExpr, problem)
+				return true
+			}
 
-			switch {
-			case parsedFunc.matchesTopLevelSymbol(obj):
-				for imp := range r.imports() {
-					importer.Add(pass.Fset, stack[0].(*ast.File), imp.alias, imp.path)
+			var replacement string
+			replacement, err = r.print(site)
+			if err != nil {
+				return false
+			}
+			replacement, err = analyzeutil.FormatExpr(pass, callExpr.Pos(), replacement)
+			if err != nil {
+				return false
+			}
+
+			var indent string
+			indent, err = analyzeutil.Indentation(pass, stmt.Pos())
+			if err != nil {
+				return false
+			}
+
+			tf := pass.Fset.File(callExpr.Pos())
+			beforeCall := string(site.src[tf.Offset(stmt.Pos()):tf.Offset(callExpr.Pos())])
+			err = analyzeutil.ReplaceRange(pass, stmt.Pos(), callExpr.End(), writeDecls(decls, indent)+beforeCall+replacement)
+			if err != nil {
+				return false
+			}
+			r.addImports(importer, pass.Fset, file)
+			addQualified()
+
+			// The arguments were copied into the declarations as they were, so the calls in them can't be
+			// replaced in this run.
+			reportCopied(pass, parsedFunc, callExpr)
+			return false
+		},
+	)
+
+	return err
+}
+
+// reportCopied reports the calls to replace inside the arguments of call, which were copied into the
+// replacement of call as they were. Running the replacement again replaces them.
+func reportCopied(pass *analysis.Pass, parsedFunc SymbolSpec, call *ast.CallExpr) {
+	for _, arg := range call.Args {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			inner, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if _, ok := parsedFunc.match(callee(pass.TypesInfo, inner)); ok {
+				pass.Report(analysis.Diagnostic{
+					Pos:     inner.Pos(),
+					End:     inner.End(),
+					Message: "not replaced: this call was copied into the replacement of the call around it; run the replacement again to replace it",
+				})
+			}
+			return true
+		})
+	}
+}
+
+// doValueReplacement replaces references to the function that aren't calls, like function values
+// (http.HandleFunc("/", pkg.Handler)), method values (f := obj.Method) and method expressions
+// (T.Method). If r is nil, each reference is reported as unhandled instead.
+func doValueReplacement(
+	pass *analysis.Pass,
+	parsedFunc SymbolSpec,
+	inspector *inspector.Inspector,
+	importer *analyzeutil.Importer,
+	r *parsedReplacement,
+) error {
+	var err error
+	inspector.WithStack(
+		[]ast.Node{&ast.SelectorExpr{}, &ast.Ident{}},
+		func(n ast.Node, push bool, stack []ast.Node) bool {
+			if !push || err != nil {
+				return false
+			}
+
+			var name *ast.Ident
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				name = n.Sel
+			case *ast.Ident:
+				// The selector expression containing this identifier is visited on its own.
+				if sel, ok := stack[len(stack)-2].(*ast.SelectorExpr); ok && sel.Sel == n {
+					return false
 				}
-			case parsedFunc.matchesFuncReceiver(obj):
+				name = n
 			default:
+				panic("unreachable")
+			}
+
+			// Uses doesn't include the name in the function's declaration, which we want to leave be.
+			obj := pass.TypesInfo.Uses[name]
+			matches, ok := parsedFunc.match(obj)
+			if !ok {
 				return true
 			}
 
+			ref := n.(ast.Expr)
+			if isCalled(ref, stack) {
+				// Calls are handled by doFunctionReplacement.
+				return false
+			}
+
+			if r == nil {
+				pass.Report(analysis.Diagnostic{
+					Pos:     ref.Pos(),
+					End:     ref.End(),
+					Message: "unhandled reference to " + name.Name + ": " + referenceKind(pass.TypesInfo, ref),
+				})
+				return false
+			}
+
+			file := stack[0].(*ast.File)
+
 			var replacement string
-			replacement, err = r.print(pass.Fset, callExpr)
+			replacement, err = r.print(callSite{
+				fset:     pass.Fset,
+				ref:      ref,
+				pkgNames: r.pkgNames(importer, pass.Fset, file),
+				obj:      obj,
+				matches:  matches,
+			})
 			if err != nil {
 				return false
 			}
 
-			err = analyzeutil.ReplaceNode(pass, callExpr, replacement)
+			err = analyzeutil.ReplaceNode(pass, ref, replacement)
 			if err != nil {
 				return false
 			}
 
-			return true
+			r.addImports(importer, pass.Fset, file)
+			return false
 		},
 	)
 
 	return err
 }
+
+// isCalled reports whether ref, the last node in stack, is the function of a call expression.
+func isCalled(ref ast.Expr, stack []ast.Node) bool {
+	child := ast.Node(ref)
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch parent := stack[i].(type) {
+		case *ast.ParenExpr:
+			child = parent
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			if x, _ := unpackIndex(parent.(ast.Expr)); x != child {
+				return false
+			}
+			child = parent
+		case *ast.CallExpr:
+			return parent.Fun == child
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+func referenceKind(info *types.Info, ref ast.Expr) string {
+	if sel, ok := ref.(*ast.SelectorExpr); ok {
+		if selection, ok := info.Selections[sel]; ok {
+			switch selection.Kind() {
+			case types.MethodVal:
+				return "method value"
+			case types.MethodExpr:
+				return "method expression"
+			}
+		}
+	}
+
+	return "function value"
+}
+
+// replaceStmt replaces the statement enclosing the call at site with the (possibly multi-statement)
+// replacement. The rest of site is filled in from the statement. It reports whether the statement was
+// replaced.
+func replaceStmt(
+	pass *analysis.Pass,
+	importer *analyzeutil.Importer,
+	h *hoister,
+	r parsedReplacement,
+	site callSite,
+	stack []ast.Node,
+) (bool, error) {
+	call := site.call
+	stmt, ok := enclosingStmt(call, stack)
+	if !ok {
+		pass.Report(analysis.Diagnostic{
+			Pos:     call.Pos(),
+			End:     call.End(),
+			Message: "cannot replace the statement enclosing this call; it must be an expression or assignment statement in a block",
+		})
+		return false, nil
+	}
+
+	src, err := pass.ReadFile(pass.Fset.File(call.Pos()).Name())
+	if err != nil {
+		return false, err
+	}
+
+	file := stack[0].(*ast.File)
+	site.src = src
+	site.stmt = stmt
+	site.results = enclosingResults(pass.TypesInfo, stack)
+	// The imports the replacement needs are only added once it's made.
+	var addQualified func()
+	site.qualifier, addQualified = importer.DeferredQualifier(pass.Fset, file, pass.Pkg)
+
+	var decls []string
+	if problem := r.evalOrderProblem(pass.Fset, pass.TypesInfo, call); problem != "" {
+		site.hoisted, decls, ok = h.hoist(r, call, stmt, stack, src, site.qualifier)
+		if !ok {
+			reportEvalOrder(pass, call, problem)
+			return false, nil
+		}
+	}
+
+	replacement, err := r.print(site)
+	if err != nil {
+		return false, err
+	}
+	replacement = writeDecls(decls, "") + replacement
+
+	if assign, ok := stmt.(*ast.AssignStmt); ok && assign.Tok == token.ASSIGN {
+		replacement, err = assignLHS(pass, site, assign, replacement)
+		if err != nil {
+			pass.Report(analysis.Diagnostic{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				Message: "cannot replace: " + err.Error(),
+			})
+			return false, nil
+		}
+	}
+
+	indent, err := analyzeutil.Indentation(pass, stmt.Pos())
+	if err != nil {
+		return false, err
+	}
+
+	replacement, err = analyzeutil.FormatStmts(replacement, indent)
+	if err != nil {
+		return false, fmt.Errorf("statement replacement is not valid Go: %w", err)
+	}
+
+	err = analyzeutil.ReplaceNode(pass, stmt, replacement)
+	if err != nil {
+		return false, err
+	}
+
+	addQualified()
+	return true, nil
+}
+
+// assignLHS adapts a statement replacement to a call whose result is assigned with = rather than
+// declared with :=. A := after $lhs in the replacement would declare new variables that shadow the ones
+// being assigned, so it becomes =, and the other variables it declared get a var declaration of their
+// own unless they're already in scope.
+func assignLHS(pass *analysis.Pass, site callSite, assign *ast.AssignStmt, replacement string) (string, error) {
+	const header = "package p\n\nfunc _() {\n"
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", header+replacement+"\n}\n", 0)
+	if err != nil {
+		// It's reported when the replacement is formatted.
+		return replacement, nil
+	}
+
+	lhs := make([]string, 0, len(assign.Lhs))
+	for _, e := range assign.Lhs {
+		formatted, err := analyzeutil.FormatNode(site.fset, e)
+		if err != nil {
+			return "", err
+		}
+		lhs = append(lhs, formatted)
+	}
+
+	var define *ast.AssignStmt
+	ast.Inspect(f.Decls[0].(*ast.FuncDecl).Body, func(n ast.Node) bool {
+		a, ok := n.(*ast.AssignStmt)
+		if define != nil || !ok || a.Tok != token.DEFINE || len(a.Lhs) < len(lhs) {
+			return define == nil
+		}
+		for i, e := range lhs {
+			formatted, err := analyzeutil.FormatNode(fset, a.Lhs[i])
+			if err != nil || formatted != e {
+				return true
+			}
+		}
+		define = a
+		return false
+	})
+	if define == nil {
+		return replacement, nil
+	}
+
+	var decls []string
+	var rhs []types.Type
+	for i, e := range define.Lhs[len(lhs):] {
+		id, ok := e.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			continue
+		}
+		_, obj := pass.Pkg.Scope().Innermost(assign.Pos()).LookupParent(id.Name, assign.Pos())
+		if _, ok := obj.(*types.Var); ok {
+			continue
+		}
+
+		if rhs == nil {
+			rhs, err = rhsTypes(fset, pass.Pkg, assign.Pos(), define)
+			if err != nil {
+				return "", fmt.Errorf("%s is assigned with =, and the types of the variables the replacement declares can't be worked out: %w", strings.Join(lhs, ", "), err)
+			}
+		}
+		decls = append(decls, "var "+id.Name+" "+types.TypeString(rhs[len(lhs)+i], site.qualifier))
+	}
+
+	// Edit the replacement from the end so that the offsets stay valid.
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset - len(header) }
+	replacement = replacement[:offset(define.TokPos)] + "=" + replacement[offset(define.TokPos)+len(":="):]
+	if len(decls) > 0 {
+		start := offset(define.Pos())
+		replacement = replacement[:start] + writeDecls(decls, "") + replacement[start:]
+	}
+	return replacement, nil
+}
+
+// rhsTypes type-checks the right-hand side of assign as if it were at pos in pkg and returns the type
+// of each value it assigns.
+func rhsTypes(fset *token.FileSet, pkg *types.Package, pos token.Pos, assign *ast.AssignStmt) ([]types.Type, error) {
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	for _, e := range assign.Rhs {
+		err := types.CheckExpr(fset, pkg, pos, e, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(assign.Rhs) == 1 {
+		if tuple, ok := info.Types[assign.Rhs[0]].Type.(*types.Tuple); ok {
+			res := make([]types.Type, 0, tuple.Len())
+			for i := range tuple.Len() {
+				res = append(res, tuple.At(i).Type())
+			}
+			return res, nil
+		}
+	}
+
+	res := make([]types.Type, 0, len(assign.Rhs))
+	for _, e := range assign.Rhs {
+		res = append(res, types.Default(info.Types[e].Type))
+	}
+	return res, nil
+}
+
+// reportEvalOrder reports a call that can't be replaced because the replacement evaluates its
+// arguments differently, and they can't be moved into variables first.
+func reportEvalOrder(pass *analysis.Pass, call *ast.CallExpr, problem string) {
+	pass.Report(analysis.Diagnostic{
+		Pos:     call.Pos(),
+		End:     call.End(),
+		Message: "cannot replace: the replacement " + problem + "; move the arguments into variables first",
+	})
+}
+
+// enclosingStmt returns the statement call is the whole right-hand side of, as long as that statement
+// sits in a statement list where it can be expanded into several statements.
+func enclosingStmt(call *ast.CallExpr, stack []ast.Node) (ast.Stmt, bool) {
+	if len(stack) < 3 {
+		return nil, false
+	}
+
+	var stmt ast.Stmt
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.ExprStmt:
+		stmt = parent
+	case *ast.AssignStmt:
+		if len(parent.Rhs) != 1 || parent.Rhs[0] != call {
+			return nil, false
+		}
+		stmt = parent
+	default:
+		return nil, false
+	}
+
+	switch stack[len(stack)-3].(type) {
+	case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+		return stmt, true
+	default:
+		return nil, false
+	}
+}
+
+// enclosingResults returns the results of the innermost function in stack.
+func enclosingResults(info *types.Info, stack []ast.Node) *types.Tuple {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch fn := stack[i].(type) {
+		case *ast.FuncLit:
+			if sig, ok := info.TypeOf(fn).(*types.Signature); ok {
+				return sig.Results()
+			}
+			return nil
+		case *ast.FuncDecl:
+			if obj, ok := info.Defs[fn.Name].(*types.Func); ok {
+				return obj.Signature().Results()
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// callee returns the object for the function or method called by call, if any.
+func callee(info *types.Info, call *ast.CallExpr) types.Object {
+	fun := ast.Unparen(call.Fun)
+	if x, _ := unpackIndex(fun); x != nil {
+		// An explicit instantiation, like F[int](x).
+		fun = ast.Unparen(x)
+	}
+
+	switch fn := fun.(type) {
+	case *ast.Ident:
+		return info.ObjectOf(fn)
+	case *ast.SelectorExpr:
+		return info.ObjectOf(fn.Sel)
+	default:
+		return nil
+	}
+}
@@ -1,8 +1,11 @@
 package replace
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/tools/go/analysis/analysistest"
 )
 
@@ -21,3 +24,357 @@ func TestReplace_Args(t *testing.T) {
 
 	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./args")
 }
+
+func TestReplace_ArgComments(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/argcomments.ReplaceMe")
+	a.Flags.Set("replacement", "Replaced($arg1, $arg0)")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./argcomments")
+}
+
+func TestReplace_EvalOrder(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/evalorder.ReplaceMe")
+	a.Flags.Set("replacement", "Replaced($arg1, $arg0)")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./evalorder")
+}
+
+func TestReplace_EvalOrderTwice(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/evalorder/twice.Double")
+	a.Flags.Set("replacement", "$arg0 + $arg0")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./evalorder/twice")
+}
+
+func TestReplace_EvalOrderUnused(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/evalorder/unused.Get")
+	a.Flags.Set("replacement", "Lookup($arg0)")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./evalorder/unused")
+}
+
+func TestReplace_EvalOrderTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+	}{{
+		name: "typed",
+		flags: map[string]string{
+			"func":        "test.com/module/evalorder/types/typed.Set",
+			"replacement": "typed.Replaced($arg1, $arg0)",
+		},
+	}, {
+		name: "novalue",
+		flags: map[string]string{
+			"func":        "test.com/module/evalorder/types/novalue.Pair",
+			"replacement": "Zero()",
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewFuncReplacer()
+			for name, value := range tc.flags {
+				a.Flags.Set(name, value)
+			}
+
+			analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./evalorder/types/"+tc.name+"/...")
+		})
+	}
+}
+
+func TestReplace_EvalOrderImports(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/evalorder/imports.F")
+	a.Flags.Set("replacement", "$pkg(test.com/module/evalorder/imports/newer,newer).G($arg1, $arg0)")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./evalorder/imports")
+}
+
+func TestReplace_EvalOrderStmt(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/evalorder/stmt.MustOpen")
+	a.Flags.Set("stmt-replacement", "$lhs, err := Open($arg1, $arg0); if err != nil { $return(err) }")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./evalorder/stmt")
+}
+
+func TestReplace_Template(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("template", filepath.Join(analysistest.TestData(), "template", "rule", "rule.go"))
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./template")
+}
+
+func TestReplace_Stmt(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/stmt.MustOpen")
+	a.Flags.Set("stmt-replacement", "$lhs, err := Open($arg0); if err != nil { $return(err) }")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./stmt")
+}
+
+func TestReplace_StmtRejected(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/stmt/rejected.MustOpen")
+	a.Flags.Set("stmt-replacement", "$lhs, err := $pkg(test.com/module/stmt/rejected/newer,newer).Open($arg0); if err != nil { $return(err) }")
+
+	// The statements aren't replaced, so the file keeps the imports it had.
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./stmt/rejected")
+}
+
+func TestDeleteCall(t *testing.T) {
+	a := NewCallDeleter()
+	a.Flags.Set("func", "test.com/module/deletecall/metrics.Register")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./deletecall")
+}
+
+func TestDeleteCall_Method(t *testing.T) {
+	a := NewCallDeleter()
+	a.Flags.Set("func", "test.com/module/deletecall/metrics.Tracer.Stop")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./deletecall/tracing")
+}
+
+func TestReplace_Value(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/value.ReplaceMe")
+	a.Flags.Set("replacement", "Replaced($arg0)")
+	a.Flags.Set("value-replacement", "Replaced")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./value")
+}
+
+func TestReplace_GenericCall(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/value/generic.Old*")
+	a.Flags.Set("replacement", "New$match0($args)")
+	a.Flags.Set("value-replacement", "New$match0")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./value/generic")
+}
+
+func TestReplace_UnhandledValue(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/value/method.T.M")
+	a.Flags.Set("replacement", "$recv.M()")
+
+	analysistest.Run(t, analysistest.TestData(), a, "./value/method")
+}
+
+func TestReplaceType_Generic(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/generic/old.Map")
+	a.Flags.Set("replacement", "test.com/module/generic/newer.Map[$T1, $T0]")
+	a.Flags.Set("replacement-package-name", "newer")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./generic")
+}
+
+func TestReplaceType_GenericConstraints(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/generic/old.Map")
+	a.Flags.Set("replacement", "test.com/module/generic/newer.Map[$T0, $T1]")
+	a.Flags.Set("replacement-package-name", "newer")
+
+	analysistest.Run(t, analysistest.TestData(), a, "./generic/bad")
+}
+
+func TestReplaceType_PointerToValue(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/shape/old.Client")
+	a.Flags.Set("replacement", "test.com/module/shape/newer.Client")
+	a.Flags.Set("replacement-package-name", "newer")
+	a.Flags.Set("shape", "ptr-to-value")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./shape/ptrtovalue")
+}
+
+func TestReplaceType_ValueToPointer(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/shape/old.Client")
+	a.Flags.Set("replacement", "test.com/module/shape/newer.Client")
+	a.Flags.Set("replacement-package-name", "newer")
+	a.Flags.Set("shape", "value-to-ptr")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./shape/valuetoptr")
+}
+
+func TestInsertConversion(t *testing.T) {
+	a := NewConversionInserter()
+	a.Flags.Set("type", "test.com/module/convert/newer.ID")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./convert")
+}
+
+func TestReplaceType_Embedded(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/embed/old.Logger")
+	a.Flags.Set("replacement", "test.com/module/embed/newer.Log")
+	a.Flags.Set("replacement-package-name", "newer")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./embed")
+}
+
+func TestReplaceType_Only(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/scope/old.ID")
+	a.Flags.Set("replacement", "test.com/module/scope/newer.ID")
+	a.Flags.Set("replacement-package-name", "newer")
+	a.Flags.Set("only", "fields,conversions")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./scope")
+}
+
+func TestReplaceType_Within(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/scope/old.ID")
+	a.Flags.Set("replacement", "test.com/module/scope/newer.ID")
+	a.Flags.Set("replacement-package-name", "newer")
+	a.Flags.Set("within", "test.com/module/scope/within.Lookup")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./scope/within")
+}
+
+func TestReplaceType_UpdateComments(t *testing.T) {
+	a := NewTypeReplacer()
+	a.Flags.Set("type", "test.com/module/comments/old.Client")
+	a.Flags.Set("replacement", "test.com/module/comments/newer.Client")
+	a.Flags.Set("replacement-package-name", "newer")
+	a.Flags.Set("update-comments", "true")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./comments/typeuse")
+}
+
+func TestReplace_UpdateComments(t *testing.T) {
+	a := NewFuncReplacer()
+	a.Flags.Set("func", "test.com/module/comments/old.Dial")
+	a.Flags.Set("replacement", "$pkg(test.com/module/comments/newer,newer).Dial($arg0)")
+	a.Flags.Set("update-comments", "true")
+
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./comments/funcuse", "./comments/old")
+}
+
+// TestReplace_Metavariables replaces calls in testdata/metavars with a replacement using each of the
+// metavariables.
+func TestReplace_Metavariables(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+	}{{
+		name: "args",
+		flags: map[string]string{
+			"func":        "test.com/module/metavars/old.Concat",
+			"replacement": "old.Join($arg1, $arg0)",
+		},
+	}, {
+		name: "recv",
+		flags: map[string]string{
+			"func":        "test.com/module/metavars/old.Client.Get",
+			"replacement": "$recv.Fetch($arg0)",
+		},
+	}, {
+		name: "recvdot",
+		flags: map[string]string{
+			"func":        "test.com/module/metavars/recvdot.New",
+			"replacement": "$recvdotNewWithDefaults()",
+		},
+	}, {
+		name: "pkg",
+		flags: map[string]string{
+			"func":        "test.com/module/metavars/old.Concat",
+			"replacement": "$pkg(test.com/module/metavars/newer,newer).Join($arg0, $arg1)",
+		},
+	}, {
+		name: "pkgalias",
+		flags: map[string]string{
+			"func":        "test.com/module/metavars/old.Concat",
+			"replacement": "$pkg(test.com/module/metavars/newer,newer,nw).Join($arg0, $arg1)",
+		},
+	}, {
+		name: "dollar",
+		flags: map[string]string{
+			"func":        "test.com/module/metavars/old.Price",
+			"replacement": `$pkg(fmt,fmt).Sprintf("$$%d", $arg0)`,
+		},
+	}, {
+		name: "stmt",
+		flags: map[string]string{
+			"func":             "test.com/module/metavars/old.MustAtoi",
+			"stmt-replacement": `$lhs, err := old.Atoi($arg0); if err != nil { $return($pkg(fmt,fmt).Errorf("parsing %q: %w", $arg0, err)) }`,
+		},
+	}, {
+		name: "value",
+		flags: map[string]string{
+			"func":              "test.com/module/metavars/old.Concat",
+			"replacement":       "$pkg(test.com/module/metavars/newer,newer).Join($arg0, $arg1)",
+			"value-replacement": "$pkg(test.com/module/metavars/newer,newer).Join",
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewFuncReplacer()
+			for name, value := range tc.flags {
+				a.Flags.Set(name, value)
+			}
+
+			analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./metavars/"+tc.name+"/...")
+		})
+	}
+}
+
+func TestReplace_Glob(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+	}{{
+		name: "asserts",
+		flags: map[string]string{
+			"func":              "test.com/module/glob/assert.*f",
+			"replacement":       "assert.$match0($args)",
+			"value-replacement": "assert.$match0",
+		},
+	}, {
+		name: "methods",
+		flags: map[string]string{
+			"func":        "test.com/module/glob/old.*.Do",
+			"replacement": "old.$name$recvtype($recv, $args)",
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewFuncReplacer()
+			for name, value := range tc.flags {
+				a.Flags.Set(name, value)
+			}
+
+			analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), a, "./glob/"+tc.name)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	a := NewChecker()
+	a.Flags.Set("rules", filepath.Join(analysistest.TestData(), "check", "recipe.yaml"))
+
+	// Each fix carries the edits to the imports it needs, so they overlap and can't be applied together.
+	results := analysistest.Run(t, analysistest.TestData(), a, "./check/use")
+	require.Len(t, results, 1)
+
+	diags := results[0].Diagnostics
+	require.Len(t, diags, 3)
+	for _, d := range diags {
+		require.Len(t, d.SuggestedFixes, 1)
+
+		edits := d.SuggestedFixes[0].TextEdits
+		require.Len(t, edits, 2)
+		assert.Contains(t, string(edits[1].NewText), `"test.com/module/check/newer"`)
+	}
+}
@@ -6,7 +6,11 @@ import (
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/types"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
 	"golang.org/x/tools/go/analysis"
@@ -20,12 +24,20 @@ func NewTypeReplacer() *analysis.Analyzer {
 		replacement            string
 		replacementPackageName string
 		importAlias            string
+		shape                  string
+		only                   string
+		within                 string
+		updateComments         bool
 	}
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	flagSet.StringVar(&flags.typeName, "type", "", "The type to replace. Format is 'github.com/package/path.TypeName'")
 	flagSet.StringVar(&flags.replacement, "replacement", "", "The type to replace --type with; takes the same form as --type.")
 	flagSet.StringVar(&flags.replacementPackageName, "replacement-package-name", "", "The replacement package name to use.")
 	flagSet.StringVar(&flags.importAlias, "import-alias", "", "An optional alias to use when importing the replacement type.")
+	flagSet.StringVar(&flags.shape, "shape", "", "How the indirection changes: ptr-to-value replaces *A with B, value-to-ptr replaces A with *B.")
+	flagSet.StringVar(&flags.only, "only", "", "Only replace references in these (comma-separated) positions: fields, params, results, vars or conversions.")
+	flagSet.StringVar(&flags.within, "within", "", "Only replace references inside the declaration of this symbol, or inside these packages if it ends in /...")
+	flagSet.BoolVar(&flags.updateComments, "update-comments", false, "Also rewrite doc links to the type in comments, and report other mentions of it.")
 
 	return &analysis.Analyzer{
 		Name:  "replacetype",
@@ -52,6 +64,19 @@ func NewTypeReplacer() *analysis.Analyzer {
 			if err != nil {
 				return nil, fmt.Errorf("error parsing replacement: %w", err)
 			}
+			if typeSpec.IsPattern() || replacementSpec.IsPattern() {
+				return nil, errors.New("type and replacement must each name one type, not a pattern")
+			}
+
+			shape, err := ParsePointerShape(flags.shape)
+			if err != nil {
+				return nil, err
+			}
+
+			scope, err := ParseTypeScope(flags.only, flags.within)
+			if err != nil {
+				return nil, err
+			}
 
 			err = doTypeReplacement(
 				pass,
@@ -59,9 +84,32 @@ func NewTypeReplacer() *analysis.Analyzer {
 				replacementSpec,
 				flags.replacementPackageName,
 				flags.importAlias,
+				shape,
+				scope,
 				inspector,
 				importer,
 			)
+			if err != nil {
+				return nil, err
+			}
+
+			if flags.updateComments && scope.includesComments(pass.Pkg.Path()) {
+				cu := &commentUpdater{
+					pass:     pass,
+					importer: importer,
+					spec:     typeSpec,
+					isType:   true,
+					to: &linkTarget{
+						path:    replacementSpec.Pkg,
+						pkgName: flags.replacementPackageName,
+						name:    replacementSpec.name,
+					},
+				}
+				err = cu.run()
+				if err != nil {
+					return nil, err
+				}
+			}
 
 			importer.Rewrite(pass)
 
@@ -79,21 +127,92 @@ func doTypeReplacement(
 	replacement SymbolSpec,
 	importName string,
 	importAlias string,
+	shape PointerShape,
+	scope TypeScope,
 	inspector *inspector.Inspector,
 	importer *analyzeutil.Importer,
 ) error {
+	if spec.typeArgs != "" {
+		return errors.New("type arguments are only supported in the replacement type")
+	}
+
+	if !scope.includesPackage(pass.Pkg.Path()) {
+		return nil
+	}
+
+	tr := &typeReplacer{
+		pass:        pass,
+		spec:        spec,
+		replacement: replacement,
+		importName:  importName,
+		importAlias: importAlias,
+		shape:       shape,
+		scope:       scope,
+		importer:    importer,
+		skip:        make(map[ast.Node]bool),
+	}
+
+	nodeTypes := []ast.Node{&ast.SelectorExpr{}, &ast.Ident{}, &ast.IndexExpr{}, &ast.IndexListExpr{}}
+	if shape != ShapeSame {
+		nodeTypes = append(nodeTypes, &ast.StarExpr{}, &ast.UnaryExpr{}, &ast.CompositeLit{}, &ast.CallExpr{}, &ast.BinaryExpr{})
+	}
+
 	var err error
 	inspector.WithStack(
-		[]ast.Node{&ast.SelectorExpr{}, &ast.Ident{}},
+		nodeTypes,
 		func(n ast.Node, push bool, stack []ast.Node) bool {
-			if !push {
+			if !push || err != nil || tr.skip[n] {
 				return false
 			}
 
-			var name *ast.Ident
+			if id, ok := n.(*ast.Ident); ok && tr.renamesEmbedded() {
+				if field := tr.embeddedField(id); field != nil {
+					// References to the field follow its declaration, whatever the scope says about
+					// the reference itself.
+					if tr.inScopeAt(field.Pos()) {
+						err = tr.replaceEmbeddedFieldRef(id)
+					}
+					return false
+				}
+			}
+
+			if !tr.inScope(n, stack) {
+				return true
+			}
+
+			file := stack[0].(*ast.File)
+
 			switch n := n.(type) {
+			case *ast.StarExpr, *ast.UnaryExpr, *ast.CompositeLit, *ast.CallExpr, *ast.BinaryExpr:
+				var handled bool
+				handled, err = tr.replaceIndirection(file, n, stack)
+				return !handled && err == nil
+			case *ast.IndexExpr, *ast.IndexListExpr:
+				x, indices := unpackIndex(n.(ast.Expr))
+				if !tr.matches(x) {
+					return true
+				}
+
+				if !tr.checkInstantiation(n.(ast.Expr), indices) {
+					return false
+				}
+
+				if replacement.typeArgs == "" {
+					// Keep the type arguments as they are; we'll replace the generic type itself
+					// when we descend into it.
+					return true
+				}
+
+				var replacementStr string
+				replacementStr, err = tr.instantiate(file, indices)
+				if err != nil {
+					return false
+				}
+
+				err = analyzeutil.ReplaceNode(pass, n, replacementStr)
+				return false
 			case *ast.SelectorExpr:
-				name = n.Sel
+				tr.reportLostMethodUse(n)
 			case *ast.Ident:
 				// If we have an identifier, let's make sure we're not the name of a type spec. If
 				// we are, we shouldn't replace this one because our goal isn't to remove the old
@@ -102,38 +221,261 @@ func doTypeReplacement(
 					return true
 				}
 
-				name = n
-			default:
-				panic("unreachable")
 			}
 
-			obj := pass.TypesInfo.ObjectOf(name)
+			if tr.shape != ShapeSame {
+				tr.reportCopy(n.(ast.Expr), stack)
+			}
 
-			switch {
-			case spec.matchesTopLevelSymbol(obj):
+			if !tr.matches(n.(ast.Expr)) {
+				return true
+			}
 
-				var replacementStr string
-				if pass.Pkg.Path() == replacement.Pkg {
-					replacementStr = replacement.name
-				} else {
-					addedName := importer.Add(pass.Fset, stack[0].(*ast.File), importAlias, replacement.Pkg)
-					// Use the import alias if provided, otherwise the name of the import.
-					replacementStr = cmp.Or(addedName, importName) + "." + replacement.name
+			if replacement.typeArgs != "" {
+				if _, ok := stack[len(stack)-2].(*ast.IndexExpr); !ok {
+					if _, ok := stack[len(stack)-2].(*ast.IndexListExpr); !ok {
+						pass.Report(analysis.Diagnostic{
+							Pos:     n.Pos(),
+							End:     n.End(),
+							Message: "cannot replace: the replacement has type arguments but this reference has none",
+						})
+						return false
+					}
 				}
+			}
 
-				err = analyzeutil.ReplaceNode(
-					pass,
-					n,
-					replacementStr,
-				)
-
-				// Whether or not there's an error, there's no need to descend further into a Field.
-				return false
-			default:
-				return true
+			if isEmbedding(n.(ast.Expr), stack) {
+				tr.reportLostMethods(n.(ast.Expr))
 			}
+
+			err = analyzeutil.ReplaceNode(
+				pass,
+				n,
+				tr.typeName(file),
+			)
+
+			// Whether or not there's an error, there's no need to descend further into a Field.
+			return false
 		},
 	)
 
 	return err
 }
+
+type typeReplacer struct {
+	pass        *analysis.Pass
+	spec        SymbolSpec
+	replacement SymbolSpec
+	importName  string
+	importAlias string
+	shape       PointerShape
+	scope       TypeScope
+	importer    *analyzeutil.Importer
+
+	// skip holds nodes that were already replaced as part of an enclosing node.
+	skip map[ast.Node]bool
+}
+
+// matches reports whether e is a reference to the type being replaced.
+func (tr *typeReplacer) matches(e ast.Expr) bool {
+	var name *ast.Ident
+	switch e := ast.Unparen(e).(type) {
+	case *ast.SelectorExpr:
+		name = e.Sel
+	case *ast.Ident:
+		name = e
+	default:
+		return false
+	}
+
+	// Uses rather than ObjectOf, because for the type of an embedded field, Defs holds the field.
+	return tr.spec.matchesTopLevelSymbol(tr.pass.TypesInfo.Uses[name])
+}
+
+// name returns the name of the replacement type as it should be written in file.
+func (tr *typeReplacer) name(file *ast.File) string {
+	if tr.pass.Pkg.Path() == tr.replacement.Pkg {
+		return tr.replacement.name
+	}
+
+	addedName := tr.importer.Add(tr.pass.Fset, file, tr.importAlias, tr.replacement.Pkg)
+	// Use the import alias if provided, otherwise the name of the import.
+	return cmp.Or(addedName, tr.importName) + "." + tr.replacement.name
+}
+
+var typeArgPlaceholder = regexp.MustCompile(`\$T(\d+)`)
+
+// instantiate returns the replacement for an instantiation of the type being replaced with the given
+// type arguments, filling in the replacement's type argument template.
+func (tr *typeReplacer) instantiate(file *ast.File, indices []ast.Expr) (string, error) {
+	args := make([]string, 0, len(indices))
+	for _, idx := range indices {
+		arg, err := tr.text(file, idx)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, arg)
+	}
+
+	var err error
+	typeArgs := typeArgPlaceholder.ReplaceAllStringFunc(tr.replacement.typeArgs, func(m string) string {
+		i, _ := strconv.Atoi(m[2:])
+		if i >= len(args) {
+			err = fmt.Errorf("%s was used but there are only %d type arguments", m, len(args))
+			return m
+		}
+		return args[i]
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tr.name(file) + "[" + typeArgs + "]", nil
+}
+
+// text returns the source text of e with any references to the type being replaced inside of it
+// replaced.
+func (tr *typeReplacer) text(file *ast.File, e ast.Expr) (string, error) {
+	tf := tr.pass.Fset.File(e.Pos())
+	src, err := tr.pass.ReadFile(tf.Name())
+	if err != nil {
+		return "", err
+	}
+
+	sb := &strings.Builder{}
+	curr := tf.Offset(e.Pos())
+	ast.Inspect(e, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+
+		var replacementStr string
+		switch n := n.(type) {
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			x, indices := unpackIndex(n.(ast.Expr))
+			if !tr.matches(x) || tr.replacement.typeArgs == "" {
+				return true
+			}
+			replacementStr, err = tr.instantiate(file, indices)
+		case *ast.SelectorExpr, *ast.Ident:
+			if !tr.matches(n.(ast.Expr)) {
+				return true
+			}
+			replacementStr = tr.typeName(file)
+		default:
+			return true
+		}
+
+		sb.Write(src[curr:tf.Offset(n.Pos())])
+		sb.WriteString(replacementStr)
+		curr = tf.Offset(n.End())
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+	sb.Write(src[curr:tf.Offset(e.End())])
+
+	return sb.String(), nil
+}
+
+// checkInstantiation reports whether the replacement type can be instantiated with the type arguments
+// of n, an instantiation of the type being replaced. If it can't, a diagnostic is reported. The check
+// is only possible when the replacement type's package is a dependency of the package being analyzed;
+// otherwise, it's skipped.
+func (tr *typeReplacer) checkInstantiation(n ast.Expr, indices []ast.Expr) bool {
+	replacementType := lookupType(tr.pass.Pkg, tr.replacement.Pkg, tr.replacement.name)
+	if replacementType == nil {
+		return true
+	}
+
+	named, ok := tr.pass.TypesInfo.TypeOf(n).(*types.Named)
+	if !ok {
+		return true
+	}
+
+	origArgs := named.TypeArgs()
+	if origArgs == nil || origArgs.Len() != len(indices) {
+		return true
+	}
+
+	args, ok := tr.replacement.TypeArgsFor(origArgs)
+	if !ok {
+		return true
+	}
+
+	_, err := types.Instantiate(nil, replacementType.Type(), args, true)
+	if err != nil {
+		tr.pass.Report(analysis.Diagnostic{
+			Pos:     n.Pos(),
+			End:     n.End(),
+			Message: fmt.Sprintf("cannot replace: %s", err),
+		})
+		return false
+	}
+
+	return true
+}
+
+// TypeArgsFor returns the type arguments of the replacement described by s for a reference to the
+// original type with the type arguments orig. It reports false if some of them don't come straight
+// from the original, since there's no telling what they are.
+func (s SymbolSpec) TypeArgsFor(orig *types.TypeList) ([]types.Type, bool) {
+	args := make([]types.Type, 0, orig.Len())
+	if s.typeArgs == "" {
+		for i := range orig.Len() {
+			args = append(args, orig.At(i))
+		}
+		return args, true
+	}
+
+	for _, arg := range strings.Split(s.typeArgs, ",") {
+		m := typeArgPlaceholder.FindStringSubmatch(strings.TrimSpace(arg))
+		if m == nil || m[0] != strings.TrimSpace(arg) {
+			return nil, false
+		}
+
+		i, _ := strconv.Atoi(m[1])
+		if i >= orig.Len() {
+			return nil, false
+		}
+		args = append(args, orig.At(i))
+	}
+	return args, true
+}
+
+// lookupType finds the type called name in the package at path, as long as it's pkg or one of its
+// (transitive) dependencies.
+func lookupType(pkg *types.Package, path, name string) *types.TypeName {
+	seen := make(map[*types.Package]bool)
+	queue := []*types.Package{pkg}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		if p.Path() == path {
+			tn, _ := p.Scope().Lookup(name).(*types.TypeName)
+			return tn
+		}
+
+		queue = append(queue, p.Imports()...)
+	}
+
+	return nil
+}
+
+func unpackIndex(e ast.Expr) (ast.Expr, []ast.Expr) {
+	switch e := e.(type) {
+	case *ast.IndexExpr:
+		return e.X, []ast.Expr{e.Index}
+	case *ast.IndexListExpr:
+		return e.X, e.Indices
+	default:
+		return nil, nil
+	}
+}
@@ -1,151 +1,671 @@
 package replace
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"iter"
+	"regexp"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
 )
 
+// parseStmtReplacement parses a replacement for the statement enclosing a call. In addition to the
+// metavariables available to expressions, statement replacements may use $lhs and $return(...).
+func parseStmtReplacement(replacementStr string) (parsedReplacement, error) {
+	pr, err := parseReplacers(replacementStr)
+	if err != nil {
+		return parsedReplacement{}, err
+	}
+
+	pr.stmt = true
+	err = pr.checkSyntax(replacementStr)
+	if err != nil {
+		return parsedReplacement{}, err
+	}
+
+	return pr, nil
+}
+
 func parseReplacement(replacementStr string) (parsedReplacement, error) {
-	rest := replacementStr
+	pr, err := parseExprReplacement(replacementStr)
+	if err != nil {
+		return parsedReplacement{}, err
+	}
 
-	var pr parsedReplacement
+	err = pr.checkSyntax(replacementStr)
+	if err != nil {
+		return parsedReplacement{}, err
+	}
 
-	for len(rest) > 0 {
-		if rest[0] == '$' {
-			// meta vars start with $
-			rest = rest[1:]
+	return pr, nil
+}
 
-			var metaName string
-			metaName, rest = takeWhile(rest, unicode.IsLetter)
+// parseExprReplacement parses a replacement for an expression, without checking its syntax.
+func parseExprReplacement(replacementStr string) (parsedReplacement, error) {
+	pr, err := parseReplacers(replacementStr)
+	if err != nil {
+		return parsedReplacement{}, err
+	}
 
-			// for now we only support argNNN
-			switch metaName {
-			case "arg":
-				var numStr string
-				numStr, rest = takeWhile(rest, unicode.IsDigit)
+	for i, r := range pr.replacers {
+		switch r.(type) {
+		case lhsReplacer, returnReplacer:
+			return parsedReplacement{}, &TemplateError{
+				Template: replacementStr,
+				Offset:   pr.offsets[i],
+				Msg:      "$lhs and $return are only available in statement replacements",
+			}
+		}
+	}
 
-				idx, err := strconv.Atoi(numStr)
-				if err != nil {
-					return parsedReplacement{}, err
-				}
+	return pr, nil
+}
 
-				pr.replacers = append(pr.replacers, argReplacer{index: idx})
-			case "recvdot":
-				pr.replacers = append(pr.replacers, recvReplacer{})
-			case "recv":
-				pr.replacers = append(pr.replacers, recvReplacer{})
-			case "pkg":
-				var err error
-				rest, err = expectRune(rest, '(')
-				if err != nil {
-					return parsedReplacement{}, err
-				}
+// parseValueReplacement parses a replacement for a reference to a function that isn't called. There
+// are no arguments to refer to in that case, so $argNNN and $args aren't allowed.
+func parseValueReplacement(replacementStr string) (parsedReplacement, error) {
+	pr, err := parseExprReplacement(replacementStr)
+	if err != nil {
+		return parsedReplacement{}, err
+	}
 
-				var pkg string
-				pkg, rest = takeWhile(rest, func(r rune) bool { return r != ',' })
+	for i, r := range pr.replacers {
+		switch r.(type) {
+		case argReplacer, argsReplacer:
+			return parsedReplacement{}, &TemplateError{
+				Template: replacementStr,
+				Offset:   pr.offsets[i],
+				Msg:      "$argNNN and $args are not available in value replacements",
+			}
+		}
+	}
 
-				rest, err = expectRune(rest, ',')
-				if err != nil {
-					return parsedReplacement{}, err
-				}
+	err = pr.checkSyntax(replacementStr)
+	if err != nil {
+		return parsedReplacement{}, err
+	}
 
-				var name string
-				name, rest = takeWhile(rest, func(r rune) bool { return r != ',' && r != ')' })
+	return pr, nil
+}
 
-				var alias string
-				if rest[0] == ',' {
-					rest, _ = expectRune(rest, ',')
-					alias, rest = takeWhile(rest, func(r rune) bool { return r != ')' })
-				}
+// ValidateReplacements checks the replacements given to replacecall, so that mistakes in them are
+// reported before any package is loaded. Empty replacements are skipped.
+func ValidateReplacements(replacement, stmtReplacement, valueReplacement string) error {
+	var err error
+	if replacement != "" {
+		_, err = parseReplacement(replacement)
+		if err != nil {
+			return fmt.Errorf("invalid replacement: %w", err)
+		}
+	}
+	if stmtReplacement != "" {
+		_, err = parseStmtReplacement(stmtReplacement)
+		if err != nil {
+			return fmt.Errorf("invalid statement replacement: %w", err)
+		}
+	}
+	if valueReplacement != "" {
+		_, err = parseValueReplacement(valueReplacement)
+		if err != nil {
+			return fmt.Errorf("invalid value replacement: %w", err)
+		}
+	}
+	return nil
+}
 
-				rest, err = expectRune(rest, ')')
-				if err != nil {
-					return parsedReplacement{}, err
-				}
+// TemplateError is a mistake in a replacement, Offset bytes into it.
+type TemplateError struct {
+	Template string
+	Offset   int
+	Msg      string
+}
+
+// Error returns the message followed by the line of the replacement the mistake is on, with a caret
+// under it.
+func (e *TemplateError) Error() string {
+	lineStart := strings.LastIndex(e.Template[:e.Offset], "\n") + 1
+	lineEnd := strings.IndexByte(e.Template[e.Offset:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(e.Template)
+	} else {
+		lineEnd += e.Offset
+	}
+
+	// Keep tabs so that the caret lines up however wide they're displayed.
+	indent := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, e.Template[lineStart:e.Offset])
 
-				pr.replacers = append(pr.replacers, packageReplacer{
-					path:  pkg,
-					name:  name,
-					alias: alias,
-				})
-			default:
-				return parsedReplacement{}, fmt.Errorf("malformed placeholder; expected $argNNN but got $%s", metaName)
+	return fmt.Sprintf(
+		"%s at column %d\n\t%s\n\t%s^",
+		e.Msg, e.Offset-lineStart+1, e.Template[lineStart:lineEnd], indent,
+	)
+}
+
+// metavariables are the names of the metavariables, in the order they're matched against the text
+// after a $. $recvdot and $recvtype come before $recv, and $args before $arg, since they start with
+// them.
+var metavariables = []string{"recvdot", "recvtype", "recv", "return", "args", "arg", "lhs", "pkg", "name", "match"}
+
+// replacementParser parses a replacement. Its grammar is:
+//
+//	replacement = { text | "$$" | metavariable } .
+//	metavariable = "$arg" digits | "$args" | "$recvdot" | "$recv" | "$recvtype" | "$lhs"
+//	             | "$name" | "$match" digits
+//	             | "$return(" replacement ")"
+//	             | "$pkg(" path "," name [ "," alias ] ")" .
+//
+// $$ stands for a literal $. Inside $return(...), the parentheses in text have to balance, not
+// counting those in string and rune literals.
+type replacementParser struct {
+	src string
+	pos int
+}
+
+func parseReplacers(replacementStr string) (parsedReplacement, error) {
+	p := &replacementParser{src: replacementStr}
+	return p.parse(false)
+}
+
+func (p *replacementParser) errorf(offset int, format string, args ...any) error {
+	return &TemplateError{Template: p.src, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parse parses a replacement. If nested is set, it's the argument of $return(...), which ends at the
+// closing parenthesis. The parenthesis is left for the caller.
+func (p *replacementParser) parse(nested bool) (parsedReplacement, error) {
+	var pr parsedReplacement
+	add := func(offset int, r replacer) {
+		pr.replacers = append(pr.replacers, r)
+		pr.offsets = append(pr.offsets, offset)
+	}
+
+	depth := 0
+	var quote byte
+	textStart := p.pos
+	flush := func() {
+		if p.pos > textStart {
+			add(textStart, constantReplacer(p.src[textStart:p.pos]))
+		}
+	}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '$':
+			flush()
+
+			metaStart := p.pos
+			r, err := p.parseMetavariable()
+			if err != nil {
+				return parsedReplacement{}, err
 			}
+			add(metaStart, r)
 
+			textStart = p.pos
 			continue
+		case quote != 0:
+			// An escaped $ is still a metavariable, so the backslash escapes nothing.
+			if c == '\\' && quote != '`' && p.pos+1 < len(p.src) && p.src[p.pos+1] != '$' {
+				p.pos++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if nested && depth == 0 {
+				flush()
+				return pr, nil
+			}
+			depth--
 		}
+		p.pos++
+	}
+
+	flush()
+	return pr, nil
+}
+
+// parseMetavariable parses a metavariable, starting at its $.
+func (p *replacementParser) parseMetavariable() (replacer, error) {
+	start := p.pos
+	p.pos++
+
+	if strings.HasPrefix(p.src[p.pos:], "$") {
+		p.pos++
+		return constantReplacer("$"), nil
+	}
+
+	var name string
+	for _, m := range metavariables {
+		if strings.HasPrefix(p.src[p.pos:], m) {
+			name = m
+			break
+		}
+	}
+	if name == "" {
+		word, _ := takeWhile(p.src[p.pos:], isIdentRune)
+		return nil, p.errorf(
+			start,
+			"unknown metavariable $%s; expected one of $arg<n>, $args, $recv, $recvdot, $recvtype, $name, $match<n>, $pkg(...), $lhs or $return(...), or $$ for a literal $",
+			word,
+		)
+	}
+	p.pos += len(name)
 
-		end := 0
-		for end < len(rest) && rest[end] != '$' {
-			end++
+	var r replacer
+	switch name {
+	case "arg":
+		idx, err := p.parseIndex(start, "an argument", "$arg")
+		if err != nil {
+			return nil, err
+		}
+		r = argReplacer{index: idx}
+	case "args":
+		r = argsReplacer{}
+	case "match":
+		idx, err := p.parseIndex(start, "a wildcard", "$match")
+		if err != nil {
+			return nil, err
+		}
+		r = matchReplacer{index: idx}
+	case "name":
+		r = nameReplacer{}
+	case "recvtype":
+		r = recvTypeReplacer{}
+	case "recvdot":
+		// It's meant to be followed by a name.
+		return recvReplacer{dot: true}, nil
+	case "recv":
+		r = recvReplacer{}
+	case "lhs":
+		r = lhsReplacer{}
+	case "return":
+		open := p.pos
+		err := p.expect('(', "$return")
+		if err != nil {
+			return nil, err
 		}
 
-		pr.replacers = append(pr.replacers, constantReplacer(rest[:end]))
-		rest = rest[end:]
+		value, err := p.parse(true)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos == len(p.src) {
+			return nil, p.errorf(open, "the parenthesis after $return is never closed")
+		}
+		p.pos++
+
+		return returnReplacer{value: value}, nil
+	case "pkg":
+		return p.parsePackage(start)
 	}
 
-	return pr, nil
+	if next, _ := utf8.DecodeRuneInString(p.src[p.pos:]); isIdentRune(next) {
+		return nil, p.errorf(
+			p.pos,
+			"unexpected %q after $%s; a metavariable can't be followed directly by a name (did you mean $recvdot?)",
+			next, p.src[start+1:p.pos],
+		)
+	}
+
+	return r, nil
 }
 
-func takeWhile(s string, fn func(r rune) bool) (string, string) {
-	end := 0
-	for end < len(s) && fn(rune(s[end])) {
-		end++
+// parseIndex parses the index of what the metavariable called name, whose $ is at start, refers to.
+func (p *replacementParser) parseIndex(start int, what, name string) (int, error) {
+	digits, _ := takeWhile(p.src[p.pos:], isDigit)
+	if digits == "" {
+		return 0, p.errorf(p.pos, "expected the index of %s after %s, like %s0", what, name, name)
 	}
-	return s[:end], s[end:]
+	p.pos += len(digits)
+
+	idx, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, p.errorf(start, "invalid index: %v", err)
+	}
+	return idx, nil
 }
 
-func expectRune(str string, r rune) (string, error) {
-	if str == "" {
-		return "", fmt.Errorf("expected %c but the string was empty", r)
+// parsePackage parses the arguments of $pkg(path,name[,alias]), whose $ is at start.
+func (p *replacementParser) parsePackage(start int) (replacer, error) {
+	open := p.pos
+	err := p.expect('(', "$pkg")
+	if err != nil {
+		return nil, err
+	}
+
+	end := strings.IndexByte(p.src[p.pos:], ')')
+	if end == -1 {
+		return nil, p.errorf(open, "the parenthesis after $pkg is never closed")
 	}
-	if rune(str[0]) != r {
-		return "", fmt.Errorf("expected %c but got %c", r, str[0])
+
+	argsStart := p.pos
+	args := strings.Split(p.src[p.pos:p.pos+end], ",")
+	p.pos += end + 1
+
+	if len(args) < 2 || len(args) > 3 {
+		return nil, p.errorf(start, "expected $pkg(path,name) or $pkg(path,name,alias) but got %d arguments", len(args))
 	}
 
-	return str[1:], nil
+	// The offset of each argument, for errors.
+	offsets := make([]int, len(args))
+	offset := argsStart
+	for i, a := range args {
+		offsets[i] = offset + len(a) - len(strings.TrimLeft(a, " "))
+		offset += len(a) + 1
+		args[i] = strings.TrimSpace(a)
+	}
+
+	if args[0] == "" {
+		return nil, p.errorf(offsets[0], "expected an import path in $pkg")
+	}
+	if !token.IsIdentifier(args[1]) {
+		return nil, p.errorf(offsets[1], "expected the package's name in $pkg but got %q", args[1])
+	}
+
+	r := packageReplacer{path: args[0], name: args[1]}
+	if len(args) == 3 {
+		if !token.IsIdentifier(args[2]) {
+			return nil, p.errorf(offsets[2], "expected an import alias in $pkg but got %q", args[2])
+		}
+		r.alias = args[2]
+	}
+
+	return r, nil
+}
+
+// expect consumes r, which has to follow the metavariable called name.
+func (p *replacementParser) expect(r byte, name string) error {
+	if p.pos == len(p.src) {
+		return p.errorf(p.pos, "expected %c after %s but the replacement ended", r, name)
+	}
+	if p.src[p.pos] != r {
+		got, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		return p.errorf(p.pos, "expected %c after %s but got %q", r, name, got)
+	}
+
+	p.pos++
+	return nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func takeWhile(s string, fn func(r rune) bool) (string, string) {
+	end := 0
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !fn(r) {
+			break
+		}
+		end += size
+	}
+	return s[:end], s[end:]
 }
 
 type parsedReplacement struct {
 	replacers []replacer
+
+	// offsets holds the offset in the replacement where each replacer starts.
+	offsets []int
+
+	// stmt is set when the replacement replaces the statement enclosing the call rather than the
+	// call itself.
+	stmt bool
+}
+
+// String returns the replacement as a template that parses back to the same replacement. Literal $s
+// are escaped and the arguments of $pkg(...) are written without spaces, but the text is otherwise
+// kept as it was.
+func (pr parsedReplacement) String() string {
+	sb := &strings.Builder{}
+	for _, r := range pr.replacers {
+		switch r := r.(type) {
+		case constantReplacer:
+			sb.WriteString(strings.ReplaceAll(string(r), "$", "$$"))
+		case argReplacer:
+			sb.WriteString("$arg" + strconv.Itoa(r.index))
+		case argsReplacer:
+			sb.WriteString("$args")
+		case matchReplacer:
+			sb.WriteString("$match" + strconv.Itoa(r.index))
+		case nameReplacer:
+			sb.WriteString("$name")
+		case recvTypeReplacer:
+			sb.WriteString("$recvtype")
+		case recvReplacer:
+			if r.dot {
+				sb.WriteString("$recvdot")
+			} else {
+				sb.WriteString("$recv")
+			}
+		case packageReplacer:
+			sb.WriteString("$pkg(" + r.path + "," + r.name)
+			if r.alias != "" {
+				sb.WriteString("," + r.alias)
+			}
+			sb.WriteString(")")
+		case lhsReplacer:
+			sb.WriteString("$lhs")
+		case returnReplacer:
+			sb.WriteString("$return(" + r.value.String() + ")")
+		}
+	}
+	return sb.String()
+}
+
+// pkgNames returns the names the packages the replacement refers to are imported under in f, by path.
+// That's the existing name when f already imports a package. The imports aren't added until
+// addImports is called, once the replacement is made.
+func (pr parsedReplacement) pkgNames(importer *analyzeutil.Importer, fset *token.FileSet, f *ast.File) map[string]string {
+	names := make(map[string]string)
+	for imp := range pr.imports() {
+		names[imp.path] = cmp.Or(importer.Name(fset, f, imp.alias, imp.path), imp.name)
+	}
+	return names
+}
+
+// addImports adds the imports for the packages the replacement refers to to f.
+func (pr parsedReplacement) addImports(importer *analyzeutil.Importer, fset *token.FileSet, f *ast.File) {
+	for imp := range pr.imports() {
+		importer.Add(fset, f, imp.alias, imp.path)
+	}
 }
 
 func (pr parsedReplacement) imports() iter.Seq[packageReplacer] {
 	return func(yield func(packageReplacer) bool) {
 		for _, r := range pr.replacers {
-			if r, ok := r.(packageReplacer); ok {
+			switch r := r.(type) {
+			case packageReplacer:
 				if !yield(r) {
 					return
 				}
+			case returnReplacer:
+				for imp := range r.value.imports() {
+					if !yield(imp) {
+						return
+					}
+				}
 			}
 		}
 	}
 }
 
-func (pr parsedReplacement) print(fset *token.FileSet, call *ast.CallExpr) (string, error) {
+func (pr parsedReplacement) print(site callSite) (string, error) {
+	if site.multiline() {
+		return pr.printMultiline(site)
+	}
+
 	sb := &strings.Builder{}
+	dropComma := false
 	for _, r := range pr.replacers {
-		s, err := r.print(fset, call)
+		s, err := r.print(site)
 		if err != nil {
 			return "", err
 		}
 
+		if dropComma {
+			s = strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(s, " "), ","), " ")
+			dropComma = false
+		}
+		if _, ok := r.(argsReplacer); ok && s == "" {
+			// There are no arguments to pass along, so the comma separating them from the ones around
+			// them has to go too.
+			written := strings.TrimRight(sb.String(), " ")
+			if before, ok := strings.CutSuffix(written, ","); ok {
+				sb.Reset()
+				sb.WriteString(before)
+			} else {
+				dropComma = true
+			}
+		}
+
 		sb.WriteString(s)
 	}
 
 	return sb.String(), nil
 }
 
+// printMultiline is like print, for a call whose arguments are on lines of their own.
+func (pr parsedReplacement) printMultiline(site callSite) (string, error) {
+	w := newArgWriter(site)
+	for _, r := range pr.replacers {
+		if ar, ok := r.(argReplacer); ok {
+			err := ar.check(site)
+			if err != nil {
+				return "", err
+			}
+
+			w.writeArg(ar.index)
+			continue
+		}
+		if ar, ok := r.(argsReplacer); ok {
+			err := ar.check(site)
+			if err != nil {
+				return "", err
+			}
+
+			for i := range site.call.Args {
+				if i > 0 {
+					w.writeString(", ")
+				}
+				w.writeArg(i)
+			}
+			if site.call.Ellipsis.IsValid() {
+				w.writeString("...")
+			}
+			continue
+		}
+
+		s, err := r.print(site)
+		if err != nil {
+			return "", err
+		}
+		w.writeString(s)
+	}
+
+	return w.String(), nil
+}
+
+// calledFuncRE matches the start of a call to a function named in a replacement, after the package or
+// receiver it's selected from.
+var calledFuncRE = regexp.MustCompile(`^\.?([\pL_][\pL\pN_]*)\(`)
+
+// linkTarget returns the function the replacement for spec calls, for pointing doc links at. It's only
+// known when the replacement starts by calling a function from $pkg(...) or a method on $recv.
+func (pr parsedReplacement) linkTarget(spec SymbolSpec) (linkTarget, bool) {
+	if pr.stmt || len(pr.replacers) < 2 {
+		return linkTarget{}, false
+	}
+
+	c, ok := pr.replacers[1].(constantReplacer)
+	if !ok {
+		return linkTarget{}, false
+	}
+
+	m := calledFuncRE.FindStringSubmatch(string(c))
+	if m == nil {
+		return linkTarget{}, false
+	}
+
+	switch r := pr.replacers[0].(type) {
+	case packageReplacer:
+		if !strings.HasPrefix(string(c), ".") {
+			return linkTarget{}, false
+		}
+		return linkTarget{path: r.path, pkgName: r.name, name: m[1]}, true
+	case recvReplacer:
+		if spec.recv == "" {
+			// $recvdot qualifies a package-level function with its package.
+			return linkTarget{path: spec.Pkg, name: m[1]}, true
+		}
+		return linkTarget{path: spec.Pkg, name: spec.recv, method: m[1]}, true
+	default:
+		return linkTarget{}, false
+	}
+}
+
+// callSite is the context a replacement is printed in.
+type callSite struct {
+	fset *token.FileSet
+	call *ast.CallExpr
+
+	// src is the source of the file the call is in. Without it, arguments are printed from their
+	// syntax, which loses their comments.
+	src []byte
+
+	// hoisted holds the names of the variables arguments were moved into, by argument index.
+	hoisted map[int]string
+
+	// pkgNames holds the names the packages from $pkg(...) are imported under, by path.
+	pkgNames map[string]string
+
+	// obj is the function being replaced, and matches are what the wildcards in its spec matched.
+	obj     types.Object
+	matches []string
+
+	// ref is set instead of call when replacing a reference to a function that isn't called, like a
+	// function value or a method value.
+	ref ast.Expr
+
+	// The following are only set for statement replacements. stmt is the statement enclosing call,
+	// results are the results of the function enclosing stmt, and qualifier qualifies the types of
+	// those results from the point of view of the file being rewritten.
+	stmt      ast.Stmt
+	results   *types.Tuple
+	qualifier types.Qualifier
+}
+
+// fun returns the expression for the function being replaced.
+func (site callSite) fun() ast.Expr {
+	if site.call != nil {
+		return site.call.Fun
+	}
+	return site.ref
+}
+
 type replacer interface {
-	print(*token.FileSet, *ast.CallExpr) (string, error)
+	print(callSite) (string, error)
 }
 
 type packageReplacer struct {
@@ -154,13 +674,16 @@ type packageReplacer struct {
 	alias string
 }
 
-func (pr packageReplacer) print(*token.FileSet, *ast.CallExpr) (string, error) {
-	return pr.name, nil
+func (pr packageReplacer) print(site callSite) (string, error) {
+	if name, ok := site.pkgNames[pr.path]; ok {
+		return name, nil
+	}
+	return cmp.Or(pr.alias, pr.name), nil
 }
 
 type constantReplacer string
 
-func (cr constantReplacer) print(*token.FileSet, *ast.CallExpr) (string, error) {
+func (cr constantReplacer) print(callSite) (string, error) {
 	return string(cr), nil
 }
 
@@ -168,29 +691,140 @@ type argReplacer struct {
 	index int
 }
 
-func (ar argReplacer) print(fset *token.FileSet, call *ast.CallExpr) (string, error) {
+func (ar argReplacer) print(site callSite) (string, error) {
+	err := ar.check(site)
+	if err != nil {
+		return "", err
+	}
+
+	if name, ok := site.hoisted[ar.index]; ok {
+		return name, nil
+	}
+	if site.hasSource() {
+		return site.argSource(ar.index).inline(), nil
+	}
+	return analyzeutil.FormatNode(site.fset, site.call.Args[ar.index])
+}
+
+// check checks that the call has the argument.
+func (ar argReplacer) check(site callSite) error {
 	if ar.index < 0 {
-		return "", errors.New("index must be greater than or equal to 0")
+		return errors.New("index must be greater than or equal to 0")
 	}
 
-	if ar.index >= len(call.Args) {
-		return "", fmt.Errorf("index was %d but there are only %d arguments", ar.index, len(call.Args))
+	if site.call == nil {
+		return errors.New("arguments are not available when replacing a function value")
 	}
 
-	return analyzeutil.FormatNode(fset, call.Args[ar.index])
+	if ar.index >= len(site.call.Args) {
+		return fmt.Errorf("index was %d but there are only %d arguments", ar.index, len(site.call.Args))
+	}
+
+	return nil
+}
+
+// argsReplacer prints all the arguments of the call, for passing them along to a function with the
+// same parameters.
+type argsReplacer struct{}
+
+func (ar argsReplacer) print(site callSite) (string, error) {
+	err := ar.check(site)
+	if err != nil {
+		return "", err
+	}
+
+	args := make([]string, 0, len(site.call.Args))
+	for i := range site.call.Args {
+		s, err := argReplacer{index: i}.print(site)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, s)
+	}
+
+	s := strings.Join(args, ", ")
+	if site.call.Ellipsis.IsValid() {
+		s += "..."
+	}
+	return s, nil
+}
+
+func (argsReplacer) check(site callSite) error {
+	if site.call == nil {
+		return errors.New("arguments are not available when replacing a function value")
+	}
+	return nil
+}
+
+// nameReplacer prints the name of the function being replaced, which is mostly useful when its spec
+// is a pattern.
+type nameReplacer struct{}
+
+func (nameReplacer) print(site callSite) (string, error) {
+	if site.obj == nil {
+		return "", errors.New("$name was used but the function being replaced is unknown")
+	}
+	return site.obj.Name(), nil
+}
+
+// recvTypeReplacer prints the name of the type the method being replaced is declared on.
+type recvTypeReplacer struct{}
+
+func (recvTypeReplacer) print(site callSite) (string, error) {
+	fn, ok := site.obj.(*types.Func)
+	if !ok {
+		return "", errors.New("$recvtype was used but the function being replaced is unknown")
+	}
+
+	named, ok := recvNamed(fn)
+	if !ok {
+		return "", fmt.Errorf("$recvtype was used but %s is not a method", fn.Name())
+	}
+	return named.Obj().Name(), nil
+}
+
+// matchReplacer prints what the wildcard at index in the spec of the function being replaced
+// matched.
+type matchReplacer struct {
+	index int
+}
+
+func (mr matchReplacer) print(site callSite) (string, error) {
+	if mr.index >= len(site.matches) {
+		return "", fmt.Errorf("$match%d was used but the spec has only %d wildcards", mr.index, len(site.matches))
+	}
+	return site.matches[mr.index], nil
+}
+
+// checkMatches checks that the wildcards the replacement refers to with $match<n> are in spec.
+func (pr parsedReplacement) checkMatches(spec SymbolSpec) error {
+	for _, r := range pr.replacers {
+		switch r := r.(type) {
+		case matchReplacer:
+			if r.index >= spec.Wildcards() {
+				return fmt.Errorf("$match%d was used but %s has only %d wildcards", r.index, spec, spec.Wildcards())
+			}
+		case returnReplacer:
+			err := r.value.checkMatches(spec)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 type recvReplacer struct {
 	dot bool
 }
 
-func (r recvReplacer) print(fset *token.FileSet, call *ast.CallExpr) (string, error) {
-	sel, ok := call.Fun.(*ast.SelectorExpr)
+func (r recvReplacer) print(site callSite) (string, error) {
+	sel, ok := site.fun().(*ast.SelectorExpr)
 	if !ok {
 		return "", nil
 	}
 
-	formatted, err := analyzeutil.FormatNode(fset, sel.X)
+	formatted, err := analyzeutil.FormatNode(site.fset, sel.X)
 	if err != nil {
 		return "", err
 	}
@@ -200,3 +834,74 @@ func (r recvReplacer) print(fset *token.FileSet, call *ast.CallExpr) (string, er
 	}
 	return formatted, nil
 }
+
+// lhsReplacer prints the left-hand side of the assignment enclosing the call.
+type lhsReplacer struct{}
+
+func (lhsReplacer) print(site callSite) (string, error) {
+	assign, ok := site.stmt.(*ast.AssignStmt)
+	if !ok {
+		return "", errors.New("$lhs was used but the result of the call is not assigned")
+	}
+
+	lhs := make([]string, 0, len(assign.Lhs))
+	for _, e := range assign.Lhs {
+		formatted, err := analyzeutil.FormatNode(site.fset, e)
+		if err != nil {
+			return "", err
+		}
+		lhs = append(lhs, formatted)
+	}
+
+	return strings.Join(lhs, ", "), nil
+}
+
+// returnReplacer prints a return statement from the function enclosing the call. value is returned
+// as the last result and every other result is the zero value of its type.
+type returnReplacer struct {
+	value parsedReplacement
+}
+
+func (r returnReplacer) print(site callSite) (string, error) {
+	if site.results == nil || site.results.Len() == 0 {
+		return "", errors.New("$return was used but the enclosing function has no results")
+	}
+
+	results := make([]string, 0, site.results.Len())
+	for i := range site.results.Len() - 1 {
+		results = append(results, zeroValue(site.results.At(i).Type(), site.qualifier))
+	}
+
+	value, err := r.value.print(site)
+	if err != nil {
+		return "", err
+	}
+	results = append(results, value)
+
+	return "return " + strings.Join(results, ", "), nil
+}
+
+// zeroValue returns an expression for the zero value of t.
+func zeroValue(t types.Type, qualifier types.Qualifier) string {
+	if _, ok := t.(*types.TypeParam); ok {
+		return "*new(" + types.TypeString(t, qualifier) + ")"
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		default:
+			return "nil"
+		}
+	case *types.Struct, *types.Array:
+		return types.TypeString(t, qualifier) + "{}"
+	default:
+		return "nil"
+	}
+}
@@ -3,6 +3,7 @@ package replace
 import (
 	"go/ast"
 	"go/token"
+	"go/types"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,7 +14,7 @@ func TestParsingReplacement_Basic(t *testing.T) {
 	r, err := parseReplacement("replace")
 	require.NoError(t, err)
 
-	res, err := r.print(nil, nil)
+	res, err := r.print(callSite{})
 	require.NoError(t, err)
 
 	assert.Equal(t, "replace", res)
@@ -23,9 +24,12 @@ func TestParsingReplacement_Args_Basic(t *testing.T) {
 	r, err := parseReplacement("$arg0")
 	require.NoError(t, err)
 
-	res, err := r.print(token.NewFileSet(), &ast.CallExpr{
-		Args: []ast.Expr{
-			&ast.Ident{Name: "foo"},
+	res, err := r.print(callSite{
+		fset: token.NewFileSet(),
+		call: &ast.CallExpr{
+			Args: []ast.Expr{
+				&ast.Ident{Name: "foo"},
+			},
 		},
 	})
 	require.NoError(t, err)
@@ -37,10 +41,13 @@ func TestParsingReplacement_Args_Multiple(t *testing.T) {
 	r, err := parseReplacement("$arg0, $arg1")
 	require.NoError(t, err)
 
-	res, err := r.print(token.NewFileSet(), &ast.CallExpr{
-		Args: []ast.Expr{
-			&ast.Ident{Name: "foo"},
-			&ast.Ident{Name: "bar"},
+	res, err := r.print(callSite{
+		fset: token.NewFileSet(),
+		call: &ast.CallExpr{
+			Args: []ast.Expr{
+				&ast.Ident{Name: "foo"},
+				&ast.Ident{Name: "bar"},
+			},
 		},
 	})
 	require.NoError(t, err)
@@ -52,17 +59,20 @@ func TestParsingReplacement_Args_MultipleComplex(t *testing.T) {
 	r, err := parseReplacement("$arg0, $arg1")
 	require.NoError(t, err)
 
-	res, err := r.print(token.NewFileSet(), &ast.CallExpr{
-		Args: []ast.Expr{
-			&ast.BinaryExpr{
-				X:  &ast.Ident{Name: "foo"},
-				Op: token.MUL,
-				Y: &ast.BasicLit{
-					Kind:  token.STRING,
-					Value: "\"blah\"",
+	res, err := r.print(callSite{
+		fset: token.NewFileSet(),
+		call: &ast.CallExpr{
+			Args: []ast.Expr{
+				&ast.BinaryExpr{
+					X:  &ast.Ident{Name: "foo"},
+					Op: token.MUL,
+					Y: &ast.BasicLit{
+						Kind:  token.STRING,
+						Value: "\"blah\"",
+					},
 				},
+				&ast.Ident{Name: "bar"},
 			},
-			&ast.Ident{Name: "bar"},
 		},
 	})
 	require.NoError(t, err)
@@ -74,17 +84,266 @@ func TestParsingReplacement_Realistic(t *testing.T) {
 	r, err := parseReplacement("$arg2.SomeFunction($arg1, $arg0)")
 	require.NoError(t, err)
 
-	res, err := r.print(token.NewFileSet(), &ast.CallExpr{
-		Args: []ast.Expr{
-			&ast.BasicLit{
-				Kind:  token.INT,
-				Value: "123",
+	res, err := r.print(callSite{
+		fset: token.NewFileSet(),
+		call: &ast.CallExpr{
+			Args: []ast.Expr{
+				&ast.BasicLit{
+					Kind:  token.INT,
+					Value: "123",
+				},
+				&ast.Ident{Name: "bar"},
+				&ast.Ident{Name: "moveThis"},
 			},
-			&ast.Ident{Name: "bar"},
-			&ast.Ident{Name: "moveThis"},
 		},
 	})
 	require.NoError(t, err)
 
 	assert.Equal(t, "moveThis.SomeFunction(bar, 123)", res)
 }
+
+func TestParsingReplacement_Stmt(t *testing.T) {
+	_, err := parseReplacement("$return(err)")
+	require.Error(t, err)
+
+	r, err := parseStmtReplacement(`$lhs, err := Open($arg0); if err != nil { $return(fmt.Errorf("open (%s): %w", $arg0, err)) }`)
+	require.NoError(t, err)
+
+	res, err := r.print(callSite{
+		fset: token.NewFileSet(),
+		call: &ast.CallExpr{
+			Args: []ast.Expr{
+				&ast.Ident{Name: "path"},
+			},
+		},
+		stmt: &ast.AssignStmt{
+			Lhs: []ast.Expr{&ast.Ident{Name: "f"}},
+		},
+		results: types.NewTuple(
+			types.NewVar(token.NoPos, nil, "", types.Typ[types.Int]),
+			types.NewVar(token.NoPos, nil, "", types.NewStruct(nil, nil)),
+			types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type()),
+		),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `f, err := Open(path); if err != nil { return 0, struct{}{}, fmt.Errorf("open (%s): %w", path, err) }`, res)
+}
+
+func TestParsingReplacement_Recv(t *testing.T) {
+	site := callSite{
+		fset: token.NewFileSet(),
+		call: &ast.CallExpr{
+			Fun: &ast.SelectorExpr{
+				X:   &ast.Ident{Name: "mypackage"},
+				Sel: &ast.Ident{Name: "Example"},
+			},
+		},
+	}
+
+	r, err := parseReplacement("$recvdotNewExample()")
+	require.NoError(t, err)
+
+	res, err := r.print(site)
+	require.NoError(t, err)
+	assert.Equal(t, "mypackage.NewExample()", res)
+
+	// A literal $ is only valid Go inside a literal.
+	_, err = parseReplacement("$recv.Other($$)")
+	require.Error(t, err)
+
+	r, err = parseReplacement(`$recv.Other("$$5")`)
+	require.NoError(t, err)
+
+	res, err = r.print(site)
+	require.NoError(t, err)
+	assert.Equal(t, `mypackage.Other("$5")`, res)
+}
+
+func TestParsingReplacement_Errors(t *testing.T) {
+	tests := []struct {
+		replacement string
+		stmt        bool
+		expErr      string
+	}{{
+		replacement: "Replaced($foo)",
+		expErr:      "unknown metavariable $foo; expected one of $arg<n>, $args, $recv, $recvdot, $recvtype, $name, $match<n>, $pkg(...), $lhs or $return(...), or $$ for a literal $ at column 10\n\tReplaced($foo)\n\t         ^",
+	}, {
+		replacement: "Replaced($arg)",
+		expErr:      "expected the index of an argument after $arg, like $arg0 at column 14",
+	}, {
+		replacement: "Replaced($match)",
+		expErr:      "expected the index of a wildcard after $match, like $match0 at column 16",
+	}, {
+		replacement: "$nameFunc()",
+		expErr:      `unexpected 'F' after $name; a metavariable can't be followed directly by a name (did you mean $recvdot?) at column 6`,
+	}, {
+		replacement: "$recvNew()",
+		expErr:      `unexpected 'N' after $recv; a metavariable can't be followed directly by a name (did you mean $recvdot?) at column 6`,
+	}, {
+		replacement: "$pkg(",
+		expErr:      "the parenthesis after $pkg is never closed at column 5",
+	}, {
+		replacement: "$pkg",
+		expErr:      "expected ( after $pkg but the replacement ended at column 5",
+	}, {
+		replacement: "$pkg(example.com/new)",
+		expErr:      "expected $pkg(path,name) or $pkg(path,name,alias) but got 1 arguments at column 1",
+	}, {
+		replacement: "$pkg(example.com/new, new-pkg).F()",
+		expErr:      `expected the package's name in $pkg but got "new-pkg" at column 23`,
+	}, {
+		replacement: "$pkg(,new).F()",
+		expErr:      "expected an import path in $pkg at column 6",
+	}, {
+		replacement: "Replaced($arg0",
+		expErr:      "the replacement is not valid Go: missing ',' before newline in argument list at column 15",
+	}, {
+		replacement: "$arg0; $arg1",
+		expErr:      "the replacement is not valid Go: expected an expression or a list of expressions at column 13",
+	}, {
+		replacement: "Replaced($arg0))",
+		expErr:      "the replacement is not valid Go: expected statement, found ')' at column 16",
+	}, {
+		replacement: "$return(err)",
+		expErr:      "$lhs and $return are only available in statement replacements at column 1",
+	}, {
+		replacement: "x := $arg0; if x { $return(x }",
+		stmt:        true,
+		expErr:      "the parenthesis after $return is never closed at column 27",
+	}, {
+		replacement: "$lhs := Open($arg0); if err != nil { $return(err) ",
+		stmt:        true,
+		expErr:      "the replacement is not valid Go: expected '}', found 'EOF' at column 51",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.replacement, func(t *testing.T) {
+			var err error
+			if tc.stmt {
+				_, err = parseStmtReplacement(tc.replacement)
+			} else {
+				_, err = parseReplacement(tc.replacement)
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr)
+
+			var te *TemplateError
+			require.ErrorAs(t, err, &te)
+		})
+	}
+}
+
+func TestParsingReplacement_String(t *testing.T) {
+	tests := []struct {
+		replacement string
+		expected    string
+	}{
+		{replacement: "F($arg0, $arg1)"},
+		{replacement: "$recv.New($arg0)"},
+		{replacement: "$recvdotNew()"},
+		{replacement: `fmt.Sprintf("$$%d", $arg0)`},
+		{replacement: "$pkg(example.com/new,new).F()"},
+		{replacement: "$pkg( example.com/new , new , nw ).F()", expected: "$pkg(example.com/new,new,nw).F()"},
+		{replacement: `$lhs, err := Open($arg0); if err != nil { $return(fmt.Errorf("open (%s): %w", $arg0, err)) }`},
+		{replacement: `$return($pkg(example.com/errs,errs).New("$$"))`},
+		{replacement: "assert.$match0($args)"},
+		{replacement: "$pkg(example.com/new,new).$name$recvtype($recv, $args)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.replacement, func(t *testing.T) {
+			r, err := parseReplacers(tc.replacement)
+			require.NoError(t, err)
+
+			expected := tc.expected
+			if expected == "" {
+				expected = tc.replacement
+			}
+			assert.Equal(t, expected, r.String())
+		})
+	}
+}
+
+// FuzzParseReplacement checks that the parsers don't panic, that they only fail with a TemplateError
+// pointing into the replacement, and that printing a replacement and parsing it again gives back the
+// same replacement.
+func FuzzParseReplacement(f *testing.F) {
+	for _, s := range []string{
+		"replace",
+		"$arg0",
+		"F($arg0, $arg1)",
+		"$arg2.SomeFunction($arg1, $arg0)",
+		"$recv.New($arg0)",
+		"$recvdotNewExample()",
+		`$recv.Other("$$5")`,
+		"$pkg(example.com/new,new).F($arg0)",
+		"$pkg(example.com/new, new, nw).F()",
+		`$lhs, err := Open($arg0); if err != nil { $return(fmt.Errorf("open (%s): %w", $arg0, err)) }`,
+		"$return($arg0)",
+		`"\$arg0"`,
+		"`$$`",
+		"Replaced($foo)",
+		"$recvNew()",
+		"$pkg(",
+		"x := $arg0; if x { $return(x }",
+		"assert.$match0($args)",
+		"$recvdot$name$recvtype($recv, $args)",
+		"$argsX",
+	} {
+		f.Add(s)
+	}
+
+	parsers := map[string]func(string) (parsedReplacement, error){
+		"expr":  parseReplacement,
+		"stmt":  parseStmtReplacement,
+		"value": parseValueReplacement,
+	}
+
+	f.Fuzz(func(t *testing.T, replacement string) {
+		for name, parse := range parsers {
+			r, err := parse(replacement)
+			if err != nil {
+				var te *TemplateError
+				require.ErrorAs(t, err, &te, "%s: %q", name, replacement)
+				require.GreaterOrEqual(t, te.Offset, 0)
+				require.LessOrEqual(t, te.Offset, len(replacement))
+				_ = te.Error()
+				continue
+			}
+
+			printed := r.String()
+			reparsed, err := parse(printed)
+			require.NoError(t, err, "%s: %q printed as %q", name, replacement, printed)
+			require.Equal(t, normalizeReplacers(r), normalizeReplacers(reparsed), "%s: %q printed as %q", name, replacement, printed)
+			require.Equal(t, printed, reparsed.String(), "%s: %q", name, replacement)
+		}
+	})
+}
+
+// normalizeReplacers returns the replacers of r without their offsets, and with adjacent text merged,
+// since the parser splits text at each $$.
+func normalizeReplacers(r parsedReplacement) []replacer {
+	var replacers []replacer
+	for _, rep := range r.replacers {
+		switch rep := rep.(type) {
+		case constantReplacer:
+			if n := len(replacers); n > 0 {
+				if prev, ok := replacers[n-1].(constantReplacer); ok {
+					replacers[n-1] = prev + rep
+					continue
+				}
+			}
+		case returnReplacer:
+			replacers = append(replacers, normalizedReturn{value: normalizeReplacers(rep.value)})
+			continue
+		}
+		replacers = append(replacers, rep)
+	}
+	return replacers
+}
+
+type normalizedReturn struct {
+	replacer
+	value []replacer
+}
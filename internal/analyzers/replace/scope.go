@@ -0,0 +1,194 @@
+package replace
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// position is a kind of place a type can be referred to from.
+type position string
+
+const (
+	positionFields      position = "fields"
+	positionParams      position = "params"
+	positionResults     position = "results"
+	positionVars        position = "vars"
+	positionConversions position = "conversions"
+)
+
+// TypeScope restricts a type replacement to some of the references to the type.
+type TypeScope struct {
+	// only holds the positions to replace references in. If it's empty, every position is included.
+	only []position
+
+	// withinPkgs is set to a package path to only replace references in that package and the packages
+	// below it.
+	withinPkgs string
+
+	// withinSymbol is set to only replace references inside the declaration of a symbol.
+	withinSymbol *SymbolSpec
+}
+
+// ParseTypeScope parses the values of the --only and --within flags. only is a comma-separated list
+// of positions. within is either a symbol spec or a package path ending in /... to include the whole
+// subtree.
+func ParseTypeScope(only, within string) (TypeScope, error) {
+	var scope TypeScope
+
+	if only != "" {
+		for _, p := range strings.Split(only, ",") {
+			switch p := position(strings.TrimSpace(p)); p {
+			case positionFields, positionParams, positionResults, positionVars, positionConversions:
+				scope.only = append(scope.only, p)
+			default:
+				return TypeScope{}, fmt.Errorf("unknown position %q; expected one of fields, params, results, vars or conversions", p)
+			}
+		}
+	}
+
+	switch {
+	case within == "":
+	case strings.HasSuffix(within, "/..."):
+		scope.withinPkgs = strings.TrimSuffix(within, "/...")
+	default:
+		spec, err := ParseSymbolSpec(within)
+		if err != nil {
+			return TypeScope{}, fmt.Errorf("error parsing within: %w", err)
+		}
+		scope.withinSymbol = &spec
+	}
+
+	return scope, nil
+}
+
+// IsZero reports whether the scope includes every reference.
+func (s TypeScope) IsZero() bool {
+	return len(s.only) == 0 && s.withinPkgs == "" && s.withinSymbol == nil
+}
+
+// Includes reports whether n, the last element of stack, is a reference in the package at path that
+// may be replaced.
+func (s TypeScope) Includes(path string, info *types.Info, n ast.Node, stack []ast.Node) bool {
+	return s.includesPackage(path) && s.includes(info, n, stack)
+}
+
+// includesPackage reports whether references in the package at path may be replaced.
+func (s TypeScope) includesPackage(path string) bool {
+	return s.withinPkgs == "" || path == s.withinPkgs || strings.HasPrefix(path, s.withinPkgs+"/")
+}
+
+// includesComments reports whether comments in the package at path may be updated. Comments aren't
+// in any of the positions --only picks, and aren't code inside the --within symbol, so either of those
+// leaves comments alone.
+func (s TypeScope) includesComments(path string) bool {
+	return len(s.only) == 0 && s.withinSymbol == nil && s.includesPackage(path)
+}
+
+// includes reports whether n, the last element of stack, is a reference that may be replaced, not
+// counting the package it's in.
+func (s TypeScope) includes(info *types.Info, n ast.Node, stack []ast.Node) bool {
+	if len(s.only) > 0 && !slices.Contains(s.only, positionOf(info, n, stack)) {
+		return false
+	}
+
+	if s.withinSymbol != nil && !insideSymbol(info, *s.withinSymbol, stack) {
+		return false
+	}
+
+	return true
+}
+
+// inScope reports whether n, the last element of stack, is a reference that may be replaced.
+func (tr *typeReplacer) inScope(n ast.Node, stack []ast.Node) bool {
+	return tr.scope.includes(tr.pass.TypesInfo, n, stack)
+}
+
+// inScopeAt is like inScope for the node at pos in one of the package's files. Positions outside of
+// the package are considered in scope, because there's no telling.
+func (tr *typeReplacer) inScopeAt(pos token.Pos) bool {
+	for _, f := range tr.pass.Files {
+		if f.FileStart <= pos && pos < f.FileEnd {
+			path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+			slices.Reverse(path)
+			return tr.inScope(path[len(path)-1], path)
+		}
+	}
+
+	return true
+}
+
+// positionOf works out what kind of place n, the last element of stack, is in. It's empty if n isn't in
+// any of the places --only can pick.
+func positionOf(info *types.Info, n ast.Node, stack []ast.Node) position {
+	child := n
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch parent := stack[i].(type) {
+		case *ast.Field:
+			if i < 2 {
+				return ""
+			}
+
+			list := stack[i-1].(*ast.FieldList)
+			switch owner := stack[i-2].(type) {
+			case *ast.StructType:
+				return positionFields
+			case *ast.FuncType:
+				if owner.Results == list {
+					return positionResults
+				}
+				return positionParams
+			case *ast.FuncDecl:
+				// The receiver.
+				return positionParams
+			default:
+				return ""
+			}
+		case *ast.ValueSpec:
+			if parent.Type == child {
+				return positionVars
+			}
+			return ""
+		case *ast.CallExpr:
+			if parent.Fun == child && info.Types[parent.Fun].IsType() {
+				return positionConversions
+			}
+			return ""
+		case ast.Stmt, ast.Decl:
+			return ""
+		}
+
+		child = stack[i]
+	}
+
+	return ""
+}
+
+// insideSymbol reports whether stack is inside the declaration of the symbol described by spec.
+func insideSymbol(info *types.Info, spec SymbolSpec, stack []ast.Node) bool {
+	for _, n := range stack {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if spec.matchesFunc(info.Defs[n.Name]) {
+				return true
+			}
+		case *ast.TypeSpec:
+			if spec.matchesTopLevelSymbol(info.Defs[n.Name]) {
+				return true
+			}
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				if spec.matchesTopLevelSymbol(info.Defs[name]) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
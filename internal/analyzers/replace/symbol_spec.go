@@ -1,9 +1,12 @@
 package replace
 
 import (
-	"errors"
+	"fmt"
+	"go/token"
 	"go/types"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type SymbolSpec struct {
@@ -12,57 +15,430 @@ type SymbolSpec struct {
 
 	// recv is only set for function specs
 	recv string
+
+	// ptrRecv is set when the receiver was written as (*T). Both forms match methods declared on T
+	// and on *T, like method expressions do.
+	ptrRecv bool
+
+	// recvTypeParams holds the text between the brackets in a receiver like Map[K, V].
+	recvTypeParams string
+
+	// typeArgs is only set for type replacements. It's the text between the brackets in a spec like
+	// pkg.Map[$T1, $T0], where $T<n> stands for the nth type argument of the type being replaced.
+	typeArgs string
+}
+
+// NewSymbolSpec returns the spec for the symbol called name in the package at path, declared on the
+// type called recv if it's a method.
+func NewSymbolSpec(path, recv, name string) SymbolSpec {
+	return SymbolSpec{Pkg: path, recv: recv, name: name}
+}
+
+// SymbolSpecOf returns the spec describing obj, which is a package-level symbol or a method.
+func SymbolSpecOf(obj types.Object) SymbolSpec {
+	spec := SymbolSpec{Pkg: obj.Pkg().Path(), name: obj.Name()}
+
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return spec
+	}
+
+	if named, ok := recvNamed(fn); ok {
+		spec.recv = named.Obj().Name()
+	}
+
+	return spec
 }
 
 func (s SymbolSpec) matchesTopLevelSymbol(obj types.Object) bool {
-	return obj != nil && obj.Name() == s.name && obj.Pkg() != nil && obj.Pkg().Path() == s.Pkg
+	return s.recv == "" && s.matchesFunc(obj)
 }
 
-func (s SymbolSpec) matchesFuncReceiver(obj types.Object) bool {
-	if obj.Name() != s.name {
-		return false
+// Name returns the name of the symbol, without its package or receiver.
+func (s SymbolSpec) Name() string {
+	return s.name
+}
+
+// Matches reports whether obj is the symbol described by s: a package-level symbol or a method.
+func (s SymbolSpec) Matches(obj types.Object) bool {
+	return s.matchesFunc(obj)
+}
+
+// matchesFunc reports whether obj is the function or method described by s.
+func (s SymbolSpec) matchesFunc(obj types.Object) bool {
+	_, ok := s.match(obj)
+	return ok
+}
+
+// match reports whether obj is described by s, and returns what the wildcards in s matched, in the
+// order they appear in it. A pattern only matches functions and methods.
+func (s SymbolSpec) match(obj types.Object) ([]string, bool) {
+	if obj == nil || obj.Pkg() == nil {
+		return nil, false
 	}
 
-	sig, ok := obj.(*types.Func)
-	if !ok {
-		return false
+	fn, isFunc := obj.(*types.Func)
+	if !isFunc && s.IsPattern() {
+		return nil, false
 	}
 
-	recv := sig.Signature().Recv()
+	path, recv := obj.Pkg().Path(), ""
+	if isFunc && fn.Signature().Recv() != nil {
+		named, ok := recvNamed(fn)
+		if !ok {
+			return nil, false
+		}
+		path, recv = named.Obj().Pkg().Path(), named.Obj().Name()
+	}
+	if (s.recv == "") != (recv == "") {
+		return nil, false
+	}
+
+	var captures []string
+	for _, m := range []struct {
+		pattern, s string
+		path       bool
+	}{
+		{s.Pkg, path, true},
+		{s.recv, recv, false},
+		{s.name, obj.Name(), false},
+	} {
+		c, ok := matchGlob(m.pattern, m.s, m.path)
+		if !ok {
+			return nil, false
+		}
+		captures = append(captures, c...)
+	}
+
+	return captures, true
+}
+
+// recvNamed returns the type the method fn is declared on.
+func recvNamed(fn *types.Func) (*types.Named, bool) {
+	recv := fn.Signature().Recv()
 	if recv == nil {
-		return false
+		return nil, false
 	}
 
-	recvTyp, ok := recv.Type().(*types.Named)
-	if !ok {
-		return false
+	typ := recv.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+
+	named, ok := typ.(*types.Named)
+	return named, ok
+}
+
+// wildcards are the characters that make a spec a pattern. * matches any run of characters and ?
+// matches any one character, except that neither matches a slash in a package path.
+const wildcards = "*?"
+
+// IsPattern reports whether s has wildcards, so that it describes a family of functions rather than
+// one symbol.
+func (s SymbolSpec) IsPattern() bool {
+	return s.Wildcards() > 0
+}
+
+// Wildcards returns the number of wildcards in s. What they match is available to replacements as
+// $match0, $match1 and so on.
+func (s SymbolSpec) Wildcards() int {
+	n := 0
+	for _, part := range []string{s.Pkg, s.recv, s.name} {
+		for _, c := range wildcards {
+			n += strings.Count(part, string(c))
+		}
+	}
+	return n
+}
+
+// MatchesPkg reports whether path is the package of s, or matches its wildcards.
+func (s SymbolSpec) MatchesPkg(path string) bool {
+	_, ok := matchGlob(s.Pkg, path, true)
+	return ok
+}
+
+// MatchesName reports whether a symbol called name could be described by s, going by its name alone.
+func (s SymbolSpec) MatchesName(name string) bool {
+	_, ok := matchGlob(s.name, name, false)
+	return ok
+}
+
+// matchGlob reports whether s matches pattern, and returns what each wildcard in pattern matched. A *
+// matches as little as it can. If path is set, wildcards don't match slashes.
+func matchGlob(pattern, s string, path bool) ([]string, bool) {
+	if !strings.ContainsAny(pattern, wildcards) {
+		return nil, pattern == s
+	}
+	if pattern == "" {
+		return nil, s == ""
+	}
+
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(s); i++ {
+			if i > 0 && path && s[i-1] == '/' {
+				break
+			}
+			if rest, ok := matchGlob(pattern[1:], s[i:], path); ok {
+				return append([]string{s[:i]}, rest...), true
+			}
+		}
+		return nil, false
+	case '?':
+		r, size := utf8.DecodeRuneInString(s)
+		if s == "" || path && r == '/' {
+			return nil, false
+		}
+		rest, ok := matchGlob(pattern[1:], s[size:], path)
+		if !ok {
+			return nil, false
+		}
+		return append([]string{s[:size]}, rest...), true
+	default:
+		if s == "" || s[0] != pattern[0] {
+			return nil, false
+		}
+		return matchGlob(pattern[1:], s[1:], path)
+	}
+}
+
+// Recv returns the name of the type the method described by s is declared on, or "" if s describes a
+// package-level symbol.
+func (s SymbolSpec) Recv() string {
+	return s.recv
+}
+
+// RecvTypeParams returns the names of the receiver's type parameters given in the spec, like K and V
+// in pkg.Map[K,V].Get. They're optional, so they're only checked when given.
+func (s SymbolSpec) RecvTypeParams() []string {
+	if s.recvTypeParams == "" {
+		return nil
+	}
+
+	params := strings.Split(s.recvTypeParams, ",")
+	for i, p := range params {
+		params[i] = strings.TrimSpace(p)
 	}
+	return params
+}
+
+// String returns s in the form accepted by ParseSymbolSpec. The package path is quoted when its last
+// element contains a dot, which would otherwise be read as the end of the path.
+func (s SymbolSpec) String() string {
+	sb := &strings.Builder{}
+
+	if strings.Contains(s.Pkg[strings.LastIndex(s.Pkg, "/")+1:], ".") {
+		sb.WriteString(strconv.Quote(s.Pkg))
+	} else {
+		sb.WriteString(s.Pkg)
+	}
+	sb.WriteString(".")
 
-	return recvTyp.Obj().Pkg().Path() == s.Pkg && recvTyp.Obj().Name() == s.recv
+	if s.recv != "" {
+		recv := s.recv
+		if s.recvTypeParams != "" {
+			recv += "[" + s.recvTypeParams + "]"
+		}
+		if s.ptrRecv {
+			recv = "(*" + recv + ")"
+		}
+		sb.WriteString(recv + ".")
+	}
+
+	sb.WriteString(s.name)
+	if s.typeArgs != "" {
+		sb.WriteString("[" + s.typeArgs + "]")
+	}
+
+	return sb.String()
 }
 
+// specForms is appended to errors about malformed specs.
+const specForms = `; expected <package path>.<name> or <package path>.<type>.<method>, like example.com/pkg.F, "gopkg.in/yaml.v3".Marshal, example.com/pkg.(*T).M or example.com/pkg.Map[K,V].Get`
+
+// ParseSymbolSpec parses the description of a symbol. Its grammar is:
+//
+//	spec     = path "." [ recv "." ] name [ "[" typeArgs "]" ] .
+//	path     = quoted-path | unquoted-path .
+//	recv     = type | "(*" type ")" .
+//	type     = identifier [ "[" typeParams "]" ] .
+//
+// An unquoted path ends at the first dot after its last slash, so a path whose last element contains
+// a dot, like gopkg.in/yaml.v3, has to be quoted. typeArgs are only meaningful for the replacement of a
+// type, like pkg.Map[$T1, $T0].
+//
+// The path, receiver and name may contain the wildcards * and ?, making the spec a pattern for a
+// family of functions, like github.com/stretchr/testify/assert.*f or example.com/old.Client.*.
 func ParseSymbolSpec(input string) (SymbolSpec, error) {
-	dot := strings.LastIndex(input, ".")
+	p := &specParser{src: input}
+	return p.parse()
+}
+
+type specParser struct {
+	src string
+	pos int
+}
+
+func (p *specParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid spec %q at column %d: %s", p.src, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *specParser) parse() (SymbolSpec, error) {
+	var spec SymbolSpec
+
+	pkg, err := p.parsePath()
+	if err != nil {
+		return SymbolSpec{}, err
+	}
+	spec.Pkg = pkg
+
+	err = p.expect('.', "after the package path")
+	if err != nil {
+		return SymbolSpec{}, err
+	}
+
+	if strings.HasPrefix(p.src[p.pos:], "(*") {
+		p.pos += len("(*")
+		spec.ptrRecv = true
+
+		spec.recv, spec.recvTypeParams, err = p.parseType()
+		if err != nil {
+			return SymbolSpec{}, err
+		}
+
+		err = p.expect(')', "after the receiver")
+		if err != nil {
+			return SymbolSpec{}, err
+		}
+		err = p.expect('.', "after the receiver")
+		if err != nil {
+			return SymbolSpec{}, err
+		}
+	}
+
+	name, brackets, err := p.parseType()
+	if err != nil {
+		return SymbolSpec{}, err
+	}
+
+	if !spec.ptrRecv && p.pos < len(p.src) && p.src[p.pos] == '.' {
+		// What was parsed is the receiver, and the method's name follows.
+		p.pos++
+		spec.recv, spec.recvTypeParams = name, brackets
+
+		name, brackets, err = p.parseType()
+		if err != nil {
+			return SymbolSpec{}, err
+		}
+	}
+	spec.name, spec.typeArgs = name, brackets
+
+	if spec.recv != "" && spec.typeArgs != "" {
+		return SymbolSpec{}, fmt.Errorf("invalid spec %q: a method can't have type arguments", p.src)
+	}
+	if p.pos < len(p.src) {
+		return SymbolSpec{}, p.errorf("unexpected %q after the name%s", p.src[p.pos:], specForms)
+	}
+
+	return spec, nil
+}
+
+// parsePath parses the package path, which is either quoted or ends at the first dot after its last
+// slash.
+func (p *specParser) parsePath() (string, error) {
+	if strings.HasPrefix(p.src, `"`) {
+		end := strings.IndexByte(p.src[1:], '"')
+		if end == -1 {
+			return "", p.errorf("the quoted package path is never closed")
+		}
+		p.pos = end + 2
+
+		path := p.src[1 : end+1]
+		if path == "" {
+			return "", fmt.Errorf("invalid spec %q: the package path is empty", p.src)
+		}
+		return path, nil
+	}
+
+	// Slashes in brackets, or after the receiver, aren't part of the path.
+	limit := len(p.src)
+	if i := strings.IndexAny(p.src, "[("); i != -1 {
+		limit = i
+	}
+
+	lastElem := strings.LastIndex(p.src[:limit], "/") + 1
+	dot := strings.IndexByte(p.src[lastElem:limit], '.')
 	if dot == -1 {
-		return SymbolSpec{}, errors.New("spec must be of form <package path>.<receiver (optional)>.<name>")
+		return "", fmt.Errorf("invalid spec %q: no name after the package path%s", p.src, specForms)
 	}
+	if lastElem+dot == 0 {
+		return "", fmt.Errorf("invalid spec %q: the package path is empty", p.src)
+	}
+
+	p.pos = lastElem + dot
+	return p.src[:p.pos], nil
+}
 
-	rest, name := input[:dot], input[dot+1:]
-	slash := strings.LastIndex(rest, "/")
-	dot = strings.LastIndex(rest, ".")
+// parseType parses a name, optionally followed by bracketed type parameters or arguments, which are
+// returned without their brackets.
+func (p *specParser) parseType() (string, string, error) {
+	start := p.pos
+	name, _ := takeWhile(p.src[p.pos:], func(r rune) bool {
+		return isIdentRune(r) || strings.ContainsRune(wildcards, r)
+	})
+	if !isNamePattern(name) {
+		if next, _ := takeWhile(p.src[p.pos:], func(r rune) bool { return r != '.' && r != '[' }); next != "" {
+			return "", "", p.errorf("%q is not a valid name%s", next, specForms)
+		}
+		return "", "", p.errorf("expected a name%s", specForms)
+	}
+	p.pos += len(name)
 
-	if slash > dot {
-		// The only dots are in the package path, there is no receiver name.
-		return SymbolSpec{
-			Pkg:  rest,
-			name: name,
-		}, nil
+	if p.pos == len(p.src) || p.src[p.pos] != '[' {
+		return name, "", nil
+	}
+
+	depth := 0
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				brackets := p.src[p.pos+1 : i]
+				if strings.TrimSpace(brackets) == "" {
+					return "", "", p.errorf("empty brackets after %s", name)
+				}
+				p.pos = i + 1
+				return name, brackets, nil
+			}
+		}
+	}
+
+	p.pos = start + len(name)
+	return "", "", p.errorf("the bracket after %s is never closed", name)
+}
+
+// isNamePattern reports whether name is an identifier, or would be if its wildcards were letters.
+func isNamePattern(name string) bool {
+	return token.IsIdentifier(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(wildcards, r) {
+			return 'x'
+		}
+		return r
+	}, name))
+}
+
+// expect consumes c, which has to come next, as described by where.
+func (p *specParser) expect(c byte, where string) error {
+	if p.pos == len(p.src) {
+		return p.errorf("expected %c %s but the spec ended%s", c, where, specForms)
+	}
+	if p.src[p.pos] != c {
+		return p.errorf("expected %c %s but got %q%s", c, where, p.src[p.pos:], specForms)
 	}
 
-	pkg, recv := rest[:dot], rest[dot+1:]
-	return SymbolSpec{
-		Pkg:  pkg,
-		recv: recv,
-		name: name,
-	}, nil
+	p.pos++
+	return nil
 }
@@ -0,0 +1,178 @@
+package replace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSymbolSpec(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   SymbolSpec
+		// str is what the spec is printed as, if it isn't input.
+		str string
+	}{{
+		input: "fmt.Println",
+		exp:   SymbolSpec{Pkg: "fmt", name: "Println"},
+	}, {
+		input: "example.com/pkg.F",
+		exp:   SymbolSpec{Pkg: "example.com/pkg", name: "F"},
+	}, {
+		input: "example.com/pkg.T.M",
+		exp:   SymbolSpec{Pkg: "example.com/pkg", recv: "T", name: "M"},
+	}, {
+		input: "example.com/pkg.t.m",
+		exp:   SymbolSpec{Pkg: "example.com/pkg", recv: "t", name: "m"},
+	}, {
+		input: `"gopkg.in/yaml.v3".Marshal`,
+		exp:   SymbolSpec{Pkg: "gopkg.in/yaml.v3", name: "Marshal"},
+	}, {
+		input: `"example.com/pkg".T.M`,
+		exp:   SymbolSpec{Pkg: "example.com/pkg", recv: "T", name: "M"},
+		str:   "example.com/pkg.T.M",
+	}, {
+		input: "gopkg.in/yaml.v3.Marshal",
+		// Without quotes, the path ends at the first dot after the last slash.
+		exp: SymbolSpec{Pkg: "gopkg.in/yaml", recv: "v3", name: "Marshal"},
+	}, {
+		input: "example.com/pkg.(*T).M",
+		exp:   SymbolSpec{Pkg: "example.com/pkg", recv: "T", ptrRecv: true, name: "M"},
+	}, {
+		input: "example.com/pkg.Map[K,V].Get",
+		exp:   SymbolSpec{Pkg: "example.com/pkg", recv: "Map", recvTypeParams: "K,V", name: "Get"},
+	}, {
+		input: `"example.com/pkg.v2".(*Map[K, V]).Get`,
+		exp:   SymbolSpec{Pkg: "example.com/pkg.v2", recv: "Map", recvTypeParams: "K, V", ptrRecv: true, name: "Get"},
+	}, {
+		input: "example.com/pkg.Map[$T1, example.com/other.T[$T0]]",
+		exp:   SymbolSpec{Pkg: "example.com/pkg", name: "Map", typeArgs: "$T1, example.com/other.T[$T0]"},
+	}, {
+		input: "github.com/stretchr/testify/assert.*f",
+		exp:   SymbolSpec{Pkg: "github.com/stretchr/testify/assert", name: "*f"},
+	}, {
+		input: "example.com/*/client.Client.*",
+		exp:   SymbolSpec{Pkg: "example.com/*/client", recv: "Client", name: "*"},
+	}, {
+		input: "example.com/pkg.(*?).Get*",
+		exp:   SymbolSpec{Pkg: "example.com/pkg", recv: "?", ptrRecv: true, name: "Get*"},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			spec, err := ParseSymbolSpec(tc.input)
+			require.NoError(t, err)
+			assert.Equal(t, tc.exp, spec)
+
+			str := tc.str
+			if str == "" {
+				str = tc.input
+			}
+			assert.Equal(t, str, spec.String())
+
+			reparsed, err := ParseSymbolSpec(spec.String())
+			require.NoError(t, err)
+			assert.Equal(t, spec, reparsed)
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		s        string
+		path     bool
+		matches  bool
+		captures []string
+	}{
+		{pattern: "Equal", s: "Equal", matches: true},
+		{pattern: "Equal", s: "Equalf"},
+		{pattern: "*f", s: "Equalf", matches: true, captures: []string{"Equal"}},
+		{pattern: "*f", s: "Equal"},
+		{pattern: "*f*", s: "Failf", matches: true, captures: []string{"Fail", ""}},
+		{pattern: "Get?", s: "GetX", matches: true, captures: []string{"X"}},
+		{pattern: "Get?", s: "Get"},
+		{pattern: "?é", s: "éé", matches: true, captures: []string{"é"}},
+		{pattern: "example.com/*", s: "example.com/a", path: true, matches: true, captures: []string{"a"}},
+		{pattern: "example.com/*", s: "example.com/a/b", path: true},
+		{pattern: "example.com/*", s: "example.com/a/b", matches: true, captures: []string{"a/b"}},
+		{pattern: "example.com/?/b", s: "example.com///b", path: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern+" "+tc.s, func(t *testing.T) {
+			captures, ok := matchGlob(tc.pattern, tc.s, tc.path)
+			assert.Equal(t, tc.matches, ok)
+			assert.Equal(t, tc.captures, captures)
+		})
+	}
+}
+
+func TestSymbolSpec_Wildcards(t *testing.T) {
+	for input, n := range map[string]int{
+		"example.com/pkg.F":          0,
+		"example.com/pkg.*f":         1,
+		"example.com/*/pkg.(*?).*":   3,
+		"example.com/pkg.Map[K,V].*": 1,
+	} {
+		spec, err := ParseSymbolSpec(input)
+		require.NoError(t, err)
+		assert.Equal(t, n, spec.Wildcards(), input)
+		assert.Equal(t, n > 0, spec.IsPattern(), input)
+	}
+}
+
+func TestParseSymbolSpec_Errors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expErr string
+	}{{
+		input:  "Println",
+		expErr: `invalid spec "Println": no name after the package path`,
+	}, {
+		input:  ".F",
+		expErr: `invalid spec ".F": the package path is empty`,
+	}, {
+		input:  "example.com/pkg.",
+		expErr: `invalid spec "example.com/pkg." at column 17: expected a name`,
+	}, {
+		input:  `"gopkg.in/yaml.v3.Marshal`,
+		expErr: `invalid spec "\"gopkg.in/yaml.v3.Marshal" at column 1: the quoted package path is never closed`,
+	}, {
+		input:  `"gopkg.in/yaml.v3"Marshal`,
+		expErr: `invalid spec "\"gopkg.in/yaml.v3\"Marshal" at column 19: expected . after the package path but got "Marshal"`,
+	}, {
+		input:  "example.com/pkg.(*T.M",
+		expErr: `invalid spec "example.com/pkg.(*T.M" at column 20: expected ) after the receiver but got ".M"`,
+	}, {
+		input:  "example.com/pkg.(*T)",
+		expErr: `invalid spec "example.com/pkg.(*T)" at column 21: expected . after the receiver but the spec ended`,
+	}, {
+		input:  "example.com/pkg.Map[K,V.Get",
+		expErr: `invalid spec "example.com/pkg.Map[K,V.Get" at column 20: the bracket after Map is never closed`,
+	}, {
+		input:  "example.com/pkg.T.M.N",
+		expErr: `invalid spec "example.com/pkg.T.M.N" at column 20: unexpected ".N" after the name`,
+	}, {
+		input:  "example.com/pkg.T-1",
+		expErr: `invalid spec "example.com/pkg.T-1" at column 18: unexpected "-1" after the name`,
+	}, {
+		input:  "example.com/pkg.1T",
+		expErr: `invalid spec "example.com/pkg.1T" at column 17: "1T" is not a valid name`,
+	}, {
+		input:  "example.com/pkg.T.M[int]",
+		expErr: `invalid spec "example.com/pkg.T.M[int]": a method can't have type arguments`,
+	}, {
+		input:  "example.com/pkg.1*",
+		expErr: `invalid spec "example.com/pkg.1*" at column 17: "1*" is not a valid name`,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := ParseSymbolSpec(tc.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr)
+		})
+	}
+}
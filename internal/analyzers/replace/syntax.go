@@ -0,0 +1,127 @@
+package replace
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// A replacement is only text until its metavariables are expanded at each call site, so mistakes in
+// its syntax would only show up as broken code in the output. checkSyntax expands the metavariables to
+// placeholder names instead, and parses the result before any package is loaded.
+
+// syntaxSegment is where the expansion of one replacer ended up in the placeholder source.
+type syntaxSegment struct {
+	start, end int
+	offset     int
+	constant   bool
+}
+
+// placeholderSource expands the replacement with placeholder names for the metavariables, writing it
+// to sb and recording where each replacer went.
+func (pr parsedReplacement) placeholderSource(sb *strings.Builder, segments *[]syntaxSegment) {
+	for i, r := range pr.replacers {
+		start := sb.Len()
+		switch r := r.(type) {
+		case constantReplacer:
+			sb.WriteString(string(r))
+		case argReplacer:
+			sb.WriteString("_arg" + strconv.Itoa(r.index))
+		case argsReplacer:
+			sb.WriteString("_args")
+		case matchReplacer:
+			sb.WriteString("_match" + strconv.Itoa(r.index))
+		case nameReplacer:
+			sb.WriteString("_name")
+		case recvTypeReplacer:
+			sb.WriteString("_recvtype")
+		case recvReplacer:
+			sb.WriteString("_recv")
+			if r.dot {
+				sb.WriteString(".")
+			}
+		case packageReplacer:
+			sb.WriteString(r.name)
+		case lhsReplacer:
+			sb.WriteString("_lhs")
+		case returnReplacer:
+			sb.WriteString("return ")
+			r.value.placeholderSource(sb, segments)
+		}
+
+		*segments = append(*segments, syntaxSegment{
+			start:    start,
+			end:      sb.Len(),
+			offset:   pr.offsets[i],
+			constant: isConstant(r),
+		})
+	}
+}
+
+func isConstant(r replacer) bool {
+	_, ok := r.(constantReplacer)
+	return ok
+}
+
+// checkSyntax checks that the replacement, whose source is src, parses as a list of expressions (or a
+// list of statements, for statement replacements) once its metavariables are expanded. A list of
+// expressions is parsed as the right-hand side of an assignment, which is where a replacement like
+// "$arg0, $arg1" ends up.
+func (pr parsedReplacement) checkSyntax(src string) error {
+	sb := &strings.Builder{}
+	var segments []syntaxSegment
+
+	if pr.stmt {
+		sb.WriteString("package p; func _() {\n")
+	} else {
+		sb.WriteString("package p; func _() {\n_ = ")
+	}
+	pr.placeholderSource(sb, &segments)
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", sb.String()+"\n}", parser.SkipObjectResolution)
+
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		if err == nil && !pr.stmt && !isSingleStmt(f) {
+			return &TemplateError{
+				Template: src,
+				Offset:   len(src),
+				Msg:      "the replacement is not valid Go: expected an expression or a list of expressions",
+			}
+		}
+		return err
+	}
+
+	return &TemplateError{
+		Template: src,
+		Offset:   sourceOffset(segments, list[0].Pos.Offset, len(src)),
+		Msg:      "the replacement is not valid Go: " + list[0].Msg,
+	}
+}
+
+// isSingleStmt reports whether f has a single function with a single statement in it, which isn't the
+// case when a replacement that should be an expression ends one statement and starts another.
+func isSingleStmt(f *ast.File) bool {
+	if len(f.Decls) != 1 {
+		return false
+	}
+	fd, ok := f.Decls[0].(*ast.FuncDecl)
+	return ok && len(fd.Body.List) == 1
+}
+
+// sourceOffset maps an offset in the placeholder source back to the replacement. Offsets in text map
+// to the same character, and offsets in an expanded metavariable map to its $.
+func sourceOffset(segments []syntaxSegment, offset, srcLen int) int {
+	for _, s := range segments {
+		if s.start <= offset && offset < s.end {
+			if s.constant {
+				return s.offset + offset - s.start
+			}
+			return s.offset
+		}
+	}
+	return srcLen
+}
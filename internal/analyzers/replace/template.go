@@ -0,0 +1,309 @@
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Template is a replacecall rule derived from a Go file declaring a before and an after function.
+// For example, the following template replaces calls to old.F with calls to newpkg.G, swapping the
+// order of the arguments:
+//
+//	func before(a string, b int) T { return old.F(a, b) }
+//	func after(a string, b int) T { return newpkg.G(b, a) }
+//
+// Because the template is real Go code, it's type-checked before any rule is derived from it.
+type Template struct {
+	// Func is the symbol spec of the function called by before.
+	Func string
+	// Replacement is the replacement string derived from the body of after.
+	Replacement string
+}
+
+// LoadTemplate type-checks the template file at path and derives a replacecall rule from it.
+func LoadTemplate(path string) (Template, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Template{}, err
+	}
+
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return Template{}, err
+	}
+
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: analyzeutil.LoadMode,
+		Dir:  filepath.Dir(abs),
+	}, abs)
+	if err != nil {
+		return Template{}, err
+	}
+
+	if len(pkgs) != 1 || len(pkgs[0].Syntax) != 1 {
+		return Template{}, fmt.Errorf("template %s: expected a single file", path)
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.Errors) != 0 {
+		errs := make([]error, 0, len(pkg.Errors))
+		for _, e := range pkg.Errors {
+			errs = append(errs, e)
+		}
+
+		return Template{}, fmt.Errorf("template %s does not type-check: %w", path, errors.Join(errs...))
+	}
+
+	t := &templateFile{
+		fset: pkg.Fset,
+		pkg:  pkg.Types,
+		info: pkg.TypesInfo,
+		src:  src,
+	}
+
+	rule, err := t.derive(pkg.Syntax[0])
+	if err != nil {
+		return Template{}, fmt.Errorf("template %s: %w", path, err)
+	}
+
+	return rule, nil
+}
+
+type templateFile struct {
+	fset *token.FileSet
+	pkg  *types.Package
+	info *types.Info
+	src  []byte
+}
+
+func (t *templateFile) derive(f *ast.File) (Template, error) {
+	before, after := findFunc(f, "before"), findFunc(f, "after")
+	if before == nil || after == nil {
+		return Template{}, errors.New("template must declare both a before and an after function")
+	}
+
+	beforeSig := t.info.Defs[before.Name].Type().(*types.Signature)
+	afterSig := t.info.Defs[after.Name].Type().(*types.Signature)
+	if !types.Identical(beforeSig, afterSig) {
+		return Template{}, errors.New("before and after must have identical signatures")
+	}
+
+	beforeExpr, err := singleReturn(before)
+	if err != nil {
+		return Template{}, err
+	}
+
+	call, ok := ast.Unparen(beforeExpr).(*ast.CallExpr)
+	if !ok {
+		return Template{}, errors.New("before must return the result of a function call")
+	}
+
+	if call.Ellipsis.IsValid() {
+		return Template{}, errors.New("before must not call a variadic function with ...")
+	}
+
+	placeholders := make(map[types.Object]string, beforeSig.Params().Len())
+
+	var fn *types.Func
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.SelectorExpr:
+		fn, _ = t.info.Uses[fun.Sel].(*types.Func)
+		if fn == nil {
+			return Template{}, errors.New("before must call a function or method")
+		}
+
+		if _, isMethod := t.info.Selections[fun]; isMethod {
+			recv, err := t.paramOf(fun.X, beforeSig)
+			if err != nil {
+				return Template{}, fmt.Errorf("receiver of the before call: %w", err)
+			}
+			placeholders[recv] = "$recv"
+		}
+	default:
+		return Template{}, errors.New("before must call a function declared in another package")
+	}
+
+	for i, arg := range call.Args {
+		param, err := t.paramOf(arg, beforeSig)
+		if err != nil {
+			return Template{}, fmt.Errorf("argument %d of the before call: %w", i, err)
+		}
+
+		if _, ok := placeholders[param]; ok {
+			return Template{}, fmt.Errorf("parameter %s is used more than once in before", param.Name())
+		}
+		placeholders[param] = "$arg" + strconv.Itoa(i)
+	}
+
+	afterExpr, err := singleReturn(after)
+	if err != nil {
+		return Template{}, err
+	}
+
+	// before and after have distinct parameter objects; key after's by position so they line up with
+	// the placeholders we derived from before.
+	afterPlaceholders := make(map[types.Object]string, len(placeholders))
+	for i := range beforeSig.Params().Len() {
+		if p, ok := placeholders[beforeSig.Params().At(i)]; ok {
+			afterPlaceholders[afterSig.Params().At(i)] = p
+		}
+	}
+
+	replacement, err := t.printAfter(afterExpr, afterPlaceholders)
+	if err != nil {
+		return Template{}, err
+	}
+
+	return Template{
+		Func:        SymbolSpecOf(fn).String(),
+		Replacement: replacement,
+	}, nil
+}
+
+func (t *templateFile) paramOf(e ast.Expr, sig *types.Signature) (*types.Var, error) {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	if !ok {
+		return nil, errors.New("must be a parameter of before")
+	}
+
+	v, ok := t.info.Uses[id].(*types.Var)
+	if !ok {
+		return nil, errors.New("must be a parameter of before")
+	}
+
+	for i := range sig.Params().Len() {
+		if sig.Params().At(i) == v {
+			return v, nil
+		}
+	}
+
+	return nil, errors.New("must be a parameter of before")
+}
+
+// printAfter prints the expression returned by after as a replacement string. Parameters are
+// swapped for their metavariables and package-qualified identifiers for $pkg metavariables so that
+// the imports they need are added at each call site.
+func (t *templateFile) printAfter(e ast.Expr, placeholders map[types.Object]string) (string, error) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+
+	var edits []edit
+	var err error
+	ast.Inspect(e, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			x, ok := n.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+
+			pkgName, ok := t.info.Uses[x].(*types.PkgName)
+			if !ok {
+				return true
+			}
+
+			path := pkgName.Imported().Path()
+			text := "$pkg(" + path + "," + pkgName.Name() + ")"
+			if pkgName.Name() != pkgName.Imported().Name() {
+				text = "$pkg(" + path + "," + pkgName.Name() + "," + pkgName.Name() + ")"
+			}
+
+			edits = append(edits, edit{
+				start: t.offset(x.Pos()),
+				end:   t.offset(x.End()),
+				text:  text,
+			})
+			return false
+		case *ast.Ident:
+			obj := t.info.Uses[n]
+			if obj == nil {
+				return true
+			}
+
+			if p, ok := placeholders[obj]; ok {
+				edits = append(edits, edit{
+					start: t.offset(n.Pos()),
+					end:   t.offset(n.End()),
+					text:  p,
+				})
+				return true
+			}
+
+			if obj.Pkg() == t.pkg && obj.Parent() != nil && !isLocalTo(obj, e) {
+				err = fmt.Errorf("after refers to %s, which is not available at the call site", n.Name)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	start, end := t.offset(e.Pos()), t.offset(e.End())
+
+	slices.SortFunc(edits, func(a, b edit) int { return a.start - b.start })
+
+	// A $ in the source, like in a string literal, would start a metavariable.
+	escape := strings.NewReplacer("$", "$$")
+
+	sb := &strings.Builder{}
+	curr := start
+	for _, ed := range edits {
+		escape.WriteString(sb, string(t.src[curr:ed.start]))
+		sb.WriteString(ed.text)
+		curr = ed.end
+	}
+	escape.WriteString(sb, string(t.src[curr:end]))
+
+	return sb.String(), nil
+}
+
+func (t *templateFile) offset(pos token.Pos) int {
+	return t.fset.File(pos).Offset(pos)
+}
+
+// isLocalTo reports whether obj is declared within n, like a parameter of a function literal.
+func isLocalTo(obj types.Object, n ast.Node) bool {
+	return n.Pos() <= obj.Pos() && obj.Pos() < n.End()
+}
+
+func findFunc(f *ast.File, name string) *ast.FuncDecl {
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if ok && fd.Recv == nil && fd.Name.Name == name {
+			return fd
+		}
+	}
+	return nil
+}
+
+func singleReturn(fd *ast.FuncDecl) (ast.Expr, error) {
+	if fd.Body == nil || len(fd.Body.List) != 1 {
+		return nil, fmt.Errorf("%s must consist of a single return statement", fd.Name.Name)
+	}
+
+	ret, ok := fd.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return nil, fmt.Errorf("%s must consist of a single return statement", fd.Name.Name)
+	}
+
+	return ret.Results[0], nil
+}
@@ -0,0 +1,40 @@
+package argcomments
+
+import "fmt"
+
+func ReplaceMe(a string, b int) bool {
+	return false
+}
+
+func Replaced(b int, a string) bool {
+	return false
+}
+
+func multiline() {
+	r := ReplaceMe( // want `(?s)ReplaceMe\(.*fmt.Sprint\("abc"\),.*5,.*\) => Replaced\(`
+		// The name of the thing.
+		fmt.Sprint("abc"), // TODO: check this
+		/* timeout */ 5,
+	)
+	_ = r
+}
+
+func lastArgComment() {
+	_ = ReplaceMe( // want `(?s)ReplaceMe\(.*name,.*5,.*\) => Replaced\(`
+		name,
+		5, // seconds
+	)
+}
+
+func singleLine() {
+	_ = ReplaceMe( /* name */ "x", 5 /* seconds */) // want `ReplaceMe\("x", 5\) => Replaced\(5 /\* seconds \*/ /\* name \*/, "x"\)`
+}
+
+func multilineArg() {
+	_ = ReplaceMe(fmt.Sprint( // want `(?s)ReplaceMe\(fmt.Sprint\(.*\), 5\) => Replaced\(5, fmt.Sprint\(`
+		"abc", // the name
+		1,
+	), 5)
+}
+
+var name = "name"
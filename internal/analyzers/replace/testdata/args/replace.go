@@ -7,6 +7,6 @@ func ReplaceMe(a string, b bool) bool {
 }
 
 func foobar() {
-	r := ReplaceMe(fmt.Sprint("abc"), true != false) // want "replace the function call"
+	r := ReplaceMe(fmt.Sprint("abc"), true != false) // want `ReplaceMe\(fmt.Sprint\("abc"\), true != false\) => Replaced\(true != false, fmt.Sprint\("abc"\)\)`
 	_ = r
 }
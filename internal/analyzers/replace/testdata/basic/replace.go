@@ -5,6 +5,6 @@ func ReplaceMe(a, b, c int) bool {
 }
 
 func foobar() {
-	r := ReplaceMe(1, 2, 3) // want "replace the function call"
+	r := ReplaceMe(1, 2, 3) // want `ReplaceMe\(1, 2, 3\) => ExampleReplacement`
 	_ = r
 }
@@ -0,0 +1,5 @@
+package old
+
+type Client struct{}
+
+func NewClient(addr string) *Client { return &Client{} }
@@ -0,0 +1,11 @@
+package use
+
+import (
+	"test.com/module/check/old"
+)
+
+func Dial(addr string) *old.Client { // want `old-client: old.Client => newer.Client`
+	return old.NewClient(addr) // want `old-constructor: use newer.New instead \(old.NewClient\(addr\) => newer.New\(addr\)\)`
+}
+
+func Close(c *old.Client) {} // want `old-client: old.Client => newer.Client`
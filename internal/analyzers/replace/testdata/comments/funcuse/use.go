@@ -0,0 +1,10 @@
+package funcuse
+
+import "test.com/module/comments/old" // want "modifying imports"
+
+// Connect calls [old.Dial]. // want `old\.D\w+ => newer\.D\w+`
+//
+// Dial may fail. // want `comment mentions D\w+`
+func Connect(addr string) *old.Client {
+	return old.Dial(addr) // want `old\.D\w+\(addr\) => newer\.D\w+\(addr\)`
+}
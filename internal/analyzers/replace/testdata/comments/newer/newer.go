@@ -0,0 +1,13 @@
+package newer
+
+type Client struct {
+	Addr string
+}
+
+func (c *Client) Host() string {
+	return c.Addr
+}
+
+func Dial(addr string) *Client {
+	return &Client{Addr: addr}
+}
@@ -0,0 +1,21 @@
+package old
+
+// Client talks to the server. A Client isn't safe for concurrent use.
+type Client struct {
+	Addr string
+}
+
+// Host returns the host the Client talks to.
+func (c *Client) Host() string {
+	return c.Addr
+}
+
+// Dial returns a Client for addr. Dial doesn't connect.
+func Dial(addr string) *Client {
+	return &Client{Addr: addr}
+}
+
+// MustDial is like [Dial], but panics. // want `D\w+ => test\.com/module/comments/newer\.D\w+`
+func MustDial(addr string) *Client {
+	return &Client{Addr: addr}
+}
@@ -0,0 +1,10 @@
+package typeuse
+
+import "test.com/module/comments/old" // want "modifying imports"
+
+// Connect takes an [old.Client] or a [*old.Client]. // want `old\.C\w+ => newer\.C\w+` `\*old\.C\w+ => \*newer\.C\w+`
+// See [old.Client.Host] and [test.com/module/comments/old.Client]. // want `old\.C\w+\.Host => newer\.C\w+\.Host` `old\.C\w+ => newer\.C\w+`
+// [old.Dial] and [Connect] are left alone.
+//
+// The Client is cached. // want `comment mentions C\w+`
+func Connect(c *old.Client) {} // want `old\.C\w+ => newer\.C\w+`
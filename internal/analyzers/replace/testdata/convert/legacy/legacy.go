@@ -0,0 +1,9 @@
+package legacy
+
+func NextID() int {
+	return 1
+}
+
+func Key() string {
+	return "key"
+}
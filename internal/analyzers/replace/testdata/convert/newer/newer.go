@@ -0,0 +1,3 @@
+package newer
+
+type ID int64
@@ -0,0 +1,38 @@
+package convert
+
+import (
+	"strconv"
+
+	"test.com/module/convert/legacy"
+	"test.com/module/convert/newer"
+)
+
+type User struct {
+	ID newer.ID
+}
+
+func Load() User {
+	return User{ID: legacy.NextID()} // want `legacy.NextID\(\) => newer.ID\(legacy.NextID\(\)\)`
+}
+
+func Set(u *User) {
+	u.ID = legacy.NextID() // want `legacy.NextID\(\) => newer.ID\(legacy.NextID\(\)\)`
+}
+
+func SetKey(u *User) {
+	u.ID = legacy.Key() // want `cannot convert string to newer.ID`
+}
+
+func Format(u User) string {
+	return strconv.Itoa(u.ID) // want `u.ID => int\(u.ID\)`
+}
+
+func Next() newer.ID {
+	var n int = legacy.NextID()
+	return n // want `n => newer.ID\(n\)`
+}
+
+func Untouched(u User) newer.ID {
+	var id newer.ID = 3
+	return id + u.ID
+}
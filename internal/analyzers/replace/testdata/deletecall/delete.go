@@ -0,0 +1,21 @@
+package deletecall
+
+import ( // want "modifying imports"
+	"fmt"
+
+	"test.com/module/deletecall/metrics"
+)
+
+func a() {
+	metrics.Register("a") // want `delete metrics.Register\("a"\)`
+	fmt.Println("hi")
+}
+
+func b() {
+	defer metrics.Register("b") // want `delete defer metrics.Register\("b"\)`
+	go metrics.Register("c")    // want `delete go metrics.Register\("c"\)`
+
+	if true {
+		metrics.Register("d") // want `delete metrics.Register\("d"\)`
+	}
+}
@@ -0,0 +1,9 @@
+package metrics
+
+func Register(name string) error {
+	return nil
+}
+
+type Tracer struct{}
+
+func (t *Tracer) Stop() {}
@@ -0,0 +1,7 @@
+package deletecall
+
+import "test.com/module/deletecall/metrics" // want "modifying imports"
+
+func d() {
+	metrics.Register("e") // want `delete metrics.Register\("e"\)`
+}
@@ -0,0 +1,15 @@
+package tracing
+
+import (
+	"test.com/module/deletecall/metrics"
+)
+
+func run(t *metrics.Tracer) {
+	defer t.Stop() // want `delete defer t.Stop\(\)`
+}
+
+func newTracer() *metrics.Tracer { return &metrics.Tracer{} }
+
+func start() {
+	newTracer().Stop() // want "not deleting call: its receiver or arguments have side effects"
+}
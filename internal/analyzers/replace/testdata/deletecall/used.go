@@ -0,0 +1,26 @@
+package deletecall
+
+import (
+	"fmt"
+
+	"test.com/module/deletecall/metrics"
+)
+
+func c() error {
+	err := metrics.Register("c")       // want "not deleting call: its result is used"
+	fmt.Println(metrics.Register("d")) // want "not deleting call: its result is used"
+	return err
+}
+
+func name() string { return "e" }
+
+func sideEffects() {
+	metrics.Register(name())              // want "not deleting call: its receiver or arguments have side effects"
+	defer metrics.Register(fmt.Sprint(1)) // want "not deleting call: its receiver or arguments have side effects"
+}
+
+func labeled() {
+loop:
+	metrics.Register("f") // want "not deleting call: the statement is labeled"
+	goto loop
+}
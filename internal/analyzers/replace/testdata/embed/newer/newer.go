@@ -0,0 +1,5 @@
+package newer
+
+type Log struct{}
+
+func (Log) Info(msg string) {}
@@ -0,0 +1,6 @@
+package old
+
+type Logger struct{}
+
+func (Logger) Info(msg string)  {}
+func (Logger) Debug(msg string) {}
@@ -0,0 +1,31 @@
+package embed
+
+import (
+	"test.com/module/embed/newer"
+	"test.com/module/embed/old"
+)
+
+var _ newer.Log
+
+type Service struct {
+	old.Logger // want "embedding Log instead of Logger no longer promotes Debug" `old.Logger => newer.Log`
+	Name       string
+}
+
+type PtrService struct {
+	*old.Logger // want "embedding Log instead of Logger no longer promotes Debug" `old.Logger => newer.Log`
+}
+
+func New() Service {
+	return Service{Logger: old.Logger{}, Name: "x"} // want `Logger => Log` `old.Logger => newer.Log`
+}
+
+func Use(s Service) {
+	s.Logger.Info("a") // want `Logger => Log`
+	s.Info("b")
+	s.Debug("c") // want "Debug is promoted from Logger, which Log doesn't have"
+}
+
+func Reset(p *PtrService) {
+	p.Logger = nil // want `Logger => Log`
+}
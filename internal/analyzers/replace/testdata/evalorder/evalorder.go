@@ -0,0 +1,37 @@
+package evalorder
+
+import "strconv"
+
+func ReplaceMe(name string, n int) int {
+	return 0
+}
+
+func Replaced(n int, name string) int {
+	return 0
+}
+
+func next() int {
+	return 0
+}
+
+func reordered() {
+	r := ReplaceMe(strconv.Itoa(1), next()) // want `r := ReplaceMe\(strconv.Itoa\(1\), next\(\)\) => name := strconv.Itoa\(1\)\n\tr := Replaced\(next\(\), name\)`
+	_ = r
+
+	ReplaceMe(strconv.Itoa(2), next()) // want `ReplaceMe\(strconv.Itoa\(2\), next\(\)\) => name2 := strconv.Itoa\(2\)\n\tReplaced\(next\(\), name2\)`
+}
+
+func clashingNames(name string) {
+	r := ReplaceMe(strconv.Itoa(1), len(name)) // want `r := ReplaceMe\(strconv.Itoa\(1\), len\(name\)\) => name2 := strconv.Itoa\(1\)\n\tr := Replaced\(len\(name\), name2\)`
+	_ = r
+}
+
+func notAStatement() int {
+	return ReplaceMe(strconv.Itoa(1), next()) + 1 // want `cannot replace: the replacement evaluates \$arg1 \(next\(\)\) before \$arg0 \(strconv.Itoa\(1\)\); move the arguments into variables first`
+}
+
+func pureArgs(name string, n int) {
+	_ = ReplaceMe(name, n)            // want `ReplaceMe\(name, n\) => Replaced\(n, name\)`
+	_ = ReplaceMe(strconv.Itoa(n), 5) // want `ReplaceMe\(strconv.Itoa\(n\), 5\) => Replaced\(5, strconv.Itoa\(n\)\)`
+	_ = ReplaceMe("abc", len(name)+n) // want `ReplaceMe\("abc", len\(name\)\+n\) => Replaced\(len\(name\)\+n, "abc"\)`
+}
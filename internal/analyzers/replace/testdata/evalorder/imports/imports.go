@@ -0,0 +1,18 @@
+package imports
+
+import "strconv"
+
+func F(a, b int) int {
+	return b - a
+}
+
+func next() int {
+	return 0
+}
+
+func check() bool {
+	if F(next(), next()) > 0 { // want `cannot replace: the replacement evaluates \$arg1 \(next\(\)\) before \$arg0 \(next\(\)\); move the arguments into variables first`
+		return true
+	}
+	return strconv.IntSize == 64
+}
@@ -0,0 +1,5 @@
+package newer
+
+func G(a, b int) int {
+	return a - b
+}
@@ -0,0 +1,21 @@
+package stmt
+
+import "strconv"
+
+func MustOpen(name string, flags int) int {
+	return 0
+}
+
+func Open(flags int, name string) (int, error) {
+	return 0, nil
+}
+
+func next() int {
+	return 0
+}
+
+func open() error {
+	f := MustOpen(strconv.Itoa(1), next()) // want `f := MustOpen\(strconv.Itoa\(1\), next\(\)\) => name := strconv.Itoa\(1\)\n\tf, err := Open\(next\(\), name\)\n\tif err != nil {\n\t\treturn err\n\t}`
+	_ = f
+	return nil
+}
@@ -0,0 +1,18 @@
+package twice
+
+func Double(n int) int {
+	return n * 2
+}
+
+func next() int {
+	return 0
+}
+
+func double(n int) {
+	_ = Double(n) // want `Double\(n\) => n \+ n`
+
+	x := Double(next()) // want `x := Double\(next\(\)\) => n2 := next\(\)\n\tx := n2 \+ n2`
+	_ = x
+
+	_ = []int{Double(next())} // want `cannot replace: the replacement evaluates \$arg0 \(next\(\)\) more than once; move the arguments into variables first`
+}
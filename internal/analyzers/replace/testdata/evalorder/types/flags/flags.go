@@ -0,0 +1,3 @@
+package flags
+
+type Flag bool
@@ -0,0 +1,18 @@
+package novalue
+
+func Pair(a, b int) int {
+	return 0
+}
+
+func Zero() int {
+	return 0
+}
+
+func two() (int, int) {
+	return 0, 0
+}
+
+func pair() {
+	x := Pair(two()) // want `x := Pair\(two\(\)\) => two\(\)\n\tx := Zero\(\)`
+	_ = x
+}
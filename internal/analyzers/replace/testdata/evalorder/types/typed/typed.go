@@ -0,0 +1,11 @@
+package typed
+
+import "test.com/module/evalorder/types/flags"
+
+func Set(on flags.Flag, name string) int {
+	return 0
+}
+
+func Replaced(name string, on flags.Flag) int {
+	return 0
+}
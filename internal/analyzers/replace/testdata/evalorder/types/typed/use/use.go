@@ -0,0 +1,16 @@
+package use
+
+import "test.com/module/evalorder/types/typed" // want "modifying imports"
+
+func next() int {
+	return 0
+}
+
+func name() string {
+	return ""
+}
+
+func set() {
+	x := typed.Set(next() == 1, name()) // want `x := typed.Set\(next\(\) == 1, name\(\)\) => var on flags.Flag = next\(\) == 1\n\tx := typed.Replaced\(name\(\), on\)`
+	_ = x
+}
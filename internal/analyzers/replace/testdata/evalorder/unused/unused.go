@@ -0,0 +1,34 @@
+package unused
+
+func Get(key string, fallback int) string {
+	return key
+}
+
+func Lookup(key string) string {
+	return key
+}
+
+func next() int {
+	return 0
+}
+
+func name() string {
+	return ""
+}
+
+func unused() {
+	_ = Get("a", 1) // want `Get\("a", 1\) => Lookup\("a"\)`
+
+	x := Get("b", next()) // want `x := Get\("b", next\(\)\) => _ = next\(\)\n\tx := Lookup\("b"\)`
+	_ = x
+
+	y := Get(name(), next()) // want `y := Get\(name\(\), next\(\)\) => key := name\(\)\n\t_ = next\(\)\n\ty := Lookup\(key\)`
+	_ = y
+
+	_ = []string{Get("c", next())} // want `cannot replace: the replacement doesn't evaluate \$arg1 \(next\(\)\), which has side effects; move the arguments into variables first`
+}
+
+func nested() {
+	z := Get(Get("d", next()), next()) // want `z := Get\(Get\("d", next\(\)\), next\(\)\) => key := Get\("d", next\(\)\)\n\t_ = next\(\)\n\tz := Lookup\(key\)` "not replaced: this call was copied into the replacement of the call around it; run the replacement again to replace it"
+	_ = z
+}
@@ -0,0 +1,11 @@
+package bad
+
+import (
+	"test.com/module/generic/newer"
+	"test.com/module/generic/old"
+)
+
+var _ newer.Map[int, string]
+
+var a old.Map[string, []int] // want `cannot replace: \[\]int does not satisfy comparable`
+var b old.Map[string, int]   // want `old.Map\[string, int\] => newer.Map\[string, int\]`
@@ -0,0 +1,3 @@
+package newer
+
+type Map[V any, K comparable] struct{}
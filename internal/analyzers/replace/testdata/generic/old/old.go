@@ -0,0 +1,5 @@
+package old
+
+type Map[K comparable, V any] struct{}
+
+type List[T any] struct{}
@@ -0,0 +1,8 @@
+package generic
+
+import ( // want "modifying imports"
+	"test.com/module/generic/old"
+)
+
+var a old.Map[string, int]                          // want `old.Map\[string, int\] => newer.Map\[int, string\]`
+var b old.Map[string, old.Map[int, old.List[bool]]] // want `old.Map\[string, old.Map\[int, old.List\[bool\]\]\] => newer.Map\[newer.Map\[old.List\[bool\], int\], string\]`
@@ -0,0 +1,17 @@
+package asserts
+
+import (
+	"errors"
+
+	"test.com/module/glob/assert"
+)
+
+func check(t assert.TestingT) {
+	assert.Equalf(t, 1, 2, "want %d", 1)          // want `assert.Equalf\(t, 1, 2, "want %d", 1\) => assert.Equal\(t, 1, 2, "want %d", 1\)`
+	assert.NoErrorf(t, errors.New("x"), "failed") // want `assert.NoErrorf\(t, errors.New\("x"\), "failed"\) => assert.NoError\(t, errors.New\("x"\), "failed"\)`
+	assert.Equal(t, 1, 2)
+	assert.Fail(t, "failed")
+
+	fail := assert.Failf // want `assert.Failf => assert.Fail`
+	fail(t, "failed", "again")
+}
@@ -0,0 +1,10 @@
+package methods
+
+import "test.com/module/glob/old"
+
+func use(c old.Client, s *old.Server, parts []string) {
+	_ = c.Do("a", "b")    // want `c.Do\("a", "b"\) => old.DoClient\(c, "a", "b"\)`
+	_ = s.Do(parts...)    // want `s.Do\(parts...\) => old.DoServer\(s, parts...\)`
+	_ = old.Client{}.Do() // want `old.Client{}.Do\(\) => old.DoClient\(old.Client{}\)`
+	_ = c.Close()
+}
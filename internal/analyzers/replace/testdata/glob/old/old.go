@@ -0,0 +1,25 @@
+package old
+
+type Client struct{}
+
+func (Client) Do(parts ...string) error {
+	return nil
+}
+
+func (Client) Close() error {
+	return nil
+}
+
+type Server struct{}
+
+func (*Server) Do(parts ...string) error {
+	return nil
+}
+
+func DoClient(c Client, parts ...string) error {
+	return nil
+}
+
+func DoServer(s *Server, parts ...string) error {
+	return nil
+}
@@ -0,0 +1,8 @@
+package args
+
+import "test.com/module/metavars/old"
+
+func use(prefix string, parts []string) {
+	_ = old.Concat("a", "b")             // want `old.Concat\("a", "b"\) => old.Join\("b", "a"\)`
+	_ = old.Concat(prefix+"/", parts[0]) // want `old.Concat\(prefix\+"/", parts\[0\]\) => old.Join\(parts\[0\], prefix\+"/"\)`
+}
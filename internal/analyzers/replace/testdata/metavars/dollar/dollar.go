@@ -0,0 +1,8 @@
+package dollar
+
+import "test.com/module/metavars/old" // want "modifying imports"
+
+func use() {
+	_ = old.Price(100) // want `old.Price\(100\) => fmt.Sprintf\("\$%d", 100\)`
+	_ = old.Join("a", "b")
+}
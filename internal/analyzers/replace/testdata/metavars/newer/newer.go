@@ -0,0 +1,5 @@
+package newer
+
+func Join(a, b string) string {
+	return a + b
+}
@@ -0,0 +1,35 @@
+package old
+
+func Concat(a, b string) string {
+	return a + b
+}
+
+func Join(a, b string) string {
+	return a + b
+}
+
+func Price(cents int) string {
+	return ""
+}
+
+func MustAtoi(s string) int {
+	return 0
+}
+
+func Atoi(s string) (int, error) {
+	return 0, nil
+}
+
+type Client struct{}
+
+func (Client) Get(key string) string {
+	return key
+}
+
+func (Client) Fetch(key string) string {
+	return key
+}
+
+func NewClient() Client {
+	return Client{}
+}
@@ -0,0 +1,8 @@
+package pkg
+
+import "test.com/module/metavars/old" // want "modifying imports"
+
+func use() {
+	_ = old.Concat("a", "b") // want `old.Concat\("a", "b"\) => newer.Join\("a", "b"\)`
+	_ = old.Join("a", "b")
+}
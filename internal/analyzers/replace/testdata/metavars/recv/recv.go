@@ -0,0 +1,13 @@
+package recv
+
+import "test.com/module/metavars/old"
+
+type service struct {
+	clients []old.Client
+}
+
+func use(c old.Client, s *service) {
+	_ = c.Get("a")               // want `c.Get\("a"\) => c.Fetch\("a"\)`
+	_ = old.NewClient().Get("b") // want `old.NewClient\(\).Get\("b"\) => old.NewClient\(\).Fetch\("b"\)`
+	_ = s.clients[0].Get("c")    // want `s.clients\[0\].Get\("c"\) => s.clients\[0\].Fetch\("c"\)`
+}
@@ -0,0 +1,13 @@
+package recvdot
+
+func New() int {
+	return 0
+}
+
+func NewWithDefaults() int {
+	return 0
+}
+
+func use() {
+	_ = New() // want `New\(\) => NewWithDefaults\(\)`
+}
@@ -0,0 +1,7 @@
+package use
+
+import "test.com/module/metavars/recvdot"
+
+func use() {
+	_ = recvdot.New() // want `recvdot.New\(\) => recvdot.NewWithDefaults\(\)`
+}
@@ -0,0 +1,8 @@
+package stmt
+
+import "test.com/module/metavars/old" // want "modifying imports"
+
+func use() (string, int, error) {
+	n := old.MustAtoi("1") // want `n := old.MustAtoi\("1"\) => n, err := old.Atoi\("1"\)\n\tif err != nil {\n\t\treturn "", 0, fmt.Errorf\("parsing %q: %w", "1", err\)\n\t}`
+	return "", n, nil
+}
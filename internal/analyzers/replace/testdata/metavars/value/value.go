@@ -0,0 +1,11 @@
+package value
+
+import "test.com/module/metavars/old" // want "modifying imports"
+
+func use(f func(a, b string) string) {}
+
+func uses() {
+	use(old.Concat)          // want `old.Concat => newer.Join`
+	_ = old.Concat("a", "b") // want `old.Concat\("a", "b"\) => newer.Join\("a", "b"\)`
+	_ = old.Join("a", "b")
+}
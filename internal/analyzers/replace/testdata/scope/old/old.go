@@ -0,0 +1,3 @@
+package old
+
+type ID int
@@ -0,0 +1,12 @@
+package scope
+
+import "test.com/module/scope/old" // want "modifying imports"
+
+type User struct {
+	ID old.ID // want `old.ID => newer.ID`
+}
+
+func Lookup(id old.ID) old.ID {
+	var other old.ID = id
+	return old.ID(other) // want `old.ID => newer.ID`
+}
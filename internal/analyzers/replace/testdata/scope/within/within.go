@@ -0,0 +1,14 @@
+package within
+
+import "test.com/module/scope/old" // want "modifying imports"
+
+type User struct {
+	ID old.ID
+}
+
+func Lookup(id old.ID) old.ID { // want `old.ID => newer.ID` `old.ID => newer.ID`
+	var other old.ID = id // want `old.ID => newer.ID`
+	return other
+}
+
+func Other(id old.ID) {}
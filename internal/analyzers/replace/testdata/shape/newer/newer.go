@@ -0,0 +1,5 @@
+package newer
+
+type Client struct {
+	Addr string
+}
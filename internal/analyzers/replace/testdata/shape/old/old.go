@@ -0,0 +1,5 @@
+package old
+
+type Client struct {
+	Addr string
+}
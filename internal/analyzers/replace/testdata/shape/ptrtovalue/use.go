@@ -0,0 +1,32 @@
+package ptrtovalue
+
+import "test.com/module/shape/old" // want "modifying imports"
+
+type Server struct {
+	client *old.Client // want `\*old.Client => newer.Client`
+}
+
+func NewClient(addr string) *old.Client { // want `\*old.Client => newer.Client`
+	return &old.Client{Addr: addr} // want `&old.Client => newer.Client`
+}
+
+func Empty() *old.Client { // want `\*old.Client => newer.Client`
+	return new(old.Client) // want `new\(old.Client\) => \*new\(newer.Client\)`
+}
+
+func Addr(c *old.Client) string { // want `\*old.Client => newer.Client`
+	return (*c).Addr // want `\(\*c\) => c`
+}
+
+func Reset(c *old.Client) { // want `\*old.Client => newer.Client`
+	*c = old.Client{} // want "assignment through a pointer to Client now assigns to a copy" `\* => ` `old.Client => newer.Client`
+}
+
+func Share(c *old.Client) *old.Client { // want `\*old.Client => newer.Client` `\*old.Client => newer.Client`
+	d := c   // want "this was a shared pointer to Client but is now a copied value"
+	return d // want "this was a shared pointer to Client but is now a copied value"
+}
+
+func Connected(s Server) bool {
+	return s.client != nil // want "newer.Client values can't be compared with nil"
+}
@@ -0,0 +1,24 @@
+package valuetoptr
+
+import "test.com/module/shape/old" // want "modifying imports"
+
+type Server struct {
+	client old.Client // want `old.Client => \*newer.Client`
+}
+
+func NewClient(addr string) old.Client { // want `old.Client => \*newer.Client`
+	return old.Client{Addr: addr} // want `old.Client => &newer.Client`
+}
+
+func NewPtr(addr string) *old.Client { // want `\*old.Client => \*newer.Client`
+	return &old.Client{Addr: addr} // want `old.Client => newer.Client`
+}
+
+func Empty() *old.Client { // want `\*old.Client => \*newer.Client`
+	return new(old.Client) // want `old.Client => newer.Client`
+}
+
+func Copy(c old.Client) old.Client { // want `old.Client => \*newer.Client` `old.Client => \*newer.Client`
+	other := c   // want "this was a copied Client value but is now a shared pointer"
+	return other // want "this was a copied Client value but is now a shared pointer"
+}
@@ -0,0 +1,5 @@
+package newer
+
+func Open(name string) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,17 @@
+package rejected
+
+import "strconv"
+
+func MustOpen(name string) int {
+	return 0
+}
+
+func loop() error {
+	for i := MustOpen("x"); i < 3; i++ { // want "cannot replace the statement enclosing this call"
+		_ = strconv.Itoa(i)
+	}
+
+	x, y := MustOpen("abc"), 1 // want "cannot replace the statement enclosing this call"
+	_, _ = x, y
+	return nil
+}
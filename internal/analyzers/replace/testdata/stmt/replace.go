@@ -0,0 +1,47 @@
+package stmt
+
+import (
+	"errors"
+	"strings"
+)
+
+func MustOpen(p string) int {
+	return 0
+}
+
+func Open(p string) (int, error) {
+	return 0, errors.New("oops")
+}
+
+type result struct {
+	n int
+}
+
+func onlyErr() error {
+	x := MustOpen("abc") // want `x := MustOpen\("abc"\) => x, err := Open\("abc"\)\n\tif err != nil {\n\t\treturn err\n\t}`
+	_ = x
+	return nil
+}
+
+func manyResults() (result, *strings.Builder, string, error) {
+	x, y := MustOpen("abc"), 1 // want "cannot replace the statement enclosing this call"
+	_, _ = x, y
+
+	for range 3 {
+		x = MustOpen("def") // want `x = MustOpen\("def"\) => var err error\n\t\tx, err = Open\("def"\)\n\t\tif err != nil {\n\t\t\treturn result{}, nil, "", err\n\t\t}`
+	}
+	return result{}, nil, "", nil
+}
+
+func commented() error {
+	x := MustOpen( /* the config */ "abc") // want `x := MustOpen\("abc"\) => x, err := Open\( /\* the config \*/ "abc"\)\n\tif err != nil {\n\t\treturn err\n\t}`
+	_ = x
+	return nil
+}
+
+func errInScope() (err error) {
+	var x int
+	x = MustOpen("ghi") // want `x = MustOpen\("ghi"\) => x, err = Open\("ghi"\)\n\tif err != nil {\n\t\treturn err\n\t}`
+	_ = x
+	return nil
+}
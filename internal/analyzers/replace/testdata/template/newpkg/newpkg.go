@@ -0,0 +1,5 @@
+package newpkg
+
+func G(b int, a string) string {
+	return a
+}
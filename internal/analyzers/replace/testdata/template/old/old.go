@@ -0,0 +1,5 @@
+package old
+
+func F(a string, b int) string {
+	return a
+}
@@ -0,0 +1,12 @@
+package template
+
+import ( // want "modifying imports"
+	"strconv"
+
+	"test.com/module/template/old"
+)
+
+func foobar() {
+	r := old.F("abc", len(strconv.Itoa(1))) // want `old.F\("abc", len\(strconv.Itoa\(1\)\)\) => newpkg.G\(len\(strconv.Itoa\(1\)\), "abc"\+"\$"\)`
+	_ = r
+}
@@ -0,0 +1,12 @@
+//go:build ignore
+
+package rule
+
+import (
+	"test.com/module/template/newpkg"
+	"test.com/module/template/old"
+)
+
+func before(a string, b int) string { return old.F(a, b) }
+
+func after(a string, b int) string { return newpkg.G(b, a+"$") }
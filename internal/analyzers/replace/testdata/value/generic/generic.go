@@ -0,0 +1,17 @@
+package generic
+
+func OldMap[T any](x T) T { return x }
+
+func NewMap[T any](x T) T { return x }
+
+func OldPair[K comparable, V any](k K, v V) map[K]V { return map[K]V{k: v} }
+
+func NewPair[K comparable, V any](k K, v V) map[K]V { return map[K]V{k: v} }
+
+func use() {
+	_ = OldMap[int](1)               // want `OldMap\[int\]\(1\) => NewMap\(1\)`
+	_ = (OldMap[int])(2)             // want `\(OldMap\[int\]\)\(2\) => NewMap\(2\)`
+	_ = OldPair[int, string](1, "a") // want `OldPair\[int, string\]\(1, "a"\) => NewPair\(1, "a"\)`
+	f := OldMap[string]              // want `OldMap => NewMap`
+	_ = f
+}
@@ -0,0 +1,12 @@
+package method
+
+type T struct{}
+
+func (T) M() {}
+
+func foobar(t T) {
+	t.M()    // want `t.M\(\) => t.M\(\)`
+	f := t.M // want "unhandled reference to M: method value"
+	g := T.M // want "unhandled reference to M: method expression"
+	_, _ = f, g
+}
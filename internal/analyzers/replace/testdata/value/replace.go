@@ -0,0 +1,18 @@
+package value
+
+func ReplaceMe(a int) int {
+	return a
+}
+
+func Replaced(a int) int {
+	return a
+}
+
+func use(f func(int) int) {}
+
+func foobar() {
+	use(ReplaceMe)   // want `ReplaceMe => Replaced`
+	f := (ReplaceMe) // want `ReplaceMe => Replaced`
+	_ = f
+	_ = (ReplaceMe)(1) // want `\(ReplaceMe\)\(1\) => Replaced\(1\)`
+}
@@ -1,8 +1,11 @@
 package analyzeutil
 
 import (
+	"bytes"
+	"errors"
 	"go/ast"
 	"go/format"
+	"go/parser"
 	"go/token"
 	"strings"
 
@@ -19,6 +22,194 @@ func FormatNode(fset *token.FileSet, n ast.Node) (string, error) {
 	return dst.String(), nil
 }
 
+// ReplaceRange is like ReplaceNode, but replaces the source between pos and end, which don't need to
+// correspond to a single node.
+func ReplaceRange(pass *analysis.Pass, pos, end token.Pos, replaceWith string) error {
+	tf := pass.Fset.File(pos)
+	src, err := pass.ReadFile(tf.Name())
+	if err != nil {
+		return err
+	}
+
+	msg := string(src[tf.Offset(pos):tf.Offset(end)]) + " => " + replaceWith
+
+	pass.Report(
+		analysis.Diagnostic{
+			Pos:     pos,
+			End:     end,
+			Message: msg,
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message: msg,
+				TextEdits: []analysis.TextEdit{{
+					Pos:     pos,
+					End:     end,
+					NewText: []byte(replaceWith),
+				}},
+			}},
+		},
+	)
+
+	return nil
+}
+
+// DeleteStmt reports a diagnostic with a fix that deletes stmt, along with the comments that trail it on
+// its line. If stmt is alone on its line, the whole line is deleted.
+func DeleteStmt(pass *analysis.Pass, stmt ast.Stmt) error {
+	curr, err := FormatNode(pass.Fset, stmt)
+	if err != nil {
+		return err
+	}
+
+	tf := pass.Fset.File(stmt.Pos())
+	src, err := pass.ReadFile(tf.Name())
+	if err != nil {
+		return err
+	}
+
+	start, end := tf.Offset(stmt.Pos()), tf.Offset(stmt.End())
+
+	lineStart := start
+	for lineStart > 0 && (src[lineStart-1] == ' ' || src[lineStart-1] == '\t') {
+		lineStart--
+	}
+	lineEnd := skipTrailingComments(src, end)
+	if lineEnd > end && lineEnd < len(src) && src[lineEnd] == '\n' {
+		// The comments go with the statement.
+		end = lineEnd
+	}
+	if (lineStart == 0 || src[lineStart-1] == '\n') && lineEnd < len(src) && src[lineEnd] == '\n' {
+		start, end = lineStart, lineEnd+1
+	}
+
+	msg := "delete " + curr
+
+	pass.Report(
+		analysis.Diagnostic{
+			Pos:     stmt.Pos(),
+			End:     stmt.End(),
+			Message: msg,
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message: msg,
+				TextEdits: []analysis.TextEdit{{
+					Pos: tf.Pos(start),
+					End: tf.Pos(end),
+				}},
+			}},
+		},
+	)
+
+	return nil
+}
+
+// skipTrailingComments returns the offset in src after the spaces and comments that follow offset on
+// the same line.
+func skipTrailingComments(src []byte, offset int) int {
+	for {
+		for offset < len(src) && (src[offset] == ' ' || src[offset] == '\t') {
+			offset++
+		}
+
+		rest := src[offset:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("//")):
+			if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+				return offset + i
+			}
+			return len(src)
+		case bytes.HasPrefix(rest, []byte("/*")):
+			i := bytes.Index(rest, []byte("*/"))
+			if i < 0 || bytes.IndexByte(rest[:i], '\n') >= 0 {
+				// The comment carries on to another line, so it doesn't only trail this one.
+				return offset
+			}
+			offset += i + len("*/")
+		default:
+			return offset
+		}
+	}
+}
+
+// FormatStmts formats src, a list of statements, so that it can be inserted at a position indented by
+// indent. Comments in src are kept.
+func FormatStmts(src, indent string) (string, error) {
+	const header = "package p\n\nfunc _() {\n"
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", header+src+"\n}\n", parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	if len(f.Decls[0].(*ast.FuncDecl).Body.List) == 0 {
+		return "", errors.New("expected at least one statement")
+	}
+
+	formatted, err := FormatNode(fset, f)
+	if err != nil {
+		return "", err
+	}
+
+	// Cut the statements out of the function they were formatted in, which indented them by a tab.
+	body := strings.TrimPrefix(formatted, header)
+	body = body[:strings.LastIndex(body, "}")]
+
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimPrefix(line, "\t")
+		if i > 0 && line != "" {
+			line = indent + line
+		}
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Indentation returns the whitespace that the line containing pos starts with.
+func Indentation(pass *analysis.Pass, pos token.Pos) (string, error) {
+	tf := pass.Fset.File(pos)
+	src, err := pass.ReadFile(tf.Name())
+	if err != nil {
+		return "", err
+	}
+
+	start := tf.Offset(tf.LineStart(tf.Line(pos)))
+	line := string(src[start:tf.Offset(pos)])
+
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))], nil
+}
+
+// FormatExpr formats expr like gofmt would when it's written at pos, so that comments and line breaks
+// copied into it from elsewhere end up where gofmt keeps them. If expr doesn't parse, it's returned as
+// it is. So is an expr with a raw string in it, whose lines can't be reindented.
+func FormatExpr(pass *analysis.Pass, pos token.Pos, expr string) (string, error) {
+	if strings.Contains(expr, "`") {
+		return expr, nil
+	}
+
+	const header = "package p\n\nfunc _() {\n\t_ = "
+	formatted, err := format.Source([]byte(header + expr + "\n}\n"))
+	if err != nil || !bytes.HasPrefix(formatted, []byte(header)) {
+		return expr, nil
+	}
+
+	indent, err := Indentation(pass, pos)
+	if err != nil {
+		return "", err
+	}
+
+	// The expression is formatted one level into a function, so its continuation lines are indented
+	// one level too many.
+	s := strings.TrimSuffix(strings.TrimPrefix(string(formatted), header), "\n}\n")
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + strings.TrimPrefix(lines[i], "\t")
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
 func PrintReplacement(fset *token.FileSet, n ast.Node, replaceWith string) (string, error) {
 	curr, err := FormatNode(fset, n)
 	if err != nil {
@@ -1,15 +1,21 @@
 package analyzeutil
 
 import (
+	"cmp"
 	"errors"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"slices"
+	"strconv"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
+// ImportsMessage is the message of the diagnostics Rewrite reports for the changes to a file's imports.
+const ImportsMessage = "modifying imports"
+
 type importModification struct {
 	original *ast.File
 	mutated  *ast.File
@@ -20,6 +26,69 @@ type Importer struct {
 }
 
 func (imp *Importer) Add(fset *token.FileSet, f *ast.File, name, path string) string {
+	if existing, ok := imp.Imported(fset, f, path); ok {
+		// We already have this import (maybe from a previous call to Add) and we don't need to add it.
+		// Return the name (if any) that it's already imported as.
+		return existing
+	}
+
+	fileName, mod := imp.modification(fset, f)
+	astutil.AddNamedImport(fset, mod.mutated, name, path)
+	imp.filesByName[fileName] = mod
+	return name
+}
+
+// Name returns the name Add would return for path, without adding the import. Use it to write code
+// that may not be kept, and call Add once it is.
+func (imp *Importer) Name(fset *token.FileSet, f *ast.File, name, path string) string {
+	if existing, ok := imp.Imported(fset, f, path); ok {
+		return existing
+	}
+	return name
+}
+
+// Imported reports whether f imports path, including imports added with Add, and returns the name
+// it's explicitly imported under, if any.
+func (imp *Importer) Imported(fset *token.FileSet, f *ast.File, path string) (string, bool) {
+	_, mod := imp.modification(fset, f)
+
+	for _, spec := range mod.mutated.Imports {
+		if importPath(spec) != path {
+			continue
+		}
+
+		if spec.Name == nil {
+			return "", true
+		}
+		return spec.Name.Name, true
+	}
+
+	return "", false
+}
+
+// Remove removes the import of path from f.
+func (imp *Importer) Remove(fset *token.FileSet, f *ast.File, path string) {
+	fileName, mod := imp.modification(fset, f)
+
+	for _, d := range mod.mutated.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			break
+		}
+
+		gd.Specs = slices.DeleteFunc(gd.Specs, func(s ast.Spec) bool {
+			return importPath(s.(*ast.ImportSpec)) == path
+		})
+	}
+
+	mod.mutated.Imports = slices.DeleteFunc(mod.mutated.Imports, func(s *ast.ImportSpec) bool {
+		return importPath(s) == path
+	})
+
+	imp.filesByName[fileName] = mod
+}
+
+func (imp *Importer) modification(fset *token.FileSet, f *ast.File) (string, importModification) {
 	if imp.filesByName == nil {
 		imp.filesByName = make(map[string]importModification)
 	}
@@ -36,29 +105,82 @@ func (imp *Importer) Add(fset *token.FileSet, f *ast.File, name, path string) st
 		clone.Decls = slices.Clone(f.Decls)
 		clone.Imports = slices.Clone(f.Imports)
 
+		for i, d := range clone.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.IMPORT {
+				break
+			}
+
+			gdClone := *gd
+			gdClone.Specs = slices.Clone(gd.Specs)
+			clone.Decls[i] = &gdClone
+		}
+
 		mod = importModification{
 			original: f,
 			mutated:  &clone,
 		}
 	}
 
-	// Let's first check to see if we already had an import for this path. Note that we'll check the
-	// modified file because we may have added this import in a previous call to Add.
-	for _, imp := range mod.mutated.Imports {
-		unquoted := imp.Path.Value[1 : len(imp.Path.Value)-1]
-		if unquoted == path {
-			// We already have this import and we don't need to add it. Return the name (if any)
-			// that it's already imported as.
-			if imp.Name == nil {
+	return fileName, mod
+}
+
+func importPath(spec *ast.ImportSpec) string {
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// Qualifier returns a types.Qualifier that qualifies names from other packages by the name f imports
+// them under. Imports are added to f for packages it doesn't import yet.
+func (imp *Importer) Qualifier(fset *token.FileSet, f *ast.File, pkg *types.Package) types.Qualifier {
+	return imp.qualifier(fset, f, pkg, func(path string) string {
+		return imp.Add(fset, f, "", path)
+	})
+}
+
+// DeferredQualifier is like Qualifier, but the imports of the packages it qualified names from are
+// only added to f when add is called, so that nothing is added for code that isn't kept.
+func (imp *Importer) DeferredQualifier(fset *token.FileSet, f *ast.File, pkg *types.Package) (q types.Qualifier, add func()) {
+	var paths []string
+	q = imp.qualifier(fset, f, pkg, func(path string) string {
+		paths = append(paths, path)
+		return imp.Name(fset, f, "", path)
+	})
+	add = func() {
+		for _, path := range paths {
+			imp.Add(fset, f, "", path)
+		}
+	}
+	return q, add
+}
+
+// qualifier returns a types.Qualifier for f, which calls add for packages f doesn't import yet.
+func (imp *Importer) qualifier(fset *token.FileSet, f *ast.File, pkg *types.Package, add func(path string) string) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+
+		for _, spec := range f.Imports {
+			if importPath(spec) != p.Path() {
+				continue
+			}
+
+			switch {
+			case spec.Name == nil:
+				return p.Name()
+			case spec.Name.Name == ".":
 				return ""
+			case spec.Name.Name != "_":
+				return spec.Name.Name
 			}
-			return imp.Name.Name
 		}
-	}
 
-	astutil.AddNamedImport(fset, mod.mutated, name, path)
-	imp.filesByName[fileName] = mod
-	return name
+		return cmp.Or(add(p.Path()), p.Name())
+	}
 }
 
 func (imp *Importer) Rewrite(pass *analysis.Pass) error {
@@ -70,40 +192,52 @@ func (imp *Importer) Rewrite(pass *analysis.Pass) error {
 			return errors.New("attempted to add import to file with no declarations")
 		}
 
-		originalDecl, ok := mod.original.Decls[0].(*ast.GenDecl)
-		if !ok {
+		firstDecl, ok := mod.original.Decls[0].(*ast.GenDecl)
+		if !ok || firstDecl.Tok != token.IMPORT {
 			return errors.New("unimplemented (TODO): we don't yet support there not being an import block")
 		}
 
-		if originalDecl.Tok != token.IMPORT {
-			return errors.New("unimplemented (TODO): we don't yet support there not being an import block")
-		}
+		for i, d := range mod.original.Decls {
+			originalDecl, ok := d.(*ast.GenDecl)
+			if !ok || originalDecl.Tok != token.IMPORT {
+				break
+			}
 
-		newDecl, ok := mod.mutated.Decls[0].(*ast.GenDecl)
-		if !ok || newDecl.Tok != token.IMPORT {
-			return errors.New("unexpected error: mutated imports was malformed")
-		}
+			newDecl, ok := mod.mutated.Decls[i].(*ast.GenDecl)
+			if !ok || newDecl.Tok != token.IMPORT {
+				return errors.New("unexpected error: mutated imports was malformed")
+			}
 
-		newDeclStr, err := FormatNode(pass.Fset, newDecl)
-		if err != nil {
-			return err
-		}
+			if slices.Equal(originalDecl.Specs, newDecl.Specs) {
+				continue
+			}
 
-		pass.Report(
-			analysis.Diagnostic{
-				Pos:     originalDecl.Pos(),
-				End:     originalDecl.End(),
-				Message: "modifying imports",
-				SuggestedFixes: []analysis.SuggestedFix{{
-					Message: "modifying imports",
-					TextEdits: []analysis.TextEdit{{
-						Pos:     originalDecl.Pos(),
-						End:     originalDecl.End(),
-						NewText: []byte(newDeclStr),
+			// If we removed every import in the declaration, remove the declaration too.
+			var newDeclStr string
+			if len(newDecl.Specs) > 0 {
+				var err error
+				newDeclStr, err = FormatNode(pass.Fset, newDecl)
+				if err != nil {
+					return err
+				}
+			}
+
+			pass.Report(
+				analysis.Diagnostic{
+					Pos:     originalDecl.Pos(),
+					End:     originalDecl.End(),
+					Message: ImportsMessage,
+					SuggestedFixes: []analysis.SuggestedFix{{
+						Message: ImportsMessage,
+						TextEdits: []analysis.TextEdit{{
+							Pos:     originalDecl.Pos(),
+							End:     originalDecl.End(),
+							NewText: []byte(newDeclStr),
+						}},
 					}},
-				}},
-			},
-		)
+				},
+			)
+		}
 	}
 
 	return nil
@@ -45,6 +45,16 @@ import (
 
 	assert.Len(t, imp.filesByName[`foo.go`].mutated.Imports, 3)
 
+	// Imports that were there already and imports that were added are both reported
+	name, ok := imp.Imported(fset, f, "github.com/w/x/y")
+	assert.True(t, ok)
+	assert.Empty(t, name)
+	name, ok = imp.Imported(fset, f, "github.com/new/imp")
+	assert.True(t, ok)
+	assert.Equal(t, "hmm", name)
+	_, ok = imp.Imported(fset, f, "github.com/not/imported")
+	assert.False(t, ok)
+
 	assertHasImport := func(path, name string) {
 		t.Helper()
 
@@ -77,3 +87,31 @@ import (
 	assertHasImport("github.com/w/x/y/z", "")
 	assertHasImport("github.com/new/imp", "hmm")
 }
+
+func TestImporter_Remove(t *testing.T) {
+	src := `package foo
+
+import (
+	"github.com/w/x/y"
+	"github.com/w/x/y/z"
+)`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(
+		fset,
+		`foo.go`,
+		[]byte(src),
+		parser.AllErrors,
+	)
+	require.NoError(t, err)
+
+	imp := &Importer{}
+	imp.Remove(fset, f, "github.com/w/x/y")
+
+	mod := imp.filesByName[`foo.go`]
+	require.Len(t, mod.mutated.Imports, 1)
+	assert.Equal(t, `"github.com/w/x/y/z"`, mod.mutated.Imports[0].Path.Value)
+
+	// The original file should be left alone.
+	assert.Len(t, f.Imports, 2)
+	assert.Len(t, f.Decls[0].(*ast.GenDecl).Specs, 2)
+}
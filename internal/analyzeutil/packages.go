@@ -0,0 +1,38 @@
+package analyzeutil
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadMode loads packages with their syntax and full type information, which is what's needed to find
+// references to a symbol in them.
+const LoadMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedSyntax |
+	packages.NeedTypes |
+	packages.NeedTypesInfo
+
+// Seen records what's been found while walking a list of packages. A file can belong to more than one
+// package, like a package and its test variant, so the same thing can be found more than once.
+type Seen[K comparable] map[K]bool
+
+// First reports whether k is found for the first time, and records it.
+func (s Seen[K]) First(k K) bool {
+	if s[k] {
+		return false
+	}
+	s[k] = true
+	return true
+}
+
+// Deref returns the type t points to, or t itself if it isn't a pointer.
+func Deref(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
@@ -3,12 +3,19 @@ package driver
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
 )
 
 //go:embed bin/driver
@@ -51,6 +58,24 @@ var (
 type Result struct {
 	output *strings.Builder
 	Count  int
+
+	// Diagnostics are the diagnostics reported by the analyzer, along with their fixes.
+	Diagnostics []Diagnostic
+}
+
+// Diagnostic is a diagnostic reported by an analyzer, and the edits of its suggested fix.
+type Diagnostic struct {
+	Posn    string
+	Message string
+	Edits   []Edit
+}
+
+// Edit replaces the bytes between Start and End of a file with New.
+type Edit struct {
+	Filename string `json:"filename"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	New      string `json:"new"`
 }
 
 func (r *Result) Output() string {
@@ -60,6 +85,31 @@ func (r *Result) Output() string {
 	return r.output.String()
 }
 
+// Changes returns the number of diagnostics that come with a fix, as opposed to the ones that only
+// report something that couldn't be changed. The imports the fixes need are part of them rather than
+// changes of their own.
+func (r *Result) Changes() int {
+	n := 0
+	for _, d := range r.Diagnostics {
+		if len(d.Edits) > 0 && d.Message != analyzeutil.ImportsMessage {
+			n++
+		}
+	}
+	return n
+}
+
+// Issues returns the number of diagnostics the analyzer reported, leaving out the edits to imports
+// that go with its fixes.
+func (r *Result) Issues() int {
+	n := 0
+	for _, d := range r.Diagnostics {
+		if d.Message != analyzeutil.ImportsMessage {
+			n++
+		}
+	}
+	return n
+}
+
 func (r *Result) Write(b []byte) (int, error) {
 	if r.output == nil {
 		r.output = &strings.Builder{}
@@ -68,55 +118,119 @@ func (r *Result) Write(b []byte) (int, error) {
 	return r.output.Write(b)
 }
 
-func (d Driver) Execute(subcmd string, flags map[string]string, args []string) (*Result, error) {
-	return d.execute(subcmd, flags, args, false)
+// Fixes runs the analyzer and returns what it reports along with the fixes it suggests, leaving it to
+// the caller to apply them, or only to print them for a dry run.
+func (d Driver) Fixes(subcmd string, flags map[string]string, args []string) (*Result, error) {
+	stdout, stderr := &bytes.Buffer{}, &strings.Builder{}
+	code, err := d.run(subcmd, flags, args, []string{"-json"}, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		// With -json, diagnostics don't affect the exit code, so this is a failure to load the packages.
+		return nil, exitcode.Wrap(exitcode.Load, fmt.Errorf("error running driver: %s", stderr))
+	}
 
+	// The output maps package IDs to analyzer names to either a list of diagnostics or an error.
+	var tree map[string]map[string]json.RawMessage
+	err = json.Unmarshal(stdout.Bytes(), &tree)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing driver output: %w\n%s%s", err, stdout, stderr)
+	}
+
+	type jsonDiagnostic struct {
+		Posn           string `json:"posn"`
+		Message        string `json:"message"`
+		SuggestedFixes []struct {
+			Edits []Edit `json:"edits"`
+		} `json:"suggested_fixes"`
+	}
+
+	res := &Result{}
+	seen := make(map[string]bool)
+	for _, pkgID := range slices.Sorted(maps.Keys(tree)) {
+		raw, ok := tree[pkgID][subcmd]
+		if !ok {
+			continue
+		}
+
+		var failed struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &failed) == nil && failed.Error != "" {
+			return nil, fmt.Errorf("error running driver: %s: %s", pkgID, failed.Error)
+		}
+
+		var diags []jsonDiagnostic
+		err := json.Unmarshal(raw, &diags)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing driver output: %w", err)
+		}
+
+		for _, diag := range diags {
+			// Files that belong to a package and its test variant are analyzed twice.
+			if seen[diag.Posn+diag.Message] {
+				continue
+			}
+			seen[diag.Posn+diag.Message] = true
+
+			d := Diagnostic{Posn: diag.Posn, Message: diag.Message}
+			for _, fix := range diag.SuggestedFixes {
+				d.Edits = append(d.Edits, fix.Edits...)
+			}
+			res.Diagnostics = append(res.Diagnostics, d)
+
+			fmt.Fprintf(res, "%s: %s\n", diag.Posn, diag.Message)
+		}
+	}
+
+	if len(res.Diagnostics) == 0 {
+		return nil, fmt.Errorf("execute: %w", ErrNoResults)
+	}
+	return res, nil
 }
 
-func (d Driver) Preview(subcmd string, flags map[string]string, args []string) (*Result, error) {
-	return d.execute(subcmd, flags, args, true)
+// VetTool runs the driver as the vet tool of go vet -vettool, with only the analyzer called subcmd
+// enabled, and returns its exit code. args are passed along as go vet gave them.
+func (d Driver) VetTool(subcmd string, args []string, stdout io.Writer) (int, error) {
+	return d.run(subcmd, nil, args, nil, stdout, os.Stderr)
 }
 
-func (d Driver) execute(subcmd string, flags map[string]string, args []string, dryrun bool) (*Result, error) {
+// run runs the driver with the analyzer called subcmd and returns its exit code.
+func (d Driver) run(
+	subcmd string,
+	flags map[string]string,
+	args []string,
+	extraArgs []string,
+	stdout, stderr io.Writer,
+) (int, error) {
 	if len(args) == 0 {
-		return nil, errors.New("must provide at least one argument specifying a package path to run the tool over")
+		return 0, errors.New("must provide at least one argument specifying a package path to run the tool over")
 	}
 
-	preparedArgs := make([]string, 0, len(flags)+2+len(args))
+	preparedArgs := make([]string, 0, len(flags)+len(extraArgs)+1+len(args))
 	preparedArgs = append(preparedArgs, "-"+subcmd)
-	if !dryrun {
-		preparedArgs = append(preparedArgs, "-fix")
-	}
+	preparedArgs = append(preparedArgs, extraArgs...)
 	for k, v := range flags {
 		preparedArgs = append(preparedArgs, "-"+subcmd+"."+k+"="+v)
 	}
 	preparedArgs = append(preparedArgs, args...)
 
-	output := &Result{}
 	cmd := exec.Command(d.exePath, preparedArgs...)
-	cmd.Stdout = output
-	cmd.Stderr = output
-
-	err := cmd.Start()
-	if err != nil {
-		return nil, err
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// Run waits for the output to be copied to stdout and stderr, which Process.Wait doesn't.
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
 	}
-
-	state, err := cmd.Process.Wait()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	switch state.ExitCode() {
-	case 0:
-		return nil, fmt.Errorf("execute: %w\n%s", ErrNoResults, output.Output())
-	case 3:
-		// Code 3 is returned when diagnostics were reported, this is our success case (when we had
-		// something to fix).
-		return output, nil
-	default:
-		return nil, fmt.Errorf("error running driver: %s", output.Output())
-	}
+	return 0, nil
 }
 
 func (d Driver) Cleanup() error {
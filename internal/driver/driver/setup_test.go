@@ -0,0 +1,37 @@
+package driver
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixes_Changes(t *testing.T) {
+	d, err := Setup()
+	require.NoError(t, err)
+	t.Cleanup(func() { d.Cleanup() })
+
+	// The packages are in the testdata's own module, which has to be where the driver runs.
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir("testdata"))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	res, err := d.Fixes("replacecall", map[string]string{
+		"func":        "driver.com/m/old.F",
+		"replacement": "$pkg(driver.com/m/newer,newer).G($arg0)",
+	}, []string{"./..."})
+	require.NoError(t, err)
+
+	messages := make([]string, 0, len(res.Diagnostics))
+	for _, d := range res.Diagnostics {
+		messages = append(messages, d.Message)
+	}
+	assert.ElementsMatch(t, []string{"old.F(1) => newer.G(1)", analyzeutil.ImportsMessage}, messages)
+
+	// The import goes with the replacement rather than being a change of its own.
+	assert.Equal(t, 1, res.Changes())
+}
@@ -0,0 +1,3 @@
+module driver.com/m
+
+go 1.23
@@ -0,0 +1,5 @@
+package newer
+
+func G(n int) int {
+	return n
+}
@@ -0,0 +1,5 @@
+package old
+
+func F(n int) int {
+	return n
+}
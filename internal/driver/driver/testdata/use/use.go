@@ -0,0 +1,7 @@
+package use
+
+import "driver.com/m/old"
+
+func use() int {
+	return old.F(1)
+}
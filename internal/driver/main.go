@@ -9,5 +9,8 @@ func main() {
 	multichecker.Main(
 		replace.NewFuncReplacer(),
 		replace.NewTypeReplacer(),
+		replace.NewCallDeleter(),
+		replace.NewConversionInserter(),
+		replace.NewChecker(),
 	)
 }
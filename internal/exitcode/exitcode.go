@@ -0,0 +1,136 @@
+// Package exitcode sorts the ways a run can fail into classes, each with its own exit code, so that
+// scripts and CI jobs can tell them apart. It also defines the modes that decide whether a run that
+// changed nothing, or changed something, counts as a failure.
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Class is the kind of failure a run ended with. Its value is the exit code.
+type Class int
+
+const (
+	// Failure is any failure that doesn't fit one of the classes below.
+	Failure Class = 1
+	// Usage is a mistake in how go-refactor was invoked: a bad flag, a malformed spec or replacement,
+	// or a symbol that doesn't exist.
+	Usage Class = 2
+	// Load is a failure to load the packages to refactor.
+	Load Class = 3
+	// TypeCheck is rewritten code that doesn't type-check, or that wouldn't according to a pre-flight
+	// check.
+	TypeCheck Class = 4
+	// Write is a failure to apply the edits to the files on disk.
+	Write Class = 5
+	// Outcome is a run that worked, but whose result was asked to be treated as a failure, like
+	// leftover references with --fail-on-leftovers or any change with --exit-code=check.
+	Outcome Class = 6
+)
+
+func (c Class) String() string {
+	switch c {
+	case Usage:
+		return "usage"
+	case Load:
+		return "load"
+	case TypeCheck:
+		return "type-check"
+	case Write:
+		return "write"
+	case Outcome:
+		return "outcome"
+	default:
+		return "failure"
+	}
+}
+
+// Error is an error of a known class.
+type Error struct {
+	Class Class
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns err as an error of the given class. Errors that are nil or already have a class are
+// returned as they are, so that the class is decided where the failure happened.
+func Wrap(class Class, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// Code returns the exit code for err: 0 for nil, the class of err if it has one and Failure otherwise.
+func Code(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return int(e.Class)
+	}
+	return int(Failure)
+}
+
+// Mode decides whether the number of changes a run made is a failure.
+type Mode int
+
+const (
+	// ZeroOK succeeds whether or not anything changed, so that a migration can be run again once it's
+	// done.
+	ZeroOK Mode = iota
+	// RequireChanges fails when nothing changed, for migrations that are expected to find something.
+	RequireChanges
+	// Check writes nothing, and fails when anything would change, for checking in CI that a migration
+	// is complete.
+	Check
+)
+
+func (m Mode) String() string {
+	switch m {
+	case RequireChanges:
+		return "require-changes"
+	case Check:
+		return "check"
+	default:
+		return "zero-ok"
+	}
+}
+
+// ParseMode parses the name of a mode.
+func ParseMode(s string) (Mode, error) {
+	for _, m := range []Mode{ZeroOK, RequireChanges, Check} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+	return 0, Wrap(Usage, fmt.Errorf("unknown mode %q; expected one of zero-ok, require-changes or check", s))
+}
+
+// Result returns the error for a run that made (or, in check mode, would have made) n changes, or nil
+// if that's a success in mode m.
+func (m Mode) Result(n int) error {
+	switch {
+	case m == RequireChanges && n == 0:
+		return Wrap(Outcome, errors.New("nothing was changed"))
+	case m == Check && n > 0:
+		return Wrap(Outcome, fmt.Errorf("%d issues need fixing", n))
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCode(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name string
+		err  error
+		exp  int
+	}{{
+		name: "nil",
+		err:  nil,
+		exp:  0,
+	}, {
+		name: "unclassified",
+		err:  base,
+		exp:  1,
+	}, {
+		name: "classified",
+		err:  Wrap(Load, base),
+		exp:  3,
+	}, {
+		name: "wrapped again",
+		err:  fmt.Errorf("running: %w", Wrap(Write, base)),
+		exp:  5,
+	}, {
+		name: "first class wins",
+		err:  Wrap(Usage, Wrap(TypeCheck, base)),
+		exp:  4,
+	}, {
+		name: "joined",
+		err:  errors.Join(Wrap(Load, base), errors.New("cleanup failed")),
+		exp:  3,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.exp, Code(tc.err))
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	base := errors.New("boom")
+
+	assert.NoError(t, Wrap(Usage, nil))
+
+	err := Wrap(Usage, base)
+	assert.Equal(t, "boom", err.Error())
+	assert.ErrorIs(t, err, base)
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range []Mode{ZeroOK, RequireChanges, Check} {
+		parsed, err := ParseMode(m.String())
+		require.NoError(t, err)
+		assert.Equal(t, m, parsed)
+	}
+
+	_, err := ParseMode("strict")
+	require.Error(t, err)
+	assert.Equal(t, `unknown mode "strict"; expected one of zero-ok, require-changes or check`, err.Error())
+	assert.Equal(t, int(Usage), Code(err))
+}
+
+func TestMode_Result(t *testing.T) {
+	tests := []struct {
+		mode   Mode
+		n      int
+		expErr string
+	}{
+		{mode: ZeroOK, n: 0},
+		{mode: ZeroOK, n: 3},
+		{mode: RequireChanges, n: 0, expErr: "nothing was changed"},
+		{mode: RequireChanges, n: 3},
+		{mode: Check, n: 0},
+		{mode: Check, n: 3, expErr: "3 issues need fixing"},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s %d", tc.mode, tc.n), func(t *testing.T) {
+			err := tc.mode.Result(tc.n)
+			if tc.expErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.expErr, err.Error())
+			assert.Equal(t, int(Outcome), Code(err))
+		})
+	}
+}
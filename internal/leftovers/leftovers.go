@@ -0,0 +1,294 @@
+package leftovers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// Leftover is a reference to a symbol that's still present after a migration.
+type Leftover struct {
+	Pos    token.Position
+	Reason string
+}
+
+func (l Leftover) String() string {
+	return l.Pos.String() + ": " + l.Reason
+}
+
+// Find loads the packages matching patterns (relative to dir) and returns every remaining reference
+// to the symbol described by spec, along with the reason it's likely to have been left behind.
+func Find(dir string, patterns []string, spec replace.SymbolSpec) ([]Leftover, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  analyzeutil.LoadMode,
+		Dir:   dir,
+		Tests: true,
+	}, patterns...)
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.Load, err)
+	}
+
+	// Note that we don't bail out when packages have errors. After a migration, leftovers are a likely
+	// cause of type errors, and the type checker still records what it can.
+
+	seen := make(analyzeutil.Seen[token.Position])
+	var found []Leftover
+	add := func(l Leftover) {
+		if seen.First(l.Pos) {
+			found = append(found, l)
+		}
+	}
+
+	for _, pkg := range pkgs {
+		findInSyntax(pkg, spec, add)
+
+		err := findInIgnoredFiles(pkg, spec, add)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortFunc(found, func(a, b Leftover) int {
+		return cmpPosition(a.Pos, b.Pos)
+	})
+
+	return found, nil
+}
+
+func findInSyntax(pkg *packages.Package, spec replace.SymbolSpec, add func(Leftover)) {
+	inspector.New(pkg.Syntax).WithStack(
+		[]ast.Node{&ast.Ident{}, &ast.BasicLit{}},
+		func(n ast.Node, push bool, stack []ast.Node) bool {
+			if !push {
+				return false
+			}
+
+			switch n := n.(type) {
+			case *ast.Ident:
+				reason, ok := classifyUse(pkg.TypesInfo, spec, n, stack)
+				if ok {
+					add(Leftover{
+						Pos:    pkg.Fset.Position(n.Pos()),
+						Reason: reason,
+					})
+				}
+			case *ast.BasicLit:
+				if n.Kind != token.STRING || !importsReflect(stack[0].(*ast.File)) {
+					return false
+				}
+
+				s, err := strconv.Unquote(n.Value)
+				if err != nil {
+					return false
+				}
+
+				if spec.MatchesName(s[strings.LastIndex(s, ".")+1:]) {
+					add(Leftover{
+						Pos:    pkg.Fset.Position(n.Pos()),
+						Reason: "string that may refer to it via reflection",
+					})
+				}
+			}
+
+			return true
+		},
+	)
+}
+
+func classifyUse(info *types.Info, spec replace.SymbolSpec, id *ast.Ident, stack []ast.Node) (string, bool) {
+	obj := info.Uses[id]
+	if obj == nil {
+		return "", false
+	}
+
+	// Selecting an embedded field whose type is the symbol refers to it implicitly.
+	if v, ok := obj.(*types.Var); ok && v.Embedded() {
+		if named, ok := analyzeutil.Deref(v.Type()).(*types.Named); ok && spec.Matches(named.Obj()) {
+			return "use of a field embedding it", true
+		}
+		return "", false
+	}
+
+	if !spec.Matches(obj) {
+		return "", false
+	}
+
+	// Work out the expression the identifier is part of and what that expression is part of.
+	ref, i := ast.Expr(id), len(stack)-2
+	if sel, ok := stack[i].(*ast.SelectorExpr); ok && sel.Sel == id {
+		ref, i = sel, i-1
+	}
+	for ; i > 0; i-- {
+		paren, ok := stack[i].(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		ref = paren
+	}
+
+	switch obj.(type) {
+	case *types.Func:
+		if call, ok := stack[i].(*ast.CallExpr); ok && call.Fun == ref {
+			return "call that was not rewritten", true
+		}
+
+		if sel, ok := ref.(*ast.SelectorExpr); ok {
+			if selection, ok := info.Selections[sel]; ok && selection.Kind() == types.MethodExpr {
+				return "non-call reference (method expression)", true
+			} else if ok {
+				return "non-call reference (method value)", true
+			}
+		}
+		return "non-call reference (function value)", true
+	case *types.TypeName:
+		parent := stack[i]
+		if star, ok := parent.(*ast.StarExpr); ok && i > 0 {
+			parent = stack[i-1]
+			ref = star
+		}
+
+		if field, ok := parent.(*ast.Field); ok && len(field.Names) == 0 && field.Type == ref && i > 1 {
+			if _, ok := stack[i-2].(*ast.StructType); ok {
+				return "struct embedding", true
+			}
+		}
+		return "type reference that was not rewritten", true
+	default:
+		return "reference that was not rewritten", true
+	}
+}
+
+// findInIgnoredFiles looks for references to the symbol in the files of pkg that are excluded by build
+// constraints, which aren't type-checked. Going by the syntax alone, a reference is the name of the
+// symbol qualified by the name a file imports its package under, or unqualified in a file of the
+// package itself. For a method, it's any selector with the method's name in those files.
+func findInIgnoredFiles(pkg *packages.Package, spec replace.SymbolSpec, add func(Leftover)) error {
+	for _, path := range pkg.IgnoredFiles {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		fset := token.NewFileSet()
+		f, _ := parser.ParseFile(fset, path, src, parser.ImportsOnly)
+		if f == nil || f.Name == nil {
+			continue
+		}
+
+		qualifiers := make(map[string]bool)
+		unqualified := f.Name.Name == pkg.Name && spec.MatchesPkg(pkg.PkgPath)
+		for _, imp := range f.Imports {
+			impPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || !spec.MatchesPkg(impPath) {
+				continue
+			}
+
+			switch name := importName(imp, impPath); name {
+			case "_":
+			case ".":
+				unqualified = true
+			default:
+				qualifiers[name] = true
+			}
+		}
+		if len(qualifiers) == 0 && !unqualified {
+			continue
+		}
+
+		var sc scanner.Scanner
+		sc.Init(fset.AddFile(path, -1, len(src)), src, nil, 0)
+
+		// The two tokens before the current one, to tell pkg.Name from x.Name and Name.
+		var prev, prevPrev token.Token
+		var prevLit, prevPrevLit string
+		for {
+			pos, tok, lit := sc.Scan()
+			if tok == token.EOF {
+				break
+			}
+
+			if tok == token.IDENT && spec.MatchesName(lit) {
+				selected := prev == token.PERIOD
+				var ok bool
+				switch {
+				case spec.Recv() != "":
+					ok = selected
+				case selected:
+					ok = prevPrev == token.IDENT && qualifiers[prevPrevLit]
+				default:
+					ok = unqualified
+				}
+
+				if ok {
+					add(Leftover{
+						Pos:    fset.Position(pos),
+						Reason: "mention in a file excluded by build constraints",
+					})
+				}
+			}
+
+			prevPrev, prevPrevLit = prev, prevLit
+			prev, prevLit = tok, lit
+		}
+	}
+
+	return nil
+}
+
+// importName returns the name imp imports the package at path under. Without an explicit name, that's
+// guessed from the path, as the package's name isn't known without loading it.
+func importName(imp *ast.ImportSpec, path string) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && isMajorVersion(name) {
+		// example.com/pkg/v2 is usually package pkg.
+		name = elems[len(elems)-2]
+	}
+	// gopkg.in/yaml.v3 is package yaml.
+	name, _, _ = strings.Cut(name, ".")
+	return name
+}
+
+func isMajorVersion(elem string) bool {
+	n, ok := strings.CutPrefix(elem, "v")
+	if !ok || n == "" {
+		return false
+	}
+	_, err := strconv.Atoi(n)
+	return err == nil
+}
+
+func importsReflect(f *ast.File) bool {
+	for _, imp := range f.Imports {
+		if imp.Path.Value == `"reflect"` {
+			return true
+		}
+	}
+	return false
+}
+
+func cmpPosition(a, b token.Position) int {
+	if c := strings.Compare(a.Filename, b.Filename); c != 0 {
+		return c
+	}
+	if a.Line != b.Line {
+		return a.Line - b.Line
+	}
+	return a.Column - b.Column
+}
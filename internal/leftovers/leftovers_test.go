@@ -0,0 +1,61 @@
+package leftovers
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		spec string
+		exp  []string
+	}{{
+		spec: "test.com/leftovers/old.F",
+		exp: []string{
+			"old/ignored.go:6:2: mention in a file excluded by build constraints",
+			"use/ignored.go:12:6: mention in a file excluded by build constraints",
+			"use/use.go:14:6: call that was not rewritten",
+			"use/use.go:15:11: non-call reference (function value)",
+		},
+	}, {
+		spec: "test.com/leftovers/ol?.F*",
+		exp: []string{
+			"old/ignored.go:6:2: mention in a file excluded by build constraints",
+			"use/ignored.go:12:6: mention in a file excluded by build constraints",
+			"use/use.go:14:6: call that was not rewritten",
+			"use/use.go:15:11: non-call reference (function value)",
+		},
+	}, {
+		spec: "test.com/leftovers/old.T",
+		exp: []string{
+			"old/ignored.go:5:16: mention in a file excluded by build constraints",
+			"old/ignored.go:7:9: mention in a file excluded by build constraints",
+			"use/use.go:10:6: struct embedding",
+			"use/use.go:19:8: use of a field embedding it",
+			"use/use.go:20:8: use of a field embedding it",
+			"use/use.go:20:15: type reference that was not rewritten",
+			"use/use.go:22:41: string that may refer to it via reflection",
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			spec, err := replace.ParseSymbolSpec(tc.spec)
+			require.NoError(t, err)
+
+			dir := testutil.Testdata(t)
+
+			found, err := Find(dir, []string{"./..."}, spec)
+			require.NoError(t, err)
+
+			got := testutil.Strings(t, dir, found, func(l *Leftover) *token.Position { return &l.Pos })
+
+			assert.Equal(t, tc.exp, got)
+		})
+	}
+}
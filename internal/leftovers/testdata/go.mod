@@ -0,0 +1,3 @@
+module test.com/leftovers
+
+go 1.23.2
@@ -0,0 +1,8 @@
+//go:build ignore
+
+package old
+
+func ignored() T {
+	F()
+	return T{}
+}
@@ -0,0 +1,5 @@
+package old
+
+type T struct{}
+
+func F() {}
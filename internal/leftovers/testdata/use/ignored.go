@@ -0,0 +1,17 @@
+//go:build ignore
+
+package use
+
+import (
+	"testing"
+
+	"test.com/leftovers/old"
+)
+
+func ignored(t *testing.T) {
+	old.F()
+
+	// Neither a T from another package, nor a local T, nor old.T in a comment is a reference.
+	var T testing.T
+	_ = T
+}
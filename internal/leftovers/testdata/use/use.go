@@ -0,0 +1,23 @@
+package use
+
+import (
+	"reflect"
+
+	"test.com/leftovers/old"
+)
+
+type S struct {
+	old.T
+}
+
+func f() {
+	old.F()
+	g := old.F
+	_ = g
+
+	var s S
+	_ = s.T
+	_ = S{T: old.T{}}
+
+	_ = reflect.TypeOf(s).Field(0).Name == "T"
+}
@@ -0,0 +1,352 @@
+// Package movetype moves a type from one package to another in steps that can each be committed on
+// their own: first the definition moves and the old name becomes an alias for it, then references to
+// the alias are migrated, and finally the alias is deleted.
+package movetype
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"github.com/cszczepaniak/go-refactor/internal/srcedit"
+	"github.com/cszczepaniak/go-refactor/internal/typecheck"
+	"golang.org/x/tools/go/packages"
+)
+
+// Alias returns the change that moves the definition of the type from, along with its methods, into a
+// new file in the package of to, and turns from into an alias for it. The moved code can't refer to
+// anything else in from's package, because it would be left behind, and the rest of from's package
+// can't use the type's unexported fields and methods, because it couldn't get to them any more.
+func Alias(dir string, from, to replace.SymbolSpec) (typecheck.Change, error) {
+	if from.Name() != to.Name() {
+		return typecheck.Change{}, fmt.Errorf("the type must keep its name when it's moved (%s != %s)", from.Name(), to.Name())
+	}
+
+	oldPkgs, newPkg, err := load(dir, from.Pkg, to.Pkg)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+	oldPkg := oldPkgs[0]
+
+	files, err := srcedit.Open(oldPkg)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	d, err := srcedit.FindType(oldPkg, files, from.Name())
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	if d.Spec.Assign.IsValid() {
+		return typecheck.Change{}, fmt.Errorf("%s is already an alias", from.Name())
+	}
+	if d.Spec.TypeParams != nil {
+		return typecheck.Change{}, fmt.Errorf("%s is generic and can't be replaced by an alias", from.Name())
+	}
+	if newPkg.Types.Scope().Lookup(to.Name()) != nil {
+		return typecheck.Change{}, fmt.Errorf("%s already declares %s", to.Pkg, to.Name())
+	}
+
+	methods := srcedit.FindMethods(oldPkg, files, d.Obj)
+
+	moved := []ast.Node{d.Spec}
+	for _, m := range methods {
+		moved = append(moved, m.Decl)
+	}
+
+	imports, err := movedImports(oldPkg, d.Obj, to.Pkg, moved)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	err = checkUnexportedUses(oldPkgs, oldPkg.Fset, d.Obj, moved)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	// Write the definition into the new package.
+	newSrc := &bytes.Buffer{}
+	fmt.Fprintf(newSrc, "package %s\n\n", newPkg.Name)
+	if len(imports) > 0 {
+		newSrc.WriteString("import (\n")
+		for _, imp := range imports {
+			newSrc.WriteString(imp + "\n")
+		}
+		newSrc.WriteString(")\n\n")
+	}
+
+	if doc := d.Doc(); doc != nil {
+		newSrc.Write(d.File.Text(doc.Pos(), doc.End()))
+		newSrc.WriteString("\n")
+	}
+	newSrc.WriteString("type ")
+	newSrc.Write(d.File.Text(d.Spec.Pos(), d.Spec.End()))
+	newSrc.WriteString("\n")
+
+	for _, m := range methods {
+		newSrc.WriteString("\n")
+		newSrc.Write(m.File.Text(m.Start(), m.Decl.End()))
+		newSrc.WriteString("\n")
+	}
+
+	newPath := filepath.Join(packageDir(newPkg), strings.ToLower(to.Name())+".go")
+	if _, err := os.Stat(newPath); err == nil {
+		return typecheck.Change{}, fmt.Errorf("%s already exists", newPath)
+	}
+
+	formatted, err := format.Source(newSrc.Bytes())
+	if err != nil {
+		return typecheck.Change{}, fmt.Errorf("error formatting the moved type: %w", err)
+	}
+
+	// Replace the old definition with an alias and delete its methods, which now live in the new
+	// package.
+	edits := map[*srcedit.File][]srcedit.Edit{
+		d.File: {d.File.Replacement(d.Spec.Type.Pos(), d.Spec.Type.End(), "= "+newPkg.Name+"."+to.Name())},
+	}
+	for _, m := range methods {
+		edits[m.File] = append(edits[m.File], m.Deletion())
+	}
+
+	oldEdits, err := srcedit.Apply(edits, to.Pkg)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	// The new file and the alias only make sense together, so they're one change.
+	return typecheck.Change{
+		Rule:    "movetype",
+		Posn:    oldPkg.Fset.Position(d.Spec.Pos()).String(),
+		Message: fmt.Sprintf("move %s to %s, leaving an alias behind", from.Name(), to.Pkg),
+		Edits:   append(oldEdits, typecheck.Edit{Filename: newPath, New: string(formatted)}),
+	}, nil
+}
+
+// IsAlias reports whether the type from has been turned into an alias for to.
+func IsAlias(dir string, from, to replace.SymbolSpec) (bool, error) {
+	oldPkgs, _, err := load(dir, from.Pkg, to.Pkg)
+	if err != nil {
+		return false, err
+	}
+
+	return isAlias(oldPkgs[0], from, to)
+}
+
+func isAlias(oldPkg *packages.Package, from, to replace.SymbolSpec) (bool, error) {
+	tn, ok := oldPkg.Types.Scope().Lookup(from.Name()).(*types.TypeName)
+	if !ok {
+		return false, fmt.Errorf("type %s not found in %s", from.Name(), from.Pkg)
+	}
+
+	if !tn.IsAlias() {
+		return false, nil
+	}
+
+	named, ok := types.Unalias(tn.Type()).(*types.Named)
+	return ok && to.Matches(named.Obj()), nil
+}
+
+// DeleteAlias returns the change that deletes the alias declared by Alias, along with its doc comment.
+func DeleteAlias(dir string, from, to replace.SymbolSpec) (typecheck.Change, error) {
+	oldPkgs, _, err := load(dir, from.Pkg, to.Pkg)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+	oldPkg := oldPkgs[0]
+
+	ok, err := isAlias(oldPkg, from, to)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+	if !ok {
+		return typecheck.Change{}, fmt.Errorf("%s is not an alias for %s.%s", from.Name(), to.Pkg, to.Name())
+	}
+
+	files, err := srcedit.Open(oldPkg)
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	d, err := srcedit.FindType(oldPkg, files, from.Name())
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	edits, err := srcedit.Apply(map[*srcedit.File][]srcedit.Edit{d.File: {d.Deletion()}}, "")
+	if err != nil {
+		return typecheck.Change{}, err
+	}
+
+	return typecheck.Change{
+		Rule:    "movetype",
+		Posn:    oldPkg.Fset.Position(d.Spec.Pos()).String(),
+		Message: "delete the alias " + from.Name(),
+		Edits:   edits,
+	}, nil
+}
+
+// load loads the package at fromPkg, followed by its test variant if it has tests, and the package at
+// toPkg.
+func load(dir string, fromPkg, toPkg string) ([]*packages.Package, *packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  analyzeutil.LoadMode,
+		Dir:   dir,
+		Tests: true,
+	}, fromPkg, toPkg)
+	if err != nil {
+		return nil, nil, exitcode.Wrap(exitcode.Load, err)
+	}
+
+	var oldPkgs []*packages.Package
+	var newPkg *packages.Package
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, nil, exitcode.Wrap(exitcode.Load, fmt.Errorf("error loading %s: %v", pkg.PkgPath, pkg.Errors[0]))
+		}
+
+		switch {
+		case pkg.PkgPath == fromPkg && pkg.ID == fromPkg:
+			oldPkgs = slices.Insert(oldPkgs, 0, pkg)
+		case pkg.PkgPath == fromPkg:
+			// The test variant, which has the package's tests in it too.
+			oldPkgs = append(oldPkgs, pkg)
+		case pkg.PkgPath == toPkg && pkg.ID == toPkg:
+			newPkg = pkg
+		}
+	}
+
+	if len(oldPkgs) == 0 || oldPkgs[0].ID != fromPkg || newPkg == nil {
+		return nil, nil, errors.New("both packages must exist")
+	}
+
+	return oldPkgs, newPkg, nil
+}
+
+// checkUnexportedUses fails if code in pkgs (the package of tn and its test variant) that isn't moving
+// with tn uses its unexported fields or methods, which it won't be able to get to once tn is declared
+// in another package.
+func checkUnexportedUses(pkgs []*packages.Package, fset *token.FileSet, tn *types.TypeName, moved []ast.Node) error {
+	var spans [][2]token.Position
+	for _, n := range moved {
+		spans = append(spans, [2]token.Position{fset.Position(n.Pos()), fset.Position(n.End())})
+	}
+	isMoved := func(pos token.Position) bool {
+		return slices.ContainsFunc(spans, func(s [2]token.Position) bool {
+			return pos.Filename == s[0].Filename && s[0].Offset <= pos.Offset && pos.Offset < s[1].Offset
+		})
+	}
+
+	seen := make(analyzeutil.Seen[token.Position])
+	var uses []string
+	for _, pkg := range pkgs {
+		// Each variant has its own objects for the type's members.
+		members := unexportedMembers(pkg.Types.Scope().Lookup(tn.Name()))
+		for id, obj := range pkg.TypesInfo.Uses {
+			if !members[obj] {
+				continue
+			}
+
+			pos := pkg.Fset.Position(id.Pos())
+			if !isMoved(pos) && seen.First(pos) {
+				uses = append(uses, fmt.Sprintf("%s: %s", pos, id.Name))
+			}
+		}
+	}
+	if len(uses) == 0 {
+		return nil
+	}
+
+	slices.Sort(uses)
+	return fmt.Errorf(
+		"%s can't be moved because %s uses its unexported fields and methods:\n\t%s",
+		tn.Name(), tn.Pkg().Path(), strings.Join(uses, "\n\t"),
+	)
+}
+
+// unexportedMembers returns the unexported fields and methods declared on the type obj.
+func unexportedMembers(obj types.Object) map[types.Object]bool {
+	members := make(map[types.Object]bool)
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return members
+	}
+
+	for i := range named.NumMethods() {
+		if m := named.Method(i); !m.Exported() {
+			members[m] = true
+		}
+	}
+	if st, ok := named.Underlying().(*types.Struct); ok {
+		for i := range st.NumFields() {
+			if f := st.Field(i); !f.Exported() {
+				members[f] = true
+			}
+		}
+	}
+
+	return members
+}
+
+// movedImports returns the import specs the moved nodes need in their new package. It fails if the
+// nodes refer to anything in their old package other than the type being moved and its members, or to
+// the package they're moving to.
+func movedImports(pkg *packages.Package, tn *types.TypeName, toPkg string, nodes []ast.Node) ([]string, error) {
+	var imports []string
+	for _, n := range nodes {
+		var err error
+		ast.Inspect(n, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || err != nil {
+				return err == nil
+			}
+
+			switch obj := pkg.TypesInfo.Uses[id].(type) {
+			case *types.PkgName:
+				if obj.Imported().Path() == toPkg {
+					err = fmt.Errorf("%s refers to %s, the package it's moving to", tn.Name(), toPkg)
+					return false
+				}
+
+				imp := strconv.Quote(obj.Imported().Path())
+				if obj.Name() != obj.Imported().Name() {
+					imp = obj.Name() + " " + imp
+				}
+				if !slices.Contains(imports, imp) {
+					imports = append(imports, imp)
+				}
+			case nil:
+			default:
+				if obj != tn && obj.Pkg() == pkg.Types && obj.Parent() == pkg.Types.Scope() {
+					err = fmt.Errorf("%s refers to %s, which would be left behind in %s", tn.Name(), obj.Name(), pkg.PkgPath)
+					return false
+				}
+			}
+
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slices.Sort(imports)
+	return imports, nil
+}
+
+func packageDir(pkg *packages.Package) string {
+	return filepath.Dir(pkg.GoFiles[0])
+}
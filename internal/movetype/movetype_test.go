@@ -0,0 +1,125 @@
+package movetype
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/cszczepaniak/go-refactor/internal/typecheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+	from, to := specs(t, "test.com/movetype/old.Client", "test.com/movetype/newer.Client")
+
+	write(t, dir, func() (typecheck.Change, error) { return Alias(dir, from, to) })
+
+	assert.Equal(t, `package newer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Client talks to the server.
+type Client struct {
+	Addr string
+}
+
+// Host returns the host part of the address.
+func (c *Client) Host() string {
+	host, _, _ := strings.Cut(c.Addr, ":")
+	return host
+}
+
+func (c Client) upper() bool {
+	return unicode.IsUpper(rune(c.Addr[0]))
+}
+`, testutil.ReadFile(t, dir, "newer/client.go"))
+
+	assert.Equal(t, `package old
+
+import "test.com/movetype/newer"
+
+// Client talks to the server.
+type Client = newer.Client
+
+type (
+	// A is declared in a group.
+	A struct{ b B }
+
+	// B is declared in a group too.
+	B int
+)
+`, testutil.ReadFile(t, dir, "old/old.go"))
+
+	isAlias, err := IsAlias(dir, from, to)
+	require.NoError(t, err)
+	assert.True(t, isAlias)
+
+	write(t, dir, func() (typecheck.Change, error) { return DeleteAlias(dir, from, to) })
+
+	assert.Equal(t, `package old
+
+type (
+	// A is declared in a group.
+	A struct{ b B }
+
+	// B is declared in a group too.
+	B int
+)
+`, testutil.ReadFile(t, dir, "old/old.go"))
+}
+
+func TestAlias_LeftBehind(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+	from, to := specs(t, "test.com/movetype/old.A", "test.com/movetype/newer.A")
+
+	_, err := Alias(dir, from, to)
+	assert.EqualError(t, err, "A refers to B, which would be left behind in test.com/movetype/old")
+}
+
+func TestAlias_UnexportedUses(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+	from, to := specs(t, "test.com/movetype/old.Server", "test.com/movetype/newer.Server")
+
+	_, err := Alias(dir, from, to)
+	assert.EqualError(t, err, `Server can't be moved because test.com/movetype/old uses its unexported fields and methods:
+	`+filepath.Join(dir, "old/server.go")+`:15:11: addr
+	`+filepath.Join(dir, "old/server_test.go")+`:4:31: port`)
+}
+
+func TestDeleteAlias_NotAnAlias(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+	from, to := specs(t, "test.com/movetype/old.Client", "test.com/movetype/newer.Client")
+
+	_, err := DeleteAlias(dir, from, to)
+	assert.EqualError(t, err, "Client is not an alias for test.com/movetype/newer.Client")
+}
+
+// write writes the change made by f.
+func write(t *testing.T, dir string, f func() (typecheck.Change, error)) {
+	t.Helper()
+
+	c, err := f()
+	require.NoError(t, err)
+
+	failures, err := typecheck.NewSession(dir, []string{"./..."}, typecheck.Abort).Write([]typecheck.Change{c})
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+}
+
+func specs(t *testing.T, from, to string) (replace.SymbolSpec, replace.SymbolSpec) {
+	t.Helper()
+
+	fromSpec, err := replace.ParseSymbolSpec(from)
+	require.NoError(t, err)
+
+	toSpec, err := replace.ParseSymbolSpec(to)
+	require.NoError(t, err)
+
+	return fromSpec, toSpec
+}
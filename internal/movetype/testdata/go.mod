@@ -0,0 +1,3 @@
+module test.com/movetype
+
+go 1.23.2
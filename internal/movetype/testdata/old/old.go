@@ -0,0 +1,29 @@
+package old
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Client talks to the server.
+type Client struct {
+	Addr string
+}
+
+// Host returns the host part of the address.
+func (c *Client) Host() string {
+	host, _, _ := strings.Cut(c.Addr, ":")
+	return host
+}
+
+func (c Client) upper() bool {
+	return unicode.IsUpper(rune(c.Addr[0]))
+}
+
+type (
+	// A is declared in a group.
+	A struct{ b B }
+
+	// B is declared in a group too.
+	B int
+)
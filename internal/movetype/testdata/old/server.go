@@ -0,0 +1,16 @@
+package old
+
+// Server serves clients.
+type Server struct {
+	Name string
+	port int
+}
+
+func (s Server) addr() string {
+	return s.Name
+}
+
+// Dial uses the server's unexported members, so it would stop compiling if Server moved.
+func Dial(s Server) string {
+	return s.addr()
+}
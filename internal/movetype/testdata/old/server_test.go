@@ -0,0 +1,5 @@
+package old
+
+func newServer() Server {
+	return Server{Name: "local", port: 80}
+}
@@ -0,0 +1,169 @@
+// Package original deletes the declaration of a symbol once a migration has replaced every reference
+// to it.
+package original
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"slices"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"github.com/cszczepaniak/go-refactor/internal/leftovers"
+	"github.com/cszczepaniak/go-refactor/internal/srcedit"
+	"github.com/cszczepaniak/go-refactor/internal/typecheck"
+	"golang.org/x/tools/go/packages"
+)
+
+// Delete returns the change that deletes the declaration of the type or function described by spec,
+// along with its doc comment and, for a type, its methods. The packages matching patterns (relative to
+// dir) and the symbol's own package are scanned for leftover references first. References from inside
+// the declarations being deleted don't count. If there are any others, they're returned instead.
+func Delete(dir string, patterns []string, spec replace.SymbolSpec) (typecheck.Change, []leftovers.Leftover, error) {
+	pkg, err := load(dir, spec.Pkg)
+	if err != nil {
+		return typecheck.Change{}, nil, err
+	}
+
+	files, err := srcedit.Open(pkg)
+	if err != nil {
+		return typecheck.Change{}, nil, err
+	}
+
+	edits, deleted, err := deletions(pkg, files, spec)
+	if err != nil {
+		return typecheck.Change{}, nil, err
+	}
+
+	found, err := leftovers.Find(dir, append(slices.Clone(patterns), spec.Pkg), spec)
+	if err != nil {
+		return typecheck.Change{}, nil, err
+	}
+
+	found = slices.DeleteFunc(found, func(l leftovers.Leftover) bool {
+		return slices.ContainsFunc(deleted, func(r span) bool {
+			return r.contains(l.Pos)
+		})
+	})
+	if len(found) > 0 {
+		return typecheck.Change{}, found, nil
+	}
+
+	fileEdits, err := srcedit.Apply(edits, "")
+	if err != nil {
+		return typecheck.Change{}, nil, err
+	}
+
+	return typecheck.Change{
+		Rule:    "delete-original",
+		Posn:    deleted[0].start.String(),
+		Message: "delete " + spec.String(),
+		Edits:   fileEdits,
+	}, nil, nil
+}
+
+// span is the source of a declaration being deleted.
+type span struct {
+	start, end token.Position
+}
+
+func (s span) contains(pos token.Position) bool {
+	return pos.Filename == s.start.Filename && s.start.Offset <= pos.Offset && pos.Offset < s.end.Offset
+}
+
+// deletions returns the edits that delete the symbol described by spec, and the spans they delete.
+func deletions(pkg *packages.Package, files []*srcedit.File, spec replace.SymbolSpec) (map[*srcedit.File][]srcedit.Edit, []span, error) {
+	edits := make(map[*srcedit.File][]srcedit.Edit)
+	var deleted []span
+
+	deleteFunc := func(d srcedit.FuncDecl) {
+		edits[d.File] = append(edits[d.File], d.Deletion())
+		deleted = append(deleted, span{
+			start: pkg.Fset.Position(d.Start()),
+			end:   pkg.Fset.Position(d.Decl.End()),
+		})
+	}
+
+	obj := lookup(pkg.Types, spec)
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		d, err := srcedit.FindType(pkg, files, obj.Name())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		edits[d.File] = append(edits[d.File], d.Deletion())
+		deleted = append(deleted, span{
+			start: pkg.Fset.Position(d.Spec.Pos()),
+			end:   pkg.Fset.Position(d.Spec.End()),
+		})
+
+		for _, m := range srcedit.FindMethods(pkg, files, obj) {
+			deleteFunc(m)
+		}
+	case *types.Func:
+		d, err := srcedit.FindFunc(pkg, files, obj)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		deleteFunc(d)
+	case nil:
+		return nil, nil, fmt.Errorf("%s not found in %s", spec.Name(), spec.Pkg)
+	default:
+		return nil, nil, fmt.Errorf("%s is not a type or function", spec.Name())
+	}
+
+	return edits, deleted, nil
+}
+
+// lookup finds the package-level symbol or method described by spec in pkg.
+func lookup(pkg *types.Package, spec replace.SymbolSpec) types.Object {
+	if obj := pkg.Scope().Lookup(spec.Name()); spec.Matches(obj) {
+		return obj
+	}
+
+	for _, name := range pkg.Scope().Names() {
+		tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+
+		named, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+
+		for i := range named.NumMethods() {
+			if m := named.Method(i); spec.Matches(m) {
+				return m
+			}
+		}
+	}
+
+	return nil
+}
+
+func load(dir, path string) (*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: analyzeutil.LoadMode,
+		Dir:  dir,
+	}, path)
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.Load, err)
+	}
+
+	if len(pkgs) != 1 {
+		return nil, exitcode.Wrap(exitcode.Load, errors.New("loaded an unexpected number of packages"))
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, exitcode.Wrap(exitcode.Load, fmt.Errorf("error loading %s: %v", pkg.PkgPath, pkg.Errors[0]))
+	}
+
+	return pkg, nil
+}
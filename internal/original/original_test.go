@@ -0,0 +1,163 @@
+package original
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/leftovers"
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/cszczepaniak/go-refactor/internal/typecheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelete(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	// Retry's call to itself goes with it.
+	change, found, err := Delete(dir, []string{"./..."}, spec(t, "test.com/original/old.Retry"))
+	require.NoError(t, err)
+	assert.Empty(t, found)
+	write(t, dir, change)
+
+	assert.Equal(t, `package old
+
+import "strings"
+
+// Client talks to the server.
+type Client struct {
+	Addr string
+	next *Client
+}
+
+// Host returns the host part of the address.
+func (c *Client) Host() string {
+	host, _, _ := strings.Cut(c.Addr, ":")
+	return host
+}
+
+// Dial is no longer called.
+func Dial(addr string) *Client {
+	return &Client{Addr: addr}
+}
+
+// Open is still called.
+func Open(addr string) *Client {
+	return Dial(addr)
+}
+`, testutil.ReadFile(t, dir, "old/old.go"))
+}
+
+func TestDelete_Type(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old/old.go"), []byte(`package old
+
+import "strings"
+
+// Client talks to the server.
+type Client struct {
+	Addr string
+	next *Client
+}
+
+// Host returns the host part of the address.
+func (c *Client) Host() string {
+	host, _, _ := strings.Cut(c.Addr, ":")
+	return host
+}
+
+// Open is still called.
+func Open(addr string) string {
+	return addr
+}
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "use/use.go"), []byte(`package use
+
+import "test.com/original/old"
+
+func Host(addr string) string {
+	return old.Open(addr)
+}
+`), 0o644))
+
+	change, found, err := Delete(dir, []string{"./..."}, spec(t, "test.com/original/old.Client"))
+	require.NoError(t, err)
+	assert.Empty(t, found)
+	write(t, dir, change)
+
+	assert.Equal(t, `package old
+
+// Open is still called.
+func Open(addr string) string {
+	return addr
+}
+`, testutil.ReadFile(t, dir, "old/old.go"))
+}
+
+func TestDelete_Leftovers(t *testing.T) {
+	tests := []struct {
+		spec string
+		exp  []string
+	}{{
+		spec: "test.com/original/old.Dial",
+		exp: []string{
+			"old/old.go:24:9: call that was not rewritten",
+		},
+	}, {
+		spec: "test.com/original/old.Client",
+		exp: []string{
+			"old/old.go:18:25: type reference that was not rewritten",
+			"old/old.go:19:10: type reference that was not rewritten",
+			"old/old.go:23:25: type reference that was not rewritten",
+		},
+	}, {
+		spec: "test.com/original/old.Client.Host",
+		exp: []string{
+			"use/use.go:6:24: call that was not rewritten",
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			dir := testutil.CopyTestdata(t)
+
+			_, found, err := Delete(dir, []string{"./..."}, spec(t, tc.spec))
+			require.NoError(t, err)
+
+			got := testutil.Strings(t, dir, found, func(l *leftovers.Leftover) *token.Position { return &l.Pos })
+			assert.Equal(t, tc.exp, got)
+
+			// Nothing is deleted while there are references left.
+			orig, err := os.ReadFile("testdata/old/old.go")
+			require.NoError(t, err)
+			assert.Equal(t, string(orig), testutil.ReadFile(t, dir, "old/old.go"))
+		})
+	}
+}
+
+func TestDelete_NotFound(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	_, _, err := Delete(dir, []string{"./..."}, spec(t, "test.com/original/old.Missing"))
+	assert.EqualError(t, err, "Missing not found in test.com/original/old")
+}
+
+// write writes change, checking that the result type-checks.
+func write(t *testing.T, dir string, change typecheck.Change) {
+	t.Helper()
+
+	failures, err := typecheck.NewSession(dir, []string{"./..."}, typecheck.Abort).Write([]typecheck.Change{change})
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+}
+
+func spec(t *testing.T, s string) replace.SymbolSpec {
+	t.Helper()
+
+	spec, err := replace.ParseSymbolSpec(s)
+	require.NoError(t, err)
+	return spec
+}
@@ -0,0 +1,3 @@
+module test.com/original
+
+go 1.23.2
@@ -0,0 +1,32 @@
+package old
+
+import "strings"
+
+// Client talks to the server.
+type Client struct {
+	Addr string
+	next *Client
+}
+
+// Host returns the host part of the address.
+func (c *Client) Host() string {
+	host, _, _ := strings.Cut(c.Addr, ":")
+	return host
+}
+
+// Dial is no longer called.
+func Dial(addr string) *Client {
+	return &Client{Addr: addr}
+}
+
+// Open is still called.
+func Open(addr string) *Client {
+	return Dial(addr)
+}
+
+// Retry calls itself.
+func Retry(n int) {
+	if n > 0 {
+		Retry(n - 1)
+	}
+}
@@ -0,0 +1,7 @@
+package use
+
+import "test.com/original/old"
+
+func Host(addr string) string {
+	return old.Open(addr).Host()
+}
@@ -0,0 +1,419 @@
+package preflight
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Problem is a place that won't compile once a type is replaced.
+type Problem struct {
+	Pos    token.Position
+	Reason string
+}
+
+func (p Problem) String() string {
+	return p.Pos.String() + ": " + p.Reason
+}
+
+// Check loads the packages matching patterns (relative to dir) and reports the places where replacing
+// the type from with the type to (changing indirection as shape says) would break the build: methods
+// called on from that to doesn't have or has with a different signature, and interfaces from is used
+// as that to doesn't implement. Only values whose type is declared with a reference that scope
+// includes are checked, since the others keep their type.
+func Check(
+	dir string,
+	patterns []string,
+	from, to replace.SymbolSpec,
+	shape replace.PointerShape,
+	scope replace.TypeScope,
+) ([]Problem, error) {
+	// The replacement's package has to be loaded to know its methods, whether or not it's one of the
+	// packages being rewritten.
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  analyzeutil.LoadMode,
+		Dir:   dir,
+		Tests: true,
+	}, append(slices.Clone(patterns), to.Pkg)...)
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.Load, err)
+	}
+
+	toType, err := findType(pkgs, to)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checker{
+		from:   from,
+		toSpec: to,
+		to:     toType,
+		shape:  shape,
+		fset:   pkgs[0].Fset,
+		scope:  scope,
+		files:  make(map[*token.File]declFile),
+		seen:   make(analyzeutil.Seen[token.Position]),
+	}
+
+	if !scope.IsZero() {
+		packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+			for _, f := range pkg.Syntax {
+				tf := pkg.Fset.File(f.FileStart)
+				if _, ok := c.files[tf]; !ok {
+					c.files[tf] = declFile{pkg: pkg, file: f}
+				}
+			}
+		})
+	}
+
+	for _, pkg := range pkgs {
+		if pkg.PkgPath == to.Pkg && !slices.Contains(patterns, to.Pkg) {
+			// Only loaded to find the replacement.
+			continue
+		}
+
+		c.checkPackage(pkg)
+	}
+
+	slices.SortFunc(c.problems, func(a, b Problem) int {
+		if c := strings.Compare(a.Pos.Filename, b.Pos.Filename); c != 0 {
+			return c
+		}
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line - b.Pos.Line
+		}
+		return a.Pos.Column - b.Pos.Column
+	})
+
+	return c.problems, nil
+}
+
+type checker struct {
+	from   replace.SymbolSpec
+	toSpec replace.SymbolSpec
+	to     *types.TypeName
+	shape  replace.PointerShape
+	fset   *token.FileSet
+	scope  replace.TypeScope
+
+	// files holds the syntax of every loaded file, to find the declarations of values in when the
+	// scope is restricted.
+	files map[*token.File]declFile
+
+	seen     analyzeutil.Seen[token.Position]
+	problems []Problem
+}
+
+type declFile struct {
+	pkg  *packages.Package
+	file *ast.File
+}
+
+func (c *checker) report(fset *token.FileSet, pos token.Pos, format string, args ...any) {
+	position := fset.Position(pos)
+	if !c.seen.First(position) {
+		return
+	}
+
+	c.problems = append(c.problems, Problem{
+		Pos:    position,
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) checkPackage(pkg *packages.Package) {
+	info := pkg.TypesInfo
+	if info == nil {
+		return
+	}
+
+	for sel, selection := range info.Selections {
+		if selection.Kind() == types.FieldVal {
+			continue
+		}
+		c.checkMethod(pkg, sel, selection)
+	}
+
+	replace.ForEachAssignment(info, pkg.Syntax, func(_ *ast.File, target types.Type, e ast.Expr) bool {
+		c.checkInterface(pkg, target, info.TypeOf(e), e)
+		return true
+	})
+
+	// Explicit conversions to interfaces, like io.Reader(x), need the interface to be satisfied too.
+	for _, f := range pkg.Syntax {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if ok && len(call.Args) == 1 && info.Types[call.Fun].IsType() {
+				c.checkInterface(pkg, info.TypeOf(call.Fun), info.TypeOf(call.Args[0]), call.Args[0])
+			}
+			return true
+		})
+	}
+}
+
+// checkMethod checks that a method selected on the type being replaced exists on the replacement with
+// the same signature.
+func (c *checker) checkMethod(pkg *packages.Package, sel *ast.SelectorExpr, selection *types.Selection) {
+	fn, ok := selection.Obj().(*types.Func)
+	if !ok {
+		return
+	}
+
+	recv := fn.Signature().Recv()
+	if recv == nil || !c.isFrom(analyzeutil.Deref(recv.Type())) || !c.replaced(pkg.TypesInfo, sel.X) {
+		return
+	}
+
+	// The methods of a generic replacement have to be instantiated like the original's were to compare
+	// their signatures.
+	to, ok := c.instantiate(analyzeutil.Deref(recv.Type()))
+	if !ok {
+		return
+	}
+
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(to), true, c.to.Pkg(), fn.Name())
+	replacement, ok := obj.(*types.Func)
+	if !ok {
+		c.report(pkg.Fset, sel.Sel.Pos(), "%s has no method %s", typeString(to), fn.Name())
+		return
+	}
+
+	if !types.Identical(withoutRecv(fn.Signature()), withoutRecv(replacement.Signature())) {
+		c.report(
+			pkg.Fset,
+			sel.Sel.Pos(),
+			"%s is %s on %s but %s on %s",
+			fn.Name(),
+			typeString(withoutRecv(fn.Signature())), typeString(analyzeutil.Deref(recv.Type())),
+			typeString(withoutRecv(replacement.Signature())), typeString(to),
+		)
+	}
+}
+
+// checkInterface checks that, if a value of typ (the type being replaced or a pointer to it) is used
+// as the interface target, the replacement implements it too.
+func (c *checker) checkInterface(pkg *packages.Package, target, typ types.Type, e ast.Expr) {
+	if target == nil || typ == nil || !types.IsInterface(target) {
+		return
+	}
+
+	iface, ok := target.Underlying().(*types.Interface)
+	if !ok {
+		return
+	}
+
+	replacement, ok := c.replacementOf(typ)
+	if !ok || !c.replaced(pkg.TypesInfo, e) {
+		return
+	}
+
+	if types.Implements(replacement, iface) {
+		return
+	}
+
+	method, wrongType := types.MissingMethod(replacement, iface, true)
+	var reason string
+	switch {
+	case !wrongType:
+		reason = fmt.Sprintf("%s is missing method %s", typeString(replacement), method.Name())
+	case types.Implements(types.NewPointer(replacement), iface):
+		reason = fmt.Sprintf("%s has a pointer receiver on %s", method.Name(), typeString(replacement))
+	default:
+		reason = fmt.Sprintf("%s has the wrong signature for method %s", typeString(replacement), method.Name())
+	}
+
+	c.report(pkg.Fset, e.Pos(), "%s is used as %s here, but %s", typeString(typ), typeString(target), reason)
+}
+
+// replacementOf returns the type a value of typ becomes once the type is replaced, if typ is the type
+// being replaced or a pointer to it.
+func (c *checker) replacementOf(typ types.Type) (types.Type, bool) {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		if !c.isFrom(ptr.Elem()) {
+			return nil, false
+		}
+		to, ok := c.instantiate(ptr.Elem())
+		if !ok {
+			return nil, false
+		}
+		if c.shape == replace.ShapePointerToValue {
+			return to, true
+		}
+		return types.NewPointer(to), true
+	}
+
+	if !c.isFrom(typ) {
+		return nil, false
+	}
+	to, ok := c.instantiate(typ)
+	if !ok {
+		return nil, false
+	}
+	if c.shape == replace.ShapeValueToPointer {
+		return types.NewPointer(to), true
+	}
+	return to, true
+}
+
+func (c *checker) isFrom(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && c.from.Matches(named.Origin().Obj())
+}
+
+// instantiate returns the replacement for from, an instance of the type being replaced. It reports
+// false if the replacement's type arguments can't be worked out.
+func (c *checker) instantiate(from types.Type) (types.Type, bool) {
+	named, ok := from.(*types.Named)
+	if !ok || named.TypeArgs().Len() == 0 {
+		return c.to.Type(), true
+	}
+
+	args, ok := c.toSpec.TypeArgsFor(named.TypeArgs())
+	if !ok {
+		return nil, false
+	}
+
+	to, err := types.Instantiate(nil, c.to.Type(), args, false)
+	if err != nil {
+		return nil, false
+	}
+	return to, true
+}
+
+// replaced reports whether the value e changes type with the replacement, which is only known for
+// sure when the scope includes every reference. Otherwise, it depends on whether the reference the
+// value's type comes from is in the scope.
+func (c *checker) replaced(info *types.Info, e ast.Expr) bool {
+	if c.scope.IsZero() {
+		return true
+	}
+	return c.declReplaced(objectOf(info, e), 0)
+}
+
+// declReplaced reports whether the reference in the declaration of obj that gives it its type is in
+// the scope. Declarations that get their type from a value are followed to that value, up to a
+// point. When there's no telling, it's assumed to be replaced.
+func (c *checker) declReplaced(obj types.Object, depth int) bool {
+	if obj == nil || depth > 8 {
+		return true
+	}
+
+	df, ok := c.files[c.fset.File(obj.Pos())]
+	if !ok {
+		return true
+	}
+	info := df.pkg.TypesInfo
+
+	path, _ := astutil.PathEnclosingInterval(df.file, obj.Pos(), obj.Pos())
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return true
+	}
+	slices.Reverse(path)
+
+	// typeIn reports whether typ, a type in the declaration at the end of stack, is in the scope.
+	typeIn := func(stack []ast.Node, typ ...ast.Node) bool {
+		stack = append(slices.Clip(stack), typ...)
+		return c.scope.Includes(df.pkg.PkgPath, info, stack[len(stack)-1], stack)
+	}
+
+	for i := len(path) - 1; i >= 0; i-- {
+		switch n := path[i].(type) {
+		case *ast.Field:
+			return typeIn(path[:i+1], n.Type)
+		case *ast.ValueSpec:
+			if n.Type != nil {
+				return typeIn(path[:i+1], n.Type)
+			}
+			j := slices.Index(n.Names, id)
+			if j < 0 || len(n.Values) != len(n.Names) {
+				return true
+			}
+			return c.declReplaced(objectOf(info, n.Values[j]), depth+1)
+		case *ast.AssignStmt:
+			j := slices.IndexFunc(n.Lhs, func(e ast.Expr) bool { return e == id })
+			if j < 0 || len(n.Rhs) != len(n.Lhs) {
+				return true
+			}
+			return c.declReplaced(objectOf(info, n.Rhs[j]), depth+1)
+		case *ast.FuncDecl:
+			if n.Name != id || n.Type.Results == nil {
+				return true
+			}
+			// The value is the result of a call, so it changes if any of the results do.
+			for _, field := range n.Type.Results.List {
+				if typeIn(path[:i+1], n.Type, n.Type.Results, field, field.Type) {
+					return true
+				}
+			}
+			return false
+		case ast.Stmt, ast.Decl:
+			return true
+		}
+	}
+
+	return true
+}
+
+// objectOf returns the object that gives e its type: the variable, field or function it refers to or
+// calls. It's nil if there isn't one.
+func objectOf(info *types.Info, e ast.Expr) types.Object {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return info.ObjectOf(e)
+	case *ast.SelectorExpr:
+		if sel, ok := info.Selections[e]; ok {
+			return sel.Obj()
+		}
+		return info.ObjectOf(e.Sel)
+	case *ast.StarExpr:
+		return objectOf(info, e.X)
+	case *ast.UnaryExpr:
+		return objectOf(info, e.X)
+	case *ast.IndexExpr:
+		return objectOf(info, e.X)
+	case *ast.CallExpr:
+		fn, _ := typeutil.Callee(info, e).(*types.Func)
+		if fn == nil {
+			return nil
+		}
+		return fn
+	}
+	return nil
+}
+
+func findType(pkgs []*packages.Package, spec replace.SymbolSpec) (*types.TypeName, error) {
+	var found *types.TypeName
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if found != nil || pkg.PkgPath != spec.Pkg || pkg.Types == nil {
+			return
+		}
+		found, _ = pkg.Types.Scope().Lookup(spec.Name()).(*types.TypeName)
+	})
+
+	if found == nil {
+		return nil, fmt.Errorf("type %s not found in package %s", spec.Name(), spec.Pkg)
+	}
+	return found, nil
+}
+
+// typeString formats t with types qualified by their package names rather than their paths.
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+func withoutRecv(sig *types.Signature) *types.Signature {
+	return types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+}
@@ -0,0 +1,106 @@
+package preflight
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	from, err := replace.ParseSymbolSpec("test.com/preflight/old.T")
+	require.NoError(t, err)
+
+	to, err := replace.ParseSymbolSpec("test.com/preflight/newer.T")
+	require.NoError(t, err)
+
+	dir := testutil.Testdata(t)
+
+	problems, err := Check(dir, []string{"./use/..."}, from, to, replace.ShapeSame, replace.TypeScope{})
+	require.NoError(t, err)
+
+	got := testutil.Strings(t, dir, problems, func(p *Problem) *token.Position { return &p.Pos })
+
+	assert.Equal(t, []string{
+		"use/scope.go:10:13: Get is func() int on old.T but func() int64 on newer.T",
+		"use/scope.go:15:11: Get is func() int on old.T but func() int64 on newer.T",
+		"use/scope.go:23:15: Get is func() int on old.T but func() int64 on newer.T",
+		"use/use.go:14:8: Get is func() int on old.T but func() int64 on newer.T",
+		"use/use.go:17:16: old.T is used as use.Named here, but newer.T is missing method Name",
+		"use/use.go:20:10: old.T is used as use.Closer here, but Close has a pointer receiver on newer.T",
+		"use/use.go:22:13: old.T is used as use.Closer here, but Close has a pointer receiver on newer.T",
+	}, got)
+}
+
+func TestCheck_Scope(t *testing.T) {
+	tests := []struct {
+		only, within string
+		exp          []string
+	}{{
+		only: "fields",
+		exp: []string{
+			"use/scope.go:10:13: Get is func() int on old.T but func() int64 on newer.T",
+			"use/scope.go:15:11: Get is func() int on old.T but func() int64 on newer.T",
+		},
+	}, {
+		only: "results",
+		exp: []string{
+			"use/scope.go:23:15: Get is func() int on old.T but func() int64 on newer.T",
+		},
+	}, {
+		within: "test.com/preflight/use.Use",
+		exp: []string{
+			"use/use.go:14:8: Get is func() int on old.T but func() int64 on newer.T",
+			"use/use.go:17:16: old.T is used as use.Named here, but newer.T is missing method Name",
+			"use/use.go:20:10: old.T is used as use.Closer here, but Close has a pointer receiver on newer.T",
+			"use/use.go:22:13: old.T is used as use.Closer here, but Close has a pointer receiver on newer.T",
+		},
+	}, {
+		within: "test.com/other/...",
+		exp:    []string{},
+	}}
+
+	from, err := replace.ParseSymbolSpec("test.com/preflight/old.T")
+	require.NoError(t, err)
+
+	to, err := replace.ParseSymbolSpec("test.com/preflight/newer.T")
+	require.NoError(t, err)
+
+	dir := testutil.Testdata(t)
+
+	for _, tc := range tests {
+		t.Run(tc.only+tc.within, func(t *testing.T) {
+			scope, err := replace.ParseTypeScope(tc.only, tc.within)
+			require.NoError(t, err)
+
+			problems, err := Check(dir, []string{"./use/..."}, from, to, replace.ShapeSame, scope)
+			require.NoError(t, err)
+
+			got := testutil.Strings(t, dir, problems, func(p *Problem) *token.Position { return &p.Pos })
+			assert.Equal(t, tc.exp, got)
+		})
+	}
+}
+
+func TestCheck_Generic(t *testing.T) {
+	from, err := replace.ParseSymbolSpec("test.com/preflight/old.G")
+	require.NoError(t, err)
+
+	to, err := replace.ParseSymbolSpec("test.com/preflight/newer.G")
+	require.NoError(t, err)
+
+	dir := testutil.Testdata(t)
+
+	problems, err := Check(dir, []string{"./use/..."}, from, to, replace.ShapeSame, replace.TypeScope{})
+	require.NoError(t, err)
+
+	got := testutil.Strings(t, dir, problems, func(p *Problem) *token.Position { return &p.Pos })
+
+	// Get returns the type argument on both, so only Len differs once they're instantiated.
+	assert.Equal(t, []string{
+		"use/generic.go:7:8: Len is func() int on old.G[string] but func() int64 on newer.G[string]",
+	}, got)
+}
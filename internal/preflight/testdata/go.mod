@@ -0,0 +1,3 @@
+module test.com/preflight
+
+go 1.23.2
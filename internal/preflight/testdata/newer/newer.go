@@ -0,0 +1,12 @@
+package newer
+
+type T struct{}
+
+func (T) Get() int64    { return 0 }
+func (*T) Set(v int)    {}
+func (*T) Close() error { return nil }
+
+type G[X any] struct{}
+
+func (G[X]) Get() X     { var x X; return x }
+func (G[X]) Len() int64 { return 0 }
@@ -0,0 +1,13 @@
+package old
+
+type T struct{}
+
+func (T) Get() int     { return 0 }
+func (*T) Set(v int)   {}
+func (T) Name() string { return "" }
+func (T) Close() error { return nil }
+
+type G[X any] struct{}
+
+func (G[X]) Get() X   { var x X; return x }
+func (G[X]) Len() int { return 0 }
@@ -0,0 +1,8 @@
+package use
+
+import "test.com/preflight/old"
+
+func UseG(g old.G[string]) {
+	_ = g.Get() + ""
+	_ = g.Len()
+}
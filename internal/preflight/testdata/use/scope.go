@@ -0,0 +1,24 @@
+package use
+
+import "test.com/preflight/old"
+
+type Holder struct {
+	t old.T
+}
+
+func (h Holder) Get() int {
+	return h.t.Get()
+}
+
+func (h Holder) Copy() int {
+	t := h.t
+	return t.Get()
+}
+
+func New() old.T {
+	return old.T{}
+}
+
+func UseNew() int {
+	return New().Get()
+}
@@ -0,0 +1,25 @@
+package use
+
+import "test.com/preflight/old"
+
+type Named interface {
+	Name() string
+}
+
+type Closer interface {
+	Close() error
+}
+
+func Use(t old.T) {
+	_ = t.Get()
+	t.Set(1)
+
+	var n Named = t
+	_ = n
+
+	closeIt(t)
+	closeIt(&t)
+	_ = Closer(t)
+}
+
+func closeIt(c Closer) {}
@@ -0,0 +1,168 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// At returns the spec of the symbol referred to or declared at posn, which is either file:line:col
+// (with the column counted in bytes, like the go command does) or file:#offset. The file is relative
+// to dir. This lets editors name the symbol under the cursor instead of spelling out its spec.
+func At(dir, posn string) (replace.SymbolSpec, Kind, error) {
+	file, at, err := parsePosn(posn)
+	if err != nil {
+		return replace.SymbolSpec{}, 0, err
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	file, err = filepath.Abs(file)
+	if err != nil {
+		return replace.SymbolSpec{}, 0, err
+	}
+
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  analyzeutil.LoadMode,
+		Dir:   dir,
+		Tests: true,
+	}, "file="+file)
+	if err != nil {
+		return replace.SymbolSpec{}, 0, exitcode.Wrap(exitcode.Load, err)
+	}
+
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			tf := pkg.Fset.File(f.Pos())
+			if tf == nil || tf.Name() != file {
+				continue
+			}
+
+			pos, err := at(tf)
+			if err != nil {
+				return replace.SymbolSpec{}, 0, fmt.Errorf("%s: %w", posn, err)
+			}
+
+			return objectAt(pkg.TypesInfo, f, pos, posn)
+		}
+	}
+
+	return replace.SymbolSpec{}, 0, fmt.Errorf("%s: no package contains the file", posn)
+}
+
+// parsePosn splits posn into its file and a function returning the position it points to in that
+// file.
+func parsePosn(posn string) (string, func(*token.File) (token.Pos, error), error) {
+	malformed := fmt.Errorf("invalid position %q; expected file.go:line:column or file.go:#offset", posn)
+
+	file, last, ok := cutLast(posn, ":")
+	if !ok {
+		return "", nil, malformed
+	}
+
+	if offset, ok := strings.CutPrefix(last, "#"); ok {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return "", nil, malformed
+		}
+
+		return file, func(tf *token.File) (token.Pos, error) {
+			if n > tf.Size() {
+				return token.NoPos, fmt.Errorf("offset %d is past the end of the file", n)
+			}
+			return tf.Pos(n), nil
+		}, nil
+	}
+
+	file, lineStr, ok := cutLast(file, ":")
+	if !ok {
+		return "", nil, malformed
+	}
+	line, err := strconv.Atoi(lineStr)
+	if err != nil || line < 1 {
+		return "", nil, malformed
+	}
+	col, err := strconv.Atoi(last)
+	if err != nil || col < 1 {
+		return "", nil, malformed
+	}
+
+	return file, func(tf *token.File) (token.Pos, error) {
+		if line > tf.LineCount() {
+			return token.NoPos, fmt.Errorf("line %d is past the end of the file", line)
+		}
+
+		start := tf.Offset(tf.LineStart(line))
+		end := tf.Size()
+		if line < tf.LineCount() {
+			end = tf.Offset(tf.LineStart(line+1)) - 1
+		}
+		if start+col-1 > end {
+			return token.NoPos, fmt.Errorf("column %d is past the end of line %d", col, line)
+		}
+
+		return tf.Pos(start + col - 1), nil
+	}, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i == -1 {
+		return "", "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+var errNoSymbol = errors.New("no function, method or type there")
+
+// objectAt returns the spec of the package-level symbol or method whose name is at pos in f.
+func objectAt(info *types.Info, f *ast.File, pos token.Pos, posn string) (replace.SymbolSpec, Kind, error) {
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+
+	var id *ast.Ident
+	switch n := path[0].(type) {
+	case *ast.Ident:
+		id = n
+	case *ast.SelectorExpr:
+		// Between the operand and the selected name, or on the dot.
+		id = n.Sel
+	}
+	if id == nil {
+		return replace.SymbolSpec{}, 0, fmt.Errorf("%s: %w", posn, errNoSymbol)
+	}
+
+	obj := info.ObjectOf(id)
+	switch obj := obj.(type) {
+	case *types.Func:
+		if obj.Pkg() == nil || (obj.Signature().Recv() == nil && obj.Parent() != obj.Pkg().Scope()) {
+			break
+		}
+		return replace.SymbolSpecOf(obj.Origin()), Func, nil
+	case *types.TypeName:
+		if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+			// Builtin types, type parameters and types declared inside functions can't be described
+			// by a spec.
+			break
+		}
+		return replace.SymbolSpecOf(obj), Type, nil
+	}
+
+	if obj == nil {
+		return replace.SymbolSpec{}, 0, fmt.Errorf("%s: %w", posn, errNoSymbol)
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		return replace.SymbolSpec{}, 0, fmt.Errorf("%s: %s is a package; point at the symbol selected from it instead", posn, id.Name)
+	}
+	return replace.SymbolSpec{}, 0, fmt.Errorf("%s: %s is not a package-level function, method or type", posn, id.Name)
+}
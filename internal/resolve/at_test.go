@@ -0,0 +1,137 @@
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAt(t *testing.T) {
+	tests := []struct {
+		// at is the text in use/use.go whose first byte is pointed at.
+		at     string
+		exp    string
+		kind   Kind
+		expErr string
+	}{{
+		at:  "Marshal(nil)",
+		exp: "test.com/resolve/a.Marshal",
+	}, {
+		at:  ".Marshal(nil)",
+		exp: "test.com/resolve/a.Marshal",
+	}, {
+		at:  "Close()",
+		exp: "test.com/resolve/a.Client.Close",
+	}, {
+		at:  `Get("k")`,
+		exp: "test.com/resolve/a.Map.Get",
+	}, {
+		at:  "Read()",
+		exp: "test.com/resolve/a.Reader.Read",
+	}, {
+		at:   "Client, m",
+		exp:  "test.com/resolve/a.Client",
+		kind: Type,
+	}, {
+		at:   "local struct",
+		exp:  "test.com/resolve/use.local",
+		kind: Type,
+	}, {
+		at:  "method()",
+		exp: "test.com/resolve/use.local.method",
+	}, {
+		at:  "run(",
+		exp: "test.com/resolve/use.run",
+	}, {
+		at:     "helper()",
+		expErr: "helper is not a package-level function, method or type",
+	}, {
+		at:     `len("x")`,
+		expErr: "len is not a package-level function, method or type",
+	}, {
+		at:     "string, int",
+		expErr: "string is not a package-level function, method or type",
+	}, {
+		at:     "a.Client, m",
+		expErr: "a is a package; point at the symbol selected from it instead",
+	}, {
+		at:     "package use",
+		expErr: "no function, method or type there",
+	}}
+
+	dir := testutil.Testdata(t)
+
+	b, err := os.ReadFile(filepath.Join(dir, "use", "use.go"))
+	require.NoError(t, err)
+	src := string(b)
+
+	for _, tc := range tests {
+		offset := strings.Index(src, tc.at)
+		require.NotEqual(t, -1, offset, tc.at)
+
+		line := strings.Count(src[:offset], "\n") + 1
+		col := offset - strings.LastIndex(src[:offset], "\n")
+
+		for _, posn := range []string{
+			"use/use.go:" + strconv.Itoa(line) + ":" + strconv.Itoa(col),
+			"use/use.go:#" + strconv.Itoa(offset),
+		} {
+			t.Run(posn, func(t *testing.T) {
+				spec, kind, err := At(dir, posn)
+				if tc.expErr != "" {
+					require.Error(t, err)
+					assert.Equal(t, posn+": "+tc.expErr, err.Error())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.exp, spec.String())
+				assert.Equal(t, tc.kind, kind)
+			})
+		}
+	}
+}
+
+func TestAt_Errors(t *testing.T) {
+	tests := []struct {
+		posn   string
+		expErr string
+	}{{
+		posn:   "use/use.go",
+		expErr: `invalid position "use/use.go"; expected file.go:line:column or file.go:#offset`,
+	}, {
+		posn:   "use/use.go:3",
+		expErr: `invalid position "use/use.go:3"; expected file.go:line:column or file.go:#offset`,
+	}, {
+		posn:   "use/use.go:0:1",
+		expErr: `invalid position "use/use.go:0:1"; expected file.go:line:column or file.go:#offset`,
+	}, {
+		posn:   "use/use.go:100:1",
+		expErr: "use/use.go:100:1: line 100 is past the end of the file",
+	}, {
+		posn:   "use/use.go:1:100",
+		expErr: "use/use.go:1:100: column 100 is past the end of line 1",
+	}, {
+		posn:   "use/use.go:#100000",
+		expErr: "use/use.go:#100000: offset 100000 is past the end of the file",
+	}, {
+		posn:   "use/missing.go:1:1",
+		expErr: "use/missing.go:1:1: no package contains the file",
+	}}
+
+	dir := testutil.Testdata(t)
+
+	for _, tc := range tests {
+		t.Run(tc.posn, func(t *testing.T) {
+			_, _, err := At(dir, tc.posn)
+			require.Error(t, err)
+			assert.Equal(t, tc.expErr, err.Error())
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package resolve
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"golang.org/x/tools/go/packages"
+)
+
+// Kind is the kind of symbol a spec has to describe.
+type Kind int
+
+const (
+	// Func is a function or a method.
+	Func Kind = iota
+	// Type is a package-level type.
+	Type
+)
+
+func (k Kind) String() string {
+	if k == Type {
+		return "type"
+	}
+	return "function"
+}
+
+// Check checks that spec describes a symbol of the given kind, loading its package from dir. A spec
+// that names a missing symbol would otherwise match nothing, and the run would quietly do nothing.
+// When the symbol doesn't exist, the error suggests the symbols with similar names. A pattern has to
+// match at least one function in its package, unless the package path is a pattern too.
+func Check(dir string, spec replace.SymbolSpec, kind Kind) error {
+	if spec.IsPattern() && kind != Func {
+		return fmt.Errorf("%s is a pattern, but a %s has to be named on its own", spec, kind)
+	}
+	if strings.ContainsAny(spec.Pkg, "*?") {
+		return nil
+	}
+
+	pkg, err := load(dir, spec.Pkg)
+	if err != nil {
+		return err
+	}
+
+	if pkg == nil {
+		msg := fmt.Sprintf("package %s not found", spec.Pkg)
+		if alt, ok := dottedPath(dir, spec, kind); ok {
+			msg += fmt.Sprintf("; did you mean %s? A package path whose last element contains a dot has to be quoted", alt)
+		}
+		return errors.New(msg)
+	}
+
+	if spec.IsPattern() {
+		return matchAny(pkg, spec)
+	}
+	return lookup(pkg, spec, kind)
+}
+
+// matchAny checks that the pattern spec matches a function or method in pkg.
+func matchAny(pkg *types.Package, spec replace.SymbolSpec) error {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			if spec.Matches(obj) {
+				return nil
+			}
+		case *types.TypeName:
+			named, ok := types.Unalias(obj.Type()).(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := range named.NumMethods() {
+				if spec.Matches(named.Method(i)) {
+					return nil
+				}
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				for i := range iface.NumMethods() {
+					if spec.Matches(iface.Method(i)) {
+						return nil
+					}
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("%s matches no function or method in package %s", spec, pkg.Path())
+}
+
+// load returns the package at path, or nil if there's no such package.
+func load(dir, path string) (*types.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName |
+			packages.NeedImports |
+			packages.NeedDeps |
+			packages.NeedSyntax |
+			packages.NeedTypes,
+		Dir: dir,
+	}, path)
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.Load, err)
+	}
+
+	if len(pkgs) != 1 || pkgs[0].Types == nil || pkgs[0].Name == "" {
+		return nil, nil
+	}
+
+	// Type errors in the package don't matter, since the type checker still declares what it can.
+	return pkgs[0].Types, nil
+}
+
+// dottedPath returns the spec that spec would be if the package path went on past the first dot
+// after its last slash, like "gopkg.in/yaml.v3".Marshal for gopkg.in/yaml.v3.Marshal, if that spec
+// describes a symbol.
+func dottedPath(dir string, spec replace.SymbolSpec, kind Kind) (replace.SymbolSpec, bool) {
+	if spec.Recv() == "" {
+		return replace.SymbolSpec{}, false
+	}
+
+	alt := replace.NewSymbolSpec(spec.Pkg+"."+spec.Recv(), "", spec.Name())
+	pkg, err := load(dir, alt.Pkg)
+	if err != nil || pkg == nil || lookup(pkg, alt, kind) != nil {
+		return replace.SymbolSpec{}, false
+	}
+
+	return alt, true
+}
+
+// lookup checks that spec describes a symbol of the given kind in pkg.
+func lookup(pkg *types.Package, spec replace.SymbolSpec, kind Kind) error {
+	scope := pkg.Scope()
+
+	if spec.Recv() == "" {
+		obj := scope.Lookup(spec.Name())
+		if obj == nil {
+			return notFound(pkg, spec, kind)
+		}
+
+		if describe(obj) != kind.String() {
+			return fmt.Errorf("%s is a %s, not a %s", spec, describe(obj), kind)
+		}
+		return nil
+	}
+
+	if kind == Type {
+		return fmt.Errorf("%s describes a method, not a type", spec)
+	}
+
+	tn, ok := scope.Lookup(spec.Recv()).(*types.TypeName)
+	if !ok {
+		msg := fmt.Sprintf("package %s has no type %s", pkg.Path(), spec.Recv())
+		return withSuggestions(msg, spec.Recv(), namesOf(pkg, Type), func(name string) string {
+			return replace.NewSymbolSpec(pkg.Path(), name, spec.Name()).String()
+		})
+	}
+
+	named, ok := types.Unalias(tn.Type()).(*types.Named)
+	if !ok {
+		return fmt.Errorf("%s.%s is not a defined type, so it has no methods", pkg.Path(), spec.Recv())
+	}
+
+	if params := spec.RecvTypeParams(); params != nil && len(params) != named.TypeParams().Len() {
+		return fmt.Errorf(
+			"%s.%s has %d type parameters but %s gives %d",
+			pkg.Path(), spec.Recv(), named.TypeParams().Len(), spec, len(params),
+		)
+	}
+
+	methods := methodNames(named)
+	if slices.Contains(methods, spec.Name()) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("type %s.%s has no method %s", pkg.Path(), spec.Recv(), spec.Name())
+	return withSuggestions(msg, spec.Name(), methods, func(name string) string {
+		return replace.NewSymbolSpec(pkg.Path(), spec.Recv(), name).String()
+	})
+}
+
+// notFound returns the error for a package-level symbol that doesn't exist. For a function, it may be
+// a method the spec is missing the receiver of.
+func notFound(pkg *types.Package, spec replace.SymbolSpec, kind Kind) error {
+	msg := fmt.Sprintf("package %s has no %s %s", pkg.Path(), kind, spec.Name())
+
+	if kind == Func {
+		var recvs []string
+		for _, name := range pkg.Scope().Names() {
+			tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if named, ok := types.Unalias(tn.Type()).(*types.Named); ok && slices.Contains(methodNames(named), spec.Name()) {
+				recvs = append(recvs, replace.NewSymbolSpec(pkg.Path(), name, spec.Name()).String())
+			}
+		}
+		if len(recvs) > 0 {
+			return fmt.Errorf("%s; did you mean %s?", msg, orList(recvs))
+		}
+	}
+
+	return withSuggestions(msg, spec.Name(), namesOf(pkg, kind), func(name string) string {
+		return replace.NewSymbolSpec(pkg.Path(), "", name).String()
+	})
+}
+
+// describe returns the kind of obj, as named in errors.
+func describe(obj types.Object) string {
+	switch obj.(type) {
+	case *types.Func:
+		return Func.String()
+	case *types.TypeName:
+		return Type.String()
+	case *types.Var:
+		return "variable"
+	case *types.Const:
+		return "constant"
+	default:
+		return "symbol"
+	}
+}
+
+// namesOf returns the names of the package-level symbols of the given kind in pkg.
+func namesOf(pkg *types.Package, kind Kind) []string {
+	var names []string
+	for _, name := range pkg.Scope().Names() {
+		if describe(pkg.Scope().Lookup(name)) == kind.String() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// methodNames returns the names of the methods declared on named, including those of an interface.
+func methodNames(named *types.Named) []string {
+	var names []string
+	for i := range named.NumMethods() {
+		names = append(names, named.Method(i).Name())
+	}
+	if iface, ok := named.Underlying().(*types.Interface); ok {
+		for i := range iface.NumMethods() {
+			names = append(names, iface.Method(i).Name())
+		}
+	}
+	return names
+}
+
+// maxSuggestions is the most close matches listed in an error.
+const maxSuggestions = 3
+
+// withSuggestions returns an error with msg, suggesting the candidates that are close to name. spec
+// formats a candidate as the spec to use instead.
+func withSuggestions(msg, name string, candidates []string, spec func(string) string) error {
+	type match struct {
+		name string
+		dist int
+	}
+
+	var matches []match
+	for _, c := range candidates {
+		d := distance(strings.ToLower(name), strings.ToLower(c))
+		if d <= max(1, len(name)/3) {
+			matches = append(matches, match{name: c, dist: d})
+		}
+	}
+	if len(matches) == 0 {
+		return errors.New(msg)
+	}
+
+	slices.SortFunc(matches, func(a, b match) int {
+		return cmp.Or(cmp.Compare(a.dist, b.dist), strings.Compare(a.name, b.name))
+	})
+
+	specs := make([]string, 0, maxSuggestions)
+	for _, m := range matches[:min(len(matches), maxSuggestions)] {
+		specs = append(specs, spec(m.name))
+	}
+	return fmt.Errorf("%s; did you mean %s?", msg, orList(specs))
+}
+
+// orList joins items like "a, b or c".
+func orList(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+// distance returns the edit distance between a and b, counting the transposition of two adjacent
+// characters as one edit, since that's a common typo.
+func distance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+
+	prev2 := make([]int, len(br)+1)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := range ar {
+		curr[0] = i + 1
+		for j := range br {
+			cost := 1
+			if ar[i] == br[j] {
+				cost = 0
+			}
+			curr[j+1] = min(prev[j+1]+1, curr[j]+1, prev[j]+cost)
+			if i > 0 && j > 0 && ar[i] == br[j-1] && ar[i-1] == br[j] {
+				curr[j+1] = min(curr[j+1], prev2[j-1]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(br)]
+}
@@ -0,0 +1,112 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		spec   string
+		kind   Kind
+		expErr string
+	}{{
+		spec: "test.com/resolve/a.Marshal",
+	}, {
+		spec: "test.com/resolve/a.(*Client).Close",
+	}, {
+		spec: "test.com/resolve/a.Client.Close",
+	}, {
+		spec: "test.com/resolve/a.Client.Get",
+	}, {
+		spec: "test.com/resolve/a.Map[K,V].Get",
+	}, {
+		spec: "test.com/resolve/a.Map.Get",
+	}, {
+		spec: "test.com/resolve/a.Reader.Read",
+	}, {
+		spec: "test.com/resolve/a.unexported.run",
+	}, {
+		spec: "test.com/resolve/a.Client",
+		kind: Type,
+	}, {
+		spec: `"test.com/resolve/yaml.v3".Marshal`,
+	}, {
+		spec:   "test.com/resolve/a.Marshall",
+		expErr: "package test.com/resolve/a has no function Marshall; did you mean test.com/resolve/a.Marshal?",
+	}, {
+		spec:   "test.com/resolve/a.Close",
+		expErr: "package test.com/resolve/a has no function Close; did you mean test.com/resolve/a.Client.Close?",
+	}, {
+		spec:   "test.com/resolve/a.Client.Clos",
+		expErr: "type test.com/resolve/a.Client has no method Clos; did you mean test.com/resolve/a.Client.Close?",
+	}, {
+		spec:   "test.com/resolve/a.Client.Gte",
+		expErr: "type test.com/resolve/a.Client has no method Gte; did you mean test.com/resolve/a.Client.Get?",
+	}, {
+		spec:   "test.com/resolve/a.Clinet.Get",
+		expErr: "package test.com/resolve/a has no type Clinet; did you mean test.com/resolve/a.Client.Get?",
+	}, {
+		spec:   "test.com/resolve/a.Nothing",
+		expErr: "package test.com/resolve/a has no function Nothing",
+	}, {
+		spec:   "test.com/resolve/a.Default",
+		expErr: "test.com/resolve/a.Default is a variable, not a function",
+	}, {
+		spec:   "test.com/resolve/a.Marshal",
+		kind:   Type,
+		expErr: "test.com/resolve/a.Marshal is a function, not a type",
+	}, {
+		spec:   "test.com/resolve/a.Client.Get",
+		kind:   Type,
+		expErr: "test.com/resolve/a.Client.Get describes a method, not a type",
+	}, {
+		spec:   "test.com/resolve/a.Map[K].Get",
+		expErr: "test.com/resolve/a.Map has 2 type parameters but test.com/resolve/a.Map[K].Get gives 1",
+	}, {
+		spec:   "test.com/resolve/yaml.v3.Marshal",
+		expErr: `package test.com/resolve/yaml not found; did you mean "test.com/resolve/yaml.v3".Marshal? A package path whose last element contains a dot has to be quoted`,
+	}, {
+		spec:   "test.com/resolve/missing.F",
+		expErr: "package test.com/resolve/missing not found",
+	}, {
+		spec: "test.com/resolve/a.*shal",
+	}, {
+		spec: "test.com/resolve/a.Client.*",
+	}, {
+		spec: "test.com/resolve/a.*.Read",
+	}, {
+		spec: "test.com/resolve/*.Marshal",
+	}, {
+		spec:   "test.com/resolve/a.*f",
+		expErr: "test.com/resolve/a.*f matches no function or method in package test.com/resolve/a",
+	}, {
+		spec:   "test.com/resolve/a.Default*",
+		expErr: "test.com/resolve/a.Default* matches no function or method in package test.com/resolve/a",
+	}, {
+		spec:   "test.com/resolve/a.Cli*",
+		kind:   Type,
+		expErr: "test.com/resolve/a.Cli* is a pattern, but a type has to be named on its own",
+	}}
+
+	dir := testutil.Testdata(t)
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			spec, err := replace.ParseSymbolSpec(tc.spec)
+			require.NoError(t, err)
+
+			err = Check(dir, spec, tc.kind)
+			if tc.expErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.expErr, err.Error())
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package a
+
+var Default Client
+
+func Marshal(v any) ([]byte, error) {
+	return nil, nil
+}
+
+func Unmarshal(b []byte, v any) error {
+	return nil
+}
+
+type Client struct{}
+
+func (c *Client) Close() error {
+	return nil
+}
+
+func (Client) Get(key string) string {
+	return key
+}
+
+type Map[K comparable, V any] struct{}
+
+func (m Map[K, V]) Get(k K) V {
+	var v V
+	return v
+}
+
+type Reader interface {
+	Read() ([]byte, error)
+}
+
+type unexported struct{}
+
+func (unexported) run() {}
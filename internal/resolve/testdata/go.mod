@@ -0,0 +1,3 @@
+module test.com/resolve
+
+go 1.23.2
@@ -0,0 +1,22 @@
+package use
+
+import (
+	"test.com/resolve/a"
+)
+
+type local struct{}
+
+func run(c *a.Client, m a.Map[string, int]) {
+	_, _ = a.Marshal(nil)
+	_ = c.Close()
+	_ = m.Get("k")
+
+	var r a.Reader
+	_, _ = r.Read()
+
+	helper := func() {}
+	helper()
+	_ = len("x")
+}
+
+func (local) method() {}
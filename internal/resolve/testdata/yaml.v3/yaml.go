@@ -0,0 +1,5 @@
+package yaml
+
+func Marshal(v any) ([]byte, error) {
+	return nil, nil
+}
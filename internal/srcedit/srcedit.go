@@ -0,0 +1,262 @@
+// Package srcedit edits the source of whole declarations, like moving or deleting a type along with
+// its methods, which is out of the reach of analyzers' suggested fixes.
+package srcedit
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/typecheck"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// File is a source file being edited.
+type File struct {
+	fset *token.FileSet
+	path string
+	src  []byte
+}
+
+// Open reads the source of each of the package's files, in the same order as its syntax.
+func Open(pkg *packages.Package) ([]*File, error) {
+	files := make([]*File, 0, len(pkg.Syntax))
+	for _, f := range pkg.Syntax {
+		path := pkg.Fset.File(f.Pos()).Name()
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, &File{fset: pkg.Fset, path: path, src: src})
+	}
+	return files, nil
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Offset returns the offset of pos in the file.
+func (f *File) Offset(pos token.Pos) int {
+	return f.fset.Position(pos).Offset
+}
+
+// Text returns the source between start and end.
+func (f *File) Text(start, end token.Pos) []byte {
+	return f.src[f.Offset(start):f.Offset(end)]
+}
+
+// Replacement replaces the source between start and end with text.
+func (f *File) Replacement(start, end token.Pos, text string) Edit {
+	return Edit{start: f.Offset(start), end: f.Offset(end), text: text}
+}
+
+// Deletion deletes the source between start and end, along with the rest of the line end is on.
+func (f *File) Deletion(start, end token.Pos) Edit {
+	e := Edit{start: f.Offset(start), end: f.Offset(end)}
+	for e.end < len(f.src) && f.src[e.end] != '\n' {
+		e.end++
+	}
+	if e.end < len(f.src) {
+		e.end++
+	}
+	return e
+}
+
+// Edit is a change to the source of a file.
+type Edit struct {
+	start, end int
+	text       string
+}
+
+// Apply applies the edits to each file, removes the imports that are no longer used and adds an import
+// of addImport (if it's set). It returns edits that replace each file with the result, so that they can
+// be written by a typecheck.Session.
+func Apply(edits map[*File][]Edit, addImport string) ([]typecheck.Edit, error) {
+	var out []typecheck.Edit
+	for f, es := range edits {
+		slices.SortFunc(es, func(a, b Edit) int {
+			return b.start - a.start
+		})
+
+		src := slices.Clone(f.src)
+		for _, e := range es {
+			src = slices.Concat(src[:e.start], []byte(e.text), src[e.end:])
+		}
+
+		fset := token.NewFileSet()
+		parsed, err := parser.ParseFile(fset, f.path, src, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s after editing it: %w", f.path, err)
+		}
+
+		for _, imp := range slices.Clone(parsed.Imports) {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			if imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".") {
+				continue
+			}
+			if !astutil.UsesImport(parsed, path) {
+				name := ""
+				if imp.Name != nil {
+					name = imp.Name.Name
+				}
+				astutil.DeleteNamedImport(fset, parsed, name, path)
+			}
+		}
+
+		if addImport != "" {
+			astutil.AddImport(fset, parsed, addImport)
+		}
+
+		formatted := &bytes.Buffer{}
+		err = format.Node(formatted, fset, parsed)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, typecheck.Edit{
+			Filename: f.path,
+			Start:    0,
+			End:      len(f.src),
+			New:      formatted.String(),
+		})
+	}
+
+	slices.SortFunc(out, func(a, b typecheck.Edit) int {
+		return strings.Compare(a.Filename, b.Filename)
+	})
+	return out, nil
+}
+
+// TypeDecl is the declaration of a type.
+type TypeDecl struct {
+	File *File
+	Decl *ast.GenDecl
+	Spec *ast.TypeSpec
+	Obj  *types.TypeName
+}
+
+// Doc returns the doc comment of the type, which belongs to the declaration if it's the only spec in
+// it.
+func (d TypeDecl) Doc() *ast.CommentGroup {
+	if d.Spec.Doc != nil {
+		return d.Spec.Doc
+	}
+	if len(d.Decl.Specs) == 1 {
+		return d.Decl.Doc
+	}
+	return nil
+}
+
+// Deletion deletes the type's spec, or the whole declaration if the spec is the only one in it.
+func (d TypeDecl) Deletion() Edit {
+	if len(d.Decl.Specs) == 1 {
+		return d.File.Deletion(docStart(d.Decl.Doc, d.Decl.Pos()), d.Decl.End())
+	}
+	return d.File.Deletion(docStart(d.Spec.Doc, d.Spec.Pos()), d.Spec.End())
+}
+
+// FindType finds the declaration of the type called name in pkg, whose files are files.
+func FindType(pkg *packages.Package, files []*File, name string) (TypeDecl, error) {
+	for i, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				if spec.Name.Name != name {
+					continue
+				}
+
+				return TypeDecl{
+					File: files[i],
+					Decl: gen,
+					Spec: spec,
+					Obj:  pkg.TypesInfo.Defs[spec.Name].(*types.TypeName),
+				}, nil
+			}
+		}
+	}
+
+	return TypeDecl{}, fmt.Errorf("type %s not found in %s", name, pkg.PkgPath)
+}
+
+// FuncDecl is the declaration of a function or method.
+type FuncDecl struct {
+	File *File
+	Decl *ast.FuncDecl
+}
+
+// Start returns the start of the declaration, including its doc comment.
+func (d FuncDecl) Start() token.Pos {
+	return docStart(d.Decl.Doc, d.Decl.Pos())
+}
+
+// Deletion deletes the declaration and its doc comment.
+func (d FuncDecl) Deletion() Edit {
+	return d.File.Deletion(d.Start(), d.Decl.End())
+}
+
+// FindFunc finds the declaration of fn in pkg, whose files are files.
+func FindFunc(pkg *packages.Package, files []*File, fn *types.Func) (FuncDecl, error) {
+	for i, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if ok && pkg.TypesInfo.Defs[decl.Name] == fn {
+				return FuncDecl{File: files[i], Decl: decl}, nil
+			}
+		}
+	}
+
+	return FuncDecl{}, fmt.Errorf("function %s not found in %s", fn.Name(), pkg.PkgPath)
+}
+
+// FindMethods finds the declarations of the methods of tn in pkg, whose files are files.
+func FindMethods(pkg *packages.Package, files []*File, tn *types.TypeName) []FuncDecl {
+	var methods []FuncDecl
+	for i, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil {
+				continue
+			}
+
+			obj, ok := pkg.TypesInfo.Defs[fn.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+
+			recv := obj.Signature().Recv().Type()
+			if ptr, ok := recv.(*types.Pointer); ok {
+				recv = ptr.Elem()
+			}
+			if named, ok := recv.(*types.Named); !ok || named.Obj() != tn {
+				continue
+			}
+
+			methods = append(methods, FuncDecl{File: files[i], Decl: fn})
+		}
+	}
+	return methods
+}
+
+func docStart(doc *ast.CommentGroup, pos token.Pos) token.Pos {
+	if doc != nil {
+		return doc.Pos()
+	}
+	return pos
+}
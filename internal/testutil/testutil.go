@@ -0,0 +1,56 @@
+// Package testutil has helpers for tests that load the module in a package's testdata directory.
+package testutil
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Testdata returns the absolute path of the testdata directory of the package under test.
+func Testdata(t *testing.T) string {
+	t.Helper()
+
+	dir, err := filepath.Abs("testdata")
+	require.NoError(t, err)
+	return dir
+}
+
+// CopyTestdata copies testdata into a temporary directory, for tests of functions that write files.
+func CopyTestdata(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.CopyFS(dir, os.DirFS("testdata")))
+	return dir
+}
+
+// ReadFile returns the contents of the file at path in dir.
+func ReadFile(t *testing.T, dir, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(dir, path))
+	require.NoError(t, err)
+	return string(b)
+}
+
+// Strings formats each of items with the file of its position made relative to dir, so that
+// expectations don't depend on where the testdata is.
+func Strings[T fmt.Stringer](t *testing.T, dir string, items []T, pos func(*T) *token.Position) []string {
+	t.Helper()
+
+	got := make([]string, 0, len(items))
+	for _, item := range items {
+		p := pos(&item)
+		rel, err := filepath.Rel(dir, p.Filename)
+		require.NoError(t, err)
+
+		p.Filename = rel
+		got = append(got, item.String())
+	}
+	return got
+}
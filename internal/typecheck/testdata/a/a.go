@@ -0,0 +1,13 @@
+package a
+
+func Old(n int) int {
+	return n
+}
+
+func New(n int) string {
+	return ""
+}
+
+func Use() int {
+	return Old(1)
+}
@@ -0,0 +1,7 @@
+package b
+
+import "test.com/typecheck/a"
+
+func Use() {
+	_ = a.Old(2)
+}
@@ -0,0 +1,7 @@
+package broken
+
+import "test.com/typecheck/a"
+
+func Use() int {
+	return a.Old(3) + missing
+}
@@ -0,0 +1,3 @@
+module test.com/typecheck
+
+go 1.23.2
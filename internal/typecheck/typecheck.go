@@ -0,0 +1,551 @@
+// Package typecheck applies the fixes suggested by an analyzer in memory, type-checks the packages
+// they touch with the result and only then writes it, so that a fix that doesn't compile never reaches
+// the disk.
+package typecheck
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cszczepaniak/go-refactor/internal/analyzeutil"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"golang.org/x/tools/go/packages"
+)
+
+// Edit replaces the bytes between Start and End of a file with New. An edit to a file that doesn't
+// exist yet creates it, as if it were empty.
+type Edit struct {
+	Filename   string
+	Start, End int
+	New        string
+}
+
+// Change is the fix for one diagnostic, made by the rule called Rule. Its edits are applied together.
+type Change struct {
+	Rule    string
+	Posn    string
+	Message string
+	Edits   []Edit
+}
+
+// Error is a type error in a package after the changes.
+type Error struct {
+	Posn string
+	Msg  string
+
+	// Cause is the change that wrote the line the error is on, if any. Rules are the rules that
+	// changed the package.
+	Cause *Change
+	Rules []string
+}
+
+func (e Error) String() string {
+	s := e.Posn + ": " + e.Msg
+	switch {
+	case e.Cause != nil:
+		s += "\n\tafter " + e.Cause.Rule + " at " + e.Cause.Posn + ": " + firstLine(e.Cause.Message)
+	case len(e.Rules) > 0:
+		s += "\n\tafter " + strings.Join(e.Rules, ", ")
+	}
+	return s
+}
+
+func firstLine(s string) string {
+	before, _, _ := strings.Cut(s, "\n")
+	return before
+}
+
+// Failure is a package that no longer type-checks after the changes.
+type Failure struct {
+	Pkg    string
+	Errors []Error
+
+	// RolledBack is set when the changes to the package's files were left out so that the rest
+	// could be written.
+	RolledBack bool
+}
+
+// Mode is what to do about packages that fail to type-check.
+type Mode int
+
+const (
+	// Abort writes nothing if any package fails to type-check.
+	Abort Mode = iota
+
+	// Rollback leaves out the changes to the files of packages that fail to type-check, and writes
+	// the rest.
+	Rollback
+
+	// Ignore writes the changes whether or not they type-check. Failures are still reported.
+	Ignore
+)
+
+// ParseMode parses the name of a Mode.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "abort":
+		return Abort, nil
+	case "rollback":
+		return Rollback, nil
+	case "ignore":
+		return Ignore, nil
+	default:
+		return 0, fmt.Errorf("unknown mode %q; expected one of abort, rollback or ignore", s)
+	}
+}
+
+// ErrAborted is returned when nothing was written because a package failed to type-check.
+var ErrAborted = exitcode.Wrap(exitcode.TypeCheck, errors.New("the changes don't type-check, so nothing was written"))
+
+// Session writes the changes made over the course of one run. Packages that already failed to
+// type-check before the first change aren't checked.
+type Session struct {
+	dir      string
+	patterns []string
+	mode     Mode
+
+	// clean holds the IDs of the packages that type-checked before the first change, or is nil until
+	// then.
+	clean map[string]bool
+
+	// original holds the contents of the files written by unchecked changes, from before they were
+	// written, or nil for the files they created. rules holds the rules that made those changes.
+	original map[string][]byte
+	rules    []string
+}
+
+// NewSession starts a session over the packages matching patterns (relative to dir).
+func NewSession(dir string, patterns []string, mode Mode) *Session {
+	return &Session{
+		dir:      dir,
+		patterns: patterns,
+		mode:     mode,
+		original: make(map[string][]byte),
+	}
+}
+
+// WriteUnchecked writes changes without type-checking them, for when the next rule needs them on disk
+// to do its part. They're checked along with the changes passed to the next call to Write, and rolled
+// back with them.
+func (s *Session) WriteUnchecked(changes []Change) error {
+	err := s.baseline()
+	if err != nil {
+		return err
+	}
+
+	files, err := s.apply(changes)
+	if err != nil {
+		return err
+	}
+
+	for path, f := range files {
+		if _, ok := s.original[path]; !ok {
+			s.original[path] = f.before
+		}
+
+		err := writeFile(path, f.format())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range changes {
+		if !slices.Contains(s.rules, c.Rule) {
+			s.rules = append(s.rules, c.Rule)
+		}
+	}
+
+	return nil
+}
+
+// Write type-checks the packages with changes applied (along with any unchecked changes written
+// before) and writes them, depending on the session's mode. It returns the packages that failed to
+// type-check, and ErrAborted if nothing was written because of them.
+func (s *Session) Write(changes []Change) ([]Failure, error) {
+	if len(changes) == 0 && len(s.original) == 0 {
+		return nil, nil
+	}
+
+	err := s.baseline()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.apply(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files whose changes are being left out.
+	excluded := make(map[string]bool)
+
+	var failures []Failure
+	for {
+		overlay := make(map[string][]byte)
+		for path, f := range files {
+			if !excluded[path] {
+				overlay[path] = f.after
+			}
+		}
+		for path, src := range s.original {
+			if excluded[path] && src != nil {
+				overlay[path] = src
+			}
+		}
+
+		pkgs, err := s.load(overlay)
+		if err != nil {
+			return nil, err
+		}
+
+		failed := s.failures(pkgs, files, changes)
+		if len(failed) == 0 || s.mode == Ignore {
+			failures = append(failures, failed...)
+			break
+		}
+		if s.mode == Abort {
+			return append(failures, failed...), s.abort()
+		}
+
+		rolledBack := false
+		for i, f := range failed {
+			for _, path := range filesOf(pkgs, f.Pkg) {
+				_, changed := files[path]
+				_, written := s.original[path]
+				if (changed || written) && !excluded[path] {
+					excluded[path] = true
+					failed[i].RolledBack = true
+					rolledBack = true
+				}
+			}
+		}
+		excludeTogether(changes, excluded)
+		failures = append(failures, failed...)
+		if !rolledBack {
+			// The errors come from changes to other packages, which can't be told apart.
+			return failures, s.abort()
+		}
+	}
+
+	for path, f := range files {
+		if excluded[path] {
+			continue
+		}
+		err := writeFile(path, f.format())
+		if err != nil {
+			return nil, err
+		}
+	}
+	for path, src := range s.original {
+		if excluded[path] {
+			err := restoreFile(path, src)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	s.original = make(map[string][]byte)
+	s.rules = nil
+	return failures, nil
+}
+
+// excludeTogether adds the files of the changes that edit an excluded file to excluded, since the
+// edits of a change are applied together or not at all.
+func excludeTogether(changes []Change, excluded map[string]bool) {
+	for grew := true; grew; {
+		grew = false
+		for _, c := range changes {
+			if !slices.ContainsFunc(c.Edits, func(e Edit) bool { return excluded[e.Filename] }) {
+				continue
+			}
+			for _, e := range c.Edits {
+				if !excluded[e.Filename] {
+					excluded[e.Filename] = true
+					grew = true
+				}
+			}
+		}
+	}
+}
+
+// abort restores the files written by unchecked changes.
+func (s *Session) abort() error {
+	err := s.Discard()
+	if err != nil {
+		return err
+	}
+	return ErrAborted
+}
+
+// Discard restores the files written by unchecked changes, for when the rule that was going to
+// complete them failed.
+func (s *Session) Discard() error {
+	for path, src := range s.original {
+		err := restoreFile(path, src)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.original = make(map[string][]byte)
+	s.rules = nil
+	return nil
+}
+
+// baseline records which packages type-check before anything is written.
+func (s *Session) baseline() error {
+	if s.clean != nil {
+		return nil
+	}
+
+	pkgs, err := s.load(nil)
+	if err != nil {
+		return err
+	}
+
+	s.clean = make(map[string]bool)
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) == 0 {
+			s.clean[pkg.ID] = true
+		}
+	}
+
+	return nil
+}
+
+func (s *Session) load(overlay map[string][]byte) ([]*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:    analyzeutil.LoadMode | packages.NeedCompiledGoFiles,
+		Dir:     s.dir,
+		Tests:   true,
+		Overlay: overlay,
+	}, s.patterns...)
+	return pkgs, exitcode.Wrap(exitcode.Load, err)
+}
+
+// failures returns the packages that type-checked before but don't any more.
+func (s *Session) failures(pkgs []*packages.Package, files map[string]*file, changes []Change) []Failure {
+	seen := make(analyzeutil.Seen[string])
+
+	var failures []Failure
+	for _, pkg := range pkgs {
+		if !s.clean[pkg.ID] || len(pkg.Errors) == 0 {
+			continue
+		}
+
+		rules := s.rulesFor(pkg, files, changes)
+
+		f := Failure{Pkg: pkg.ID}
+		for _, e := range pkg.Errors {
+			if !seen.First(e.Pos + e.Msg) {
+				continue
+			}
+
+			err := Error{Posn: e.Pos, Msg: e.Msg}
+
+			path, line, ok := parsePosn(e.Pos)
+			if ok {
+				err.Cause = files[path].causeAt(line)
+			}
+			if err.Cause == nil {
+				err.Rules = rules
+			}
+
+			f.Errors = append(f.Errors, err)
+		}
+		if len(f.Errors) > 0 {
+			failures = append(failures, f)
+		}
+	}
+
+	return failures
+}
+
+// rulesFor returns the rules that changed the files of pkg, or every rule in play if none of them did.
+func (s *Session) rulesFor(pkg *packages.Package, files map[string]*file, changes []Change) []string {
+	var rules []string
+	add := func(rule string) {
+		if !slices.Contains(rules, rule) {
+			rules = append(rules, rule)
+		}
+	}
+
+	for _, path := range pkg.CompiledGoFiles {
+		if f, ok := files[path]; ok {
+			for _, p := range f.placed {
+				add(p.change.Rule)
+			}
+		}
+	}
+	if len(rules) > 0 {
+		return rules
+	}
+
+	for _, r := range s.rules {
+		add(r)
+	}
+	for _, c := range changes {
+		add(c.Rule)
+	}
+	return rules
+}
+
+func filesOf(pkgs []*packages.Package, id string) []string {
+	for _, pkg := range pkgs {
+		if pkg.ID == id {
+			return pkg.CompiledGoFiles
+		}
+	}
+	return nil
+}
+
+// parsePosn splits a position like "file.go:12:3" into the file and the line.
+func parsePosn(posn string) (string, int, bool) {
+	parts := strings.Split(posn, ":")
+	if len(parts) < 3 {
+		return "", 0, false
+	}
+
+	line, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return strings.Join(parts[:len(parts)-2], ":"), line, true
+}
+
+// file is a file with changes applied in memory.
+type file struct {
+	before, after []byte
+	placed        []placed
+}
+
+// placed is where the text written by one of a change's edits ended up, by line.
+type placed struct {
+	change             *Change
+	startLine, endLine int
+}
+
+func (f *file) causeAt(line int) *Change {
+	if f == nil {
+		return nil
+	}
+
+	for _, p := range f.placed {
+		if p.startLine <= line && line <= p.endLine {
+			return p.change
+		}
+	}
+	return nil
+}
+
+// format formats the file like the fixes of an analyzer are, unless it doesn't parse.
+func (f *file) format() []byte {
+	formatted, err := format.Source(f.after)
+	if err != nil {
+		return f.after
+	}
+	return formatted
+}
+
+type placedEdit struct {
+	Edit
+	change *Change
+}
+
+// apply applies changes to the files on disk. Edits that are made more than once, like for a file that
+// belongs to a package and its test variant, are only applied once, and edits that overlap are an
+// error.
+func (s *Session) apply(changes []Change) (map[string]*file, error) {
+	byFile := make(map[string][]placedEdit)
+	for i := range changes {
+		for _, e := range changes[i].Edits {
+			byFile[e.Filename] = append(byFile[e.Filename], placedEdit{Edit: e, change: &changes[i]})
+		}
+	}
+
+	files := make(map[string]*file, len(byFile))
+	for path, edits := range byFile {
+		src, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The file is created by the changes. Keep src nil so that it's deleted again if they're
+			// undone.
+			src, err = nil, nil
+		}
+		if err != nil {
+			return nil, exitcode.Wrap(exitcode.Write, err)
+		}
+
+		slices.SortStableFunc(edits, func(a, b placedEdit) int {
+			if a.Start != b.Start {
+				return a.Start - b.Start
+			}
+			return a.End - b.End
+		})
+		edits = slices.CompactFunc(edits, func(a, b placedEdit) bool {
+			return a.Edit == b.Edit
+		})
+
+		f := &file{before: src}
+		out := &bytes.Buffer{}
+		last := 0
+		for i, e := range edits {
+			if e.Start > e.End || e.End > len(src) {
+				return nil, exitcode.Wrap(exitcode.Write, fmt.Errorf("invalid edit to %s by %s at %s", path, e.change.Rule, e.change.Posn))
+			}
+			if e.Start < last {
+				prev := edits[i-1].change
+				return nil, exitcode.Wrap(exitcode.Write, fmt.Errorf(
+					"conflicting edits to %s: %q at %s and %q at %s",
+					path, firstLine(prev.Message), prev.Posn, firstLine(e.change.Message), e.change.Posn,
+				))
+			}
+
+			out.Write(src[last:e.Start])
+			start := out.Len()
+			out.WriteString(e.New)
+			f.placed = append(f.placed, placed{
+				change:    e.change,
+				startLine: lineAt(out.Bytes(), start),
+				endLine:   lineAt(out.Bytes(), out.Len()),
+			})
+			last = e.End
+		}
+		out.Write(src[last:])
+
+		f.after = out.Bytes()
+		files[path] = f
+	}
+
+	return files, nil
+}
+
+// lineAt returns the 1-based line of offset in src.
+func lineAt(src []byte, offset int) int {
+	return bytes.Count(src[:offset], []byte{'\n'}) + 1
+}
+
+// restoreFile writes src, the original contents of the file at path, back to it, or deletes the file
+// if src is nil because it didn't exist.
+func restoreFile(path string, src []byte) error {
+	if src == nil {
+		return exitcode.Wrap(exitcode.Write, os.Remove(path))
+	}
+	return writeFile(path, src)
+}
+
+func writeFile(path string, src []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	return exitcode.Wrap(exitcode.Write, os.WriteFile(path, src, mode))
+}
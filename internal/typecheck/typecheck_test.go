@@ -0,0 +1,188 @@
+package typecheck
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	s := NewSession(dir, []string{"./..."}, Abort)
+	failures, err := s.Write([]Change{
+		change(t, dir, "b/b.go", "a.Old(2)", "a.New(2)"),
+		// Packages that didn't type-check to begin with aren't checked.
+		change(t, dir, "broken/broken.go", "a.Old(3)", "a.New(3)"),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+
+	assert.Contains(t, testutil.ReadFile(t, dir, "b/b.go"), "_ = a.New(2)")
+	assert.Contains(t, testutil.ReadFile(t, dir, "broken/broken.go"), "return a.New(3) + missing")
+}
+
+func TestWrite_Abort(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	s := NewSession(dir, []string{"./..."}, Abort)
+	failures, err := s.Write([]Change{
+		change(t, dir, "a/a.go", "Old(1)", "New(1)"),
+		change(t, dir, "b/b.go", "a.Old(2)", "a.New(2)"),
+	})
+	require.ErrorIs(t, err, ErrAborted)
+
+	require.Len(t, failures, 1)
+	assert.Equal(t, "test.com/typecheck/a", failures[0].Pkg)
+	assert.False(t, failures[0].RolledBack)
+	require.Len(t, failures[0].Errors, 1)
+
+	e := failures[0].Errors[0]
+	assert.Equal(t, filepath.Join(dir, "a/a.go")+":12:9", e.Posn)
+	assert.Contains(t, e.Msg, "cannot use New(1)")
+	require.NotNil(t, e.Cause)
+	assert.Equal(t, "Old(1) => New(1)", e.Cause.Message)
+	assert.Contains(t, e.String(), "\n\tafter replacecall at "+filepath.Join(dir, "a/a.go")+":12:9: Old(1) => New(1)")
+
+	assert.Contains(t, testutil.ReadFile(t, dir, "a/a.go"), "return Old(1)")
+	assert.Contains(t, testutil.ReadFile(t, dir, "b/b.go"), "_ = a.Old(2)")
+}
+
+func TestWrite_Rollback(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	s := NewSession(dir, []string{"./..."}, Rollback)
+	failures, err := s.Write([]Change{
+		change(t, dir, "a/a.go", "Old(1)", "New(1)"),
+		change(t, dir, "b/b.go", "a.Old(2)", "a.New(2)"),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, failures, 1)
+	assert.Equal(t, "test.com/typecheck/a", failures[0].Pkg)
+	assert.True(t, failures[0].RolledBack)
+
+	assert.Contains(t, testutil.ReadFile(t, dir, "a/a.go"), "return Old(1)")
+	assert.Contains(t, testutil.ReadFile(t, dir, "b/b.go"), "_ = a.New(2)")
+}
+
+func TestWrite_RollbackWholeChange(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	// One change that breaks a along with a harmless edit to b.
+	c := change(t, dir, "a/a.go", "Old(1)", "New(1)")
+	c.Edits = append(c.Edits, change(t, dir, "b/b.go", "a.Old(2)", "a.Old(3)").Edits...)
+
+	s := NewSession(dir, []string{"./..."}, Rollback)
+	failures, err := s.Write([]Change{c})
+	require.NoError(t, err)
+
+	require.Len(t, failures, 1)
+	assert.True(t, failures[0].RolledBack)
+
+	// The edit to b is left out with the rest of its change.
+	assert.Contains(t, testutil.ReadFile(t, dir, "a/a.go"), "return Old(1)")
+	assert.Contains(t, testutil.ReadFile(t, dir, "b/b.go"), "_ = a.Old(2)")
+}
+
+func TestWrite_NewFile(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	c := change(t, dir, "b/b.go", "a.Old(2)", "a.Newer(2)")
+	c.Edits = append(c.Edits, Edit{
+		Filename: filepath.Join(dir, "a/newer.go"),
+		New:      "package a\n\nfunc Newer(n int) int { return n }\n",
+	})
+
+	s := NewSession(dir, []string{"./..."}, Abort)
+	failures, err := s.Write([]Change{c})
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+
+	assert.Contains(t, testutil.ReadFile(t, dir, "a/newer.go"), "func Newer(n int) int")
+	assert.Contains(t, testutil.ReadFile(t, dir, "b/b.go"), "_ = a.Newer(2)")
+}
+
+func TestWrite_NewFileUnchecked(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+	path := filepath.Join(dir, "a/newer.go")
+
+	s := NewSession(dir, []string{"./..."}, Abort)
+	err := s.WriteUnchecked([]Change{{
+		Rule:  "movetype",
+		Edits: []Edit{{Filename: path, New: "package a\n\nfunc Newer() {}\n"}},
+	}})
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+
+	// Files created by undone changes are deleted again.
+	require.NoError(t, s.Discard())
+	assert.NoFileExists(t, path)
+}
+
+func TestWrite_Unchecked(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	s := NewSession(dir, []string{"./..."}, Abort)
+	err := s.WriteUnchecked([]Change{
+		change(t, dir, "a/a.go", "Old(1)", "New(1)"),
+	})
+	require.NoError(t, err)
+	assert.Contains(t, testutil.ReadFile(t, dir, "a/a.go"), "return New(1)")
+
+	// The next rule doesn't fix what the first one broke, so both are undone.
+	failures, err := s.Write([]Change{
+		change(t, dir, "b/b.go", "a.Old(2)", "a.New(2)"),
+	})
+	require.ErrorIs(t, err, ErrAborted)
+
+	require.Len(t, failures, 1)
+	require.Len(t, failures[0].Errors, 1)
+	assert.Nil(t, failures[0].Errors[0].Cause)
+	assert.Equal(t, []string{"replacecall"}, failures[0].Errors[0].Rules)
+
+	assert.Contains(t, testutil.ReadFile(t, dir, "a/a.go"), "return Old(1)")
+	assert.Contains(t, testutil.ReadFile(t, dir, "b/b.go"), "_ = a.Old(2)")
+}
+
+func TestWrite_Conflict(t *testing.T) {
+	dir := testutil.CopyTestdata(t)
+
+	s := NewSession(dir, []string{"./..."}, Abort)
+	_, err := s.Write([]Change{
+		change(t, dir, "b/b.go", "a.Old(2)", "a.New(2)"),
+		change(t, dir, "b/b.go", "Old", "Older"),
+	})
+	require.ErrorContains(t, err, "conflicting edits")
+}
+
+// change returns a change that replaces the first occurrence of old in the file at path with new.
+func change(t *testing.T, dir, path, old, new string) Change {
+	t.Helper()
+
+	src := testutil.ReadFile(t, dir, path)
+	path = filepath.Join(dir, path)
+
+	start := strings.Index(src, old)
+	require.NotEqual(t, -1, start)
+
+	line := strings.Count(src[:start], "\n") + 1
+	col := start - strings.LastIndex(src[:start], "\n")
+
+	return Change{
+		Rule:    "replacecall",
+		Posn:    path + ":" + strconv.Itoa(line) + ":" + strconv.Itoa(col),
+		Message: old + " => " + new,
+		Edits: []Edit{{
+			Filename: path,
+			Start:    start,
+			End:      start + len(old),
+			New:      new,
+		}},
+	}
+}
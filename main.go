@@ -3,19 +3,33 @@ package main
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"maps"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/cszczepaniak/go-refactor/internal/analyzers/replace"
 	"github.com/cszczepaniak/go-refactor/internal/driver/driver"
+	"github.com/cszczepaniak/go-refactor/internal/exitcode"
+	"github.com/cszczepaniak/go-refactor/internal/leftovers"
+	"github.com/cszczepaniak/go-refactor/internal/movetype"
+	"github.com/cszczepaniak/go-refactor/internal/original"
+	"github.com/cszczepaniak/go-refactor/internal/preflight"
+	"github.com/cszczepaniak/go-refactor/internal/resolve"
+	"github.com/cszczepaniak/go-refactor/internal/typecheck"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/tools/go/packages"
 )
 
 func main() {
+	if isVetTool(os.Args[1:]) {
+		os.Exit(runVetTool(os.Args[1:]))
+	}
+
 	app := &cli.App{
 		Name: "go-refactor",
 		Args: true,
@@ -28,28 +42,163 @@ func main() {
 				Name:    "dry-run",
 				Aliases: []string{"d"},
 			},
+			&cli.BoolFlag{
+				Name:  "leftovers",
+				Usage: "After the run, report references to the target symbol that weren't rewritten",
+			},
+			&cli.BoolFlag{
+				Name:  "fail-on-leftovers",
+				Usage: "Like --leftovers, but exit with an error if there are any",
+			},
+			&cli.StringFlag{
+				Name:  "on-type-error",
+				Value: "abort",
+				Usage: "What to do when the rewritten code doesn't type-check: abort (write nothing), rollback (skip the packages that fail) or ignore",
+			},
+			&cli.StringFlag{
+				Name:  "exit-code",
+				Value: exitcode.ZeroOK.String(),
+				Usage: "When the result of a run is a failure: zero-ok (never), require-changes (when nothing was changed) or check (when anything would change; nothing is written)",
+			},
 		},
 		Commands: []*cli.Command{{
 			Name: "replacecall",
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:     "func",
-					Required: true,
+					Required: false,
+				},
+				&cli.StringFlag{
+					Name:     "at",
+					Required: false,
+					Usage:    "The position of the function in a file, like file.go:12:7 or file.go:#230, instead of --func",
 				},
 				&cli.StringFlag{
 					Name:     "replacement",
+					Required: false,
+				},
+				&cli.StringFlag{
+					Name:     "stmt-replacement",
+					Required: false,
+					Usage:    "A replacement for the whole statement enclosing each call, which may expand to several statements",
+				},
+				&cli.StringFlag{
+					Name:     "value-replacement",
+					Required: false,
+					Usage:    "A replacement for references to the function that aren't calls; if empty, those references are reported",
+				},
+				&cli.StringFlag{
+					Name:     "template",
+					Required: false,
+					Usage:    "A Go file declaring before and after functions to derive --func and --replacement from",
+				},
+				&cli.BoolFlag{
+					Name:  "update-comments",
+					Usage: "Also rewrite doc links to the function in comments, and report other mentions of it",
+				},
+				&cli.BoolFlag{
+					Name:  "delete-original",
+					Usage: "Delete the declaration of the function afterwards, if no references to it remain",
+				},
+			},
+			Action: func(cctx *cli.Context) error {
+				function, err := targetSpec(cctx, "func", resolve.Func)
+				if err != nil {
+					return err
+				}
+
+				hasReplacement := cctx.String("replacement") != "" || cctx.String("stmt-replacement") != ""
+				if cctx.String("template") != "" {
+					if function != "" || hasReplacement {
+						return exitcode.Wrap(exitcode.Usage, errors.New("--template cannot be combined with --func, --at, --replacement or --stmt-replacement"))
+					}
+
+					// Load the template up front so that mistakes in it are reported before we
+					// start loading the packages to refactor.
+					t, err := replace.LoadTemplate(cctx.String("template"))
+					if err != nil {
+						return exitcode.Wrap(exitcode.Usage, err)
+					}
+					function = t.Func
+				} else if function == "" || !hasReplacement {
+					return exitcode.Wrap(exitcode.Usage, errors.New("either --template or both --func (or --at) and --replacement (or --stmt-replacement) are required"))
+				}
+
+				err = replace.ValidateReplacements(
+					cctx.String("replacement"),
+					cctx.String("stmt-replacement"),
+					cctx.String("value-replacement"),
+				)
+				if err != nil {
+					return exitcode.Wrap(exitcode.Usage, err)
+				}
+
+				err = checkSpec("func", function, resolve.Func)
+				if err != nil {
+					return err
+				}
+				err = checkPattern(cctx, function)
+				if err != nil {
+					return err
+				}
+
+				err = runSubcommand(cctx, "replacecall", atFlags(cctx, "func", function), true)
+				if err != nil {
+					return err
+				}
+
+				err = reportLeftovers(cctx, function)
+				if err != nil {
+					return err
+				}
+
+				return deleteOriginal(cctx, function)
+			},
+		}, {
+			Name: "deletecall",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "func",
 					Required: true,
 				},
+				&cli.BoolFlag{
+					Name:  "delete-original",
+					Usage: "Delete the declaration of the function afterwards, if no references to it remain",
+				},
 			},
 			Action: func(cctx *cli.Context) error {
-				return runSubcommand(cctx, "replacecall", nil)
+				err := checkSpec("func", cctx.String("func"), resolve.Func)
+				if err != nil {
+					return err
+				}
+				err = checkPattern(cctx, cctx.String("func"))
+				if err != nil {
+					return err
+				}
+
+				err = runSubcommand(cctx, "deletecall", nil, true)
+				if err != nil {
+					return err
+				}
+
+				err = reportLeftovers(cctx, cctx.String("func"))
+				if err != nil {
+					return err
+				}
+
+				return deleteOriginal(cctx, cctx.String("func"))
 			},
 		}, {
 			Name: "replacetype",
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:     "type",
-					Required: true,
+					Required: false,
+				},
+				&cli.StringFlag{
+					Name:     "at",
+					Required: false,
+					Usage:    "The position of the type in a file, like file.go:12:7 or file.go:#230, instead of --type",
 				},
 				&cli.StringFlag{
 					Name:     "import-alias",
@@ -60,53 +209,304 @@ func main() {
 					Name:     "replacement",
 					Required: true,
 				},
+				&cli.StringFlag{
+					Name:     "shape",
+					Required: false,
+					Usage:    "Change the indirection of the type: ptr-to-value replaces *A with B, value-to-ptr replaces A with *B",
+				},
+				&cli.StringFlag{
+					Name:     "only",
+					Required: false,
+					Usage:    "Only replace references in these comma-separated positions: fields, params, results, vars, conversions",
+				},
+				&cli.StringFlag{
+					Name:     "within",
+					Required: false,
+					Usage:    "Only replace references inside the declaration of this symbol, or inside a package subtree if it ends in /...",
+				},
+				&cli.BoolFlag{
+					Name:  "update-comments",
+					Usage: "Also rewrite doc links to the type in comments, and report other mentions of it",
+				},
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "Replace the type even if the pre-flight check finds code that won't compile afterwards",
+				},
+				&cli.BoolFlag{
+					Name:  "delete-original",
+					Usage: "Delete the declaration of the type and its methods afterwards, if no references to it remain",
+				},
 			},
 			Action: func(cctx *cli.Context) error {
+				typ, err := targetSpec(cctx, "type", resolve.Type)
+				if err != nil {
+					return err
+				}
+				if typ == "" {
+					return exitcode.Wrap(exitcode.Usage, errors.New("either --type or --at is required"))
+				}
+
+				err = checkSpec("type", typ, resolve.Type)
+				if err != nil {
+					return err
+				}
+				err = checkSpec("replacement", cctx.String("replacement"), resolve.Type)
+				if err != nil {
+					return err
+				}
+
 				spec, err := replace.ParseSymbolSpec(cctx.String("replacement"))
 				if err != nil {
 					return err
 				}
 
+				err = checkTypeReplacement(cctx, typ, spec)
+				if err != nil {
+					return err
+				}
+
 				pkgName, err := loadPackageName(spec.Pkg)
 				if err != nil {
 					return err
 				}
 
-				return runSubcommand(cctx, "replacetype", map[string]string{
-					"replacement-package-name": pkgName,
-				})
+				// The conversions are inserted into the replaced code on disk, and checked along with it.
+				flags := atFlags(cctx, "type", typ)
+				flags["replacement-package-name"] = pkgName
+				err = runSubcommand(cctx, "replacetype", flags, false)
+				if err != nil {
+					return err
+				}
+
+				err = insertConversions(cctx, cctx.String("replacement"))
+				if err != nil {
+					return err
+				}
+
+				err = reportLeftovers(cctx, typ)
+				if err != nil {
+					return err
+				}
+
+				return deleteOriginal(cctx, typ)
+			},
+		}, {
+			Name: "movetype",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "type",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "to",
+					Required: true,
+					Usage:    "Where the type is moved to, like example.com/newer.T",
+				},
+				&cli.StringFlag{
+					Name:     "step",
+					Required: true,
+					Usage:    "One of alias, migrate or cleanup; each step checks that the one before it is done",
+				},
 			},
+			Action: moveType,
+		}, {
+			Name:  "check",
+			Usage: "Report code that the rules of a recipe would still change, without changing it",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "rules",
+					Required: true,
+					Usage:    "A YAML recipe of replacecall and replacetype rules",
+				},
+			},
+			Action: checkRules,
 		}},
 		Before: func(c *cli.Context) error {
+			mode, err := exitcode.ParseMode(c.String("exit-code"))
+			if err != nil {
+				return fmt.Errorf("invalid --exit-code: %w", err)
+			}
+
 			d, err := driver.Setup()
 			if err != nil {
-				return err
+				return exitcode.Wrap(exitcode.Failure, err)
 			}
 
 			c.Context = context.WithValue(c.Context, "driver", d)
+			c.Context = context.WithValue(c.Context, "outcome", &outcome{mode: mode})
 			return nil
 		},
 	}
 
+	for _, cmd := range app.Commands {
+		if cmd.Name == "check" {
+			// check changes nothing, and fails whenever it finds something to report.
+			continue
+		}
+		cmd.Action = judgeOutcome(cmd.Action)
+	}
+
 	err := app.Run(os.Args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		// The commands' actions classify their errors, so the others come from parsing the command
+		// line, like a missing flag.
+		os.Exit(exitcode.Code(exitcode.Wrap(exitcode.Usage, err)))
 	}
 }
 
-func runSubcommand(cctx *cli.Context, name string, extraFlags map[string]string) error {
-	d, ok := cctx.Context.Value("driver").(driver.Driver)
+// isVetTool reports whether go-refactor was run by go vet -vettool, which asks for the version and flags
+// of the tool before running it on each package with a .cfg file describing the package.
+func isVetTool(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	return args[0] == "-V=full" || args[0] == "-flags" || strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
+// runVetTool runs the check analyzer as a vet tool and returns the exit code for go vet.
+func runVetTool(args []string) int {
+	d, err := driver.Setup()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	// go vet runs the tool once per package, so don't leave a copy of the driver behind each time.
+	defer d.Cleanup()
+
+	err = checkVetRules(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	stdout, err := vetStdout(args[len(args)-1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if stdout != os.Stdout {
+		defer stdout.Close()
+	}
+
+	code, err := d.VetTool("check", args, stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return code
+}
+
+// checkVetRules checks that the recipe given to go vet with -check.rules is an absolute path. go vet
+// runs the tool in the directory of each package it checks, so a relative path would be looked up in a
+// different place for each one.
+func checkVetRules(args []string) error {
+	for _, arg := range args {
+		// Flags may be given with one dash or two.
+		rules, ok := strings.CutPrefix(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "check.rules=")
+		if !ok {
+			continue
+		}
+		if !filepath.IsAbs(rules) {
+			return fmt.Errorf("-check.rules must be an absolute path under go vet, like $PWD/%s", rules)
+		}
+	}
+	return nil
+}
+
+// vetStdout returns where the output of the vet tool goes. Newer versions of go vet read it from the
+// file named by Stdout in the package's .cfg file rather than from the tool's stdout, which the
+// driver's analysis framework predates.
+func vetStdout(cfg string) (*os.File, error) {
+	if !strings.HasSuffix(cfg, ".cfg") {
+		return os.Stdout, nil
+	}
+
+	data, err := os.ReadFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var vcfg struct {
+		Stdout string
+	}
+	err = json.Unmarshal(data, &vcfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", cfg, err)
+	}
+	if vcfg.Stdout == "" {
+		return os.Stdout, nil
+	}
+	return os.Create(vcfg.Stdout)
+}
+
+// outcome is what the current command changed, for --exit-code to judge.
+type outcome struct {
+	mode    exitcode.Mode
+	changes int
+}
+
+// commandOutcome returns the outcome of the current command.
+func commandOutcome(cctx *cli.Context) *outcome {
+	o, ok := cctx.Context.Value("outcome").(*outcome)
 	if !ok {
-		return errors.New("dev error: driver not found")
+		return &outcome{}
 	}
+	return o
+}
 
-	globalFlagNames := make(map[string]struct{}, len(cctx.App.Flags))
+// judgeOutcome wraps the action of a command so that, once it's done, the number of changes it made is
+// judged according to --exit-code. Errors the action doesn't classify are plain failures.
+func judgeOutcome(action cli.ActionFunc) cli.ActionFunc {
+	return func(cctx *cli.Context) error {
+		err := action(cctx)
+		if err != nil {
+			return exitcode.Wrap(exitcode.Failure, err)
+		}
+
+		o := commandOutcome(cctx)
+		return o.mode.Result(o.changes)
+	}
+}
+
+// dryRun reports whether the current command should only report what it would change. Check mode
+// never writes anything.
+func dryRun(cctx *cli.Context) bool {
+	return cctx.Bool("dry-run") || commandOutcome(cctx).mode == exitcode.Check
+}
+
+func runSubcommand(cctx *cli.Context, name string, extraFlags map[string]string, check bool) error {
+	flags, err := commandFlags(cctx)
+	if err != nil {
+		return err
+	}
+
+	maps.Insert(flags, maps.All(extraFlags))
+	_, err = runAnalyzer(cctx, name, flags, check)
+	if errors.Is(err, driver.ErrNoResults) {
+		// The target was looked up before the run, so it exists and nothing refers to it, like after
+		// an earlier run. That's a run with nothing to do rather than a failure.
+		fmt.Println("0 issues found and fixed")
+		return nil
+	}
+	return err
+}
+
+// cliOnlyFlags are command flags that are handled here rather than by the analyzer.
+var cliOnlyFlags = []string{"force", "delete-original", "at"}
+
+// commandFlags returns the values of the current command's own flags, to be passed along to the
+// analyzer of the same name.
+func commandFlags(cctx *cli.Context) (map[string]string, error) {
+	globalFlagNames := make(map[string]struct{}, len(cctx.App.Flags)+len(cliOnlyFlags))
 	for _, f := range cctx.App.Flags {
 		for _, n := range f.Names() {
 			globalFlagNames[n] = struct{}{}
 		}
 	}
+	for _, n := range cliOnlyFlags {
+		globalFlagNames[n] = struct{}{}
+	}
 
 	flags := make(map[string]string, len(cctx.Command.Flags))
 	for _, f := range cctx.Command.Flags {
@@ -132,46 +532,483 @@ func runSubcommand(cctx *cli.Context, name string, extraFlags map[string]string)
 		case *cli.BoolFlag:
 			flags[f.Name] = strconv.FormatBool(cctx.Bool(f.Name))
 		default:
-			return fmt.Errorf("unsupported flag type: %T", f)
+			return nil, fmt.Errorf("unsupported flag type: %T", f)
+		}
+	}
+
+	return flags, nil
+}
+
+// runAnalyzer runs the analyzer called name over the packages given as arguments to the current
+// command. Its fixes are type-checked before they're written, unless check is false, in which case
+// they're checked along with the fixes of the next analyzer that's run.
+func runAnalyzer(cctx *cli.Context, name string, flags map[string]string, check bool) (*driver.Result, error) {
+	d, ok := cctx.Context.Value("driver").(driver.Driver)
+	if !ok {
+		return nil, errors.New("dev error: driver not found")
+	}
+
+	if dryRun(cctx) {
+		out, err := d.Fixes(name, flags, cctx.Args().Slice())
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Println(out.Output())
+		fmt.Printf("%d issues found and fixed\n", out.Count)
+		commandOutcome(cctx).changes += out.Changes()
+		return out, nil
+	}
+
+	session, err := typecheckSession(cctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := d.Fixes(name, flags, cctx.Args().Slice())
+	switch {
+	case errors.Is(err, driver.ErrNoResults) && check:
+		// There may still be unchecked fixes from before.
+		failures, writeErr := session.Write(nil)
+		reportTypeErrors(failures)
+		if writeErr != nil {
+			return nil, writeErr
 		}
+		return nil, err
+	case err != nil:
+		return nil, errors.Join(err, session.Discard())
 	}
 
-	var do func(string, map[string]string, []string) (*driver.Result, error)
-	if cctx.Bool("dry-run") {
-		do = d.Preview
+	changes := make([]typecheck.Change, 0, len(out.Diagnostics))
+	for _, diag := range out.Diagnostics {
+		c := typecheck.Change{
+			Rule:    name,
+			Posn:    diag.Posn,
+			Message: diag.Message,
+		}
+		for _, e := range diag.Edits {
+			c.Edits = append(c.Edits, typecheck.Edit(e))
+		}
+		changes = append(changes, c)
+	}
+
+	if check {
+		failures, err := session.Write(changes)
+		reportTypeErrors(failures)
+		if err != nil {
+			return nil, err
+		}
 	} else {
-		do = d.Execute
+		err := session.WriteUnchecked(changes)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	maps.Insert(flags, maps.All(extraFlags))
-	out, err := do(
-		name,
-		flags,
-		cctx.Args().Slice(),
-	)
+	commandOutcome(cctx).changes += out.Changes()
+	if cctx.Bool("verbose") {
+		fmt.Println(out.Output())
+		fmt.Printf("%d issues found and fixed\n", out.Count)
+	}
+
+	return out, nil
+}
+
+// typecheckSession returns the session that type-checks and writes the fixes of the current command,
+// starting it on first use.
+func typecheckSession(cctx *cli.Context) (*typecheck.Session, error) {
+	if s, ok := cctx.Context.Value("typecheck").(*typecheck.Session); ok {
+		return s, nil
+	}
+
+	mode, err := typecheck.ParseMode(cctx.String("on-type-error"))
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid --on-type-error: %w", err))
+	}
+	if cctx.Bool("force") && !cctx.IsSet("on-type-error") {
+		// The pre-flight check already said the result won't compile, so unless we were told what to do
+		// about it, write it anyway.
+		mode = typecheck.Ignore
+	}
+
+	s := typecheck.NewSession("", cctx.Args().Slice(), mode)
+	cctx.Context = context.WithValue(cctx.Context, "typecheck", s)
+	return s, nil
+}
+
+// writeChanges type-checks and writes changes that weren't made by an analyzer, like moving a type.
+func writeChanges(cctx *cli.Context, changes ...typecheck.Change) error {
+	session, err := typecheckSession(cctx)
 	if err != nil {
 		return err
 	}
 
-	if cctx.Bool("verbose") || cctx.Bool("dry-run") {
+	failures, err := session.Write(changes)
+	reportTypeErrors(failures)
+	return err
+}
+
+// reportTypeErrors prints the packages that don't type-check after a rewrite.
+func reportTypeErrors(failures []typecheck.Failure) {
+	for _, f := range failures {
+		if f.RolledBack {
+			fmt.Printf("%s doesn't type-check after the changes, so they were left out:\n", f.Pkg)
+		} else {
+			fmt.Printf("%s doesn't type-check after the changes:\n", f.Pkg)
+		}
+		for _, e := range f.Errors {
+			fmt.Println("\t" + strings.ReplaceAll(e.String(), "\n", "\n\t"))
+		}
+	}
+}
+
+// insertConversions runs a second pass after a type replacement to add conversions where values now
+// flow between the replacement type and types that aren't identical to it. The replacement has to be
+// written before the pass can see it, so there's nothing to do for dry runs.
+func insertConversions(cctx *cli.Context, replacement string) error {
+	if dryRun(cctx) {
+		return nil
+	}
+
+	out, err := runAnalyzer(cctx, "insertconv", map[string]string{
+		"type": replacement,
+	}, true)
+	if errors.Is(err, driver.ErrNoResults) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error inserting conversions: %w", err)
+	}
+
+	if !cctx.Bool("verbose") {
+		// Conversions change the code in ways that deserve a look, and the places where no conversion
+		// exists still need fixing by hand, so show them even when not asked to.
 		fmt.Println(out.Output())
-		fmt.Printf("%d issues found and fixed\n", out.Count)
 	}
 
 	return nil
 }
 
+var errPreflight = exitcode.Wrap(exitcode.TypeCheck, errors.New("the replacement would break the build; use --force to replace anyway"))
+
+// checkTypeReplacement reports the places that won't compile once the type is replaced with
+// replacement, and fails if there are any, unless --force was given.
+func checkTypeReplacement(cctx *cli.Context, typ string, replacement replace.SymbolSpec) error {
+	from, err := replace.ParseSymbolSpec(typ)
+	if err != nil {
+		return err
+	}
+
+	shape, err := replace.ParsePointerShape(cctx.String("shape"))
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, err)
+	}
+
+	scope, err := replace.ParseTypeScope(cctx.String("only"), cctx.String("within"))
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, err)
+	}
+
+	problems, err := preflight.Check("", cctx.Args().Slice(), from, replacement, shape, scope)
+	if err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("%d problems found before replacing\n", len(problems))
+
+	if cctx.Bool("force") {
+		return nil
+	}
+	return errPreflight
+}
+
+var errNotAliased = exitcode.Wrap(exitcode.Usage, errors.New("the type isn't an alias yet; run the alias step first"))
+
+// moveType runs one step of moving a type to another package: alias moves the definition and leaves an
+// alias behind, migrate replaces the references to the alias, and cleanup deletes the alias.
+func moveType(cctx *cli.Context) error {
+	err := checkSpec("type", cctx.String("type"), resolve.Type)
+	if err != nil {
+		return err
+	}
+
+	from, err := replace.ParseSymbolSpec(cctx.String("type"))
+	if err != nil {
+		return err
+	}
+	to, err := replace.ParseSymbolSpec(cctx.String("to"))
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, fmt.Errorf("--to: %w", err))
+	}
+
+	step := cctx.String("step")
+	switch step {
+	case "alias":
+		change, err := movetype.Alias("", from, to)
+		if err != nil {
+			return err
+		}
+
+		commandOutcome(cctx).changes++
+		if dryRun(cctx) {
+			fmt.Printf("would move %s to %s, leaving an alias behind\n", cctx.String("type"), cctx.String("to"))
+			return nil
+		}
+		return writeChanges(cctx, change)
+	case "migrate", "cleanup":
+	default:
+		return exitcode.Wrap(exitcode.Usage, fmt.Errorf("unknown step %q; expected one of alias, migrate or cleanup", step))
+	}
+
+	// The alias step creates the destination, so it only has to exist from then on.
+	err = checkSpec("to", cctx.String("to"), resolve.Type)
+	if err != nil {
+		return err
+	}
+
+	aliased, err := movetype.IsAlias("", from, to)
+	if err != nil {
+		return err
+	}
+	if !aliased {
+		return errNotAliased
+	}
+
+	if step == "migrate" {
+		pkgName, err := loadPackageName(to.Pkg)
+		if err != nil {
+			return err
+		}
+
+		_, err = runAnalyzer(cctx, "replacetype", map[string]string{
+			"type":                     cctx.String("type"),
+			"replacement":              cctx.String("to"),
+			"replacement-package-name": pkgName,
+		}, true)
+		if err != nil && !errors.Is(err, driver.ErrNoResults) {
+			return err
+		}
+
+		return reportLeftovers(cctx, cctx.String("type"))
+	}
+
+	found, err := leftovers.Find("", cctx.Args().Slice(), from)
+	if err != nil {
+		return err
+	}
+	if len(found) > 0 {
+		for _, l := range found {
+			fmt.Println(l)
+		}
+		return exitcode.Wrap(exitcode.Usage, fmt.Errorf("%d references to %s remain; run the migrate step first", len(found), cctx.String("type")))
+	}
+
+	change, err := movetype.DeleteAlias("", from, to)
+	if err != nil {
+		return err
+	}
+
+	commandOutcome(cctx).changes++
+	if dryRun(cctx) {
+		fmt.Printf("would delete the alias %s\n", cctx.String("type"))
+		return nil
+	}
+	return writeChanges(cctx, change)
+}
+
+// checkRules reports what the rules of the recipe given by --rules would change, and fails if there's
+// anything.
+func checkRules(cctx *cli.Context) error {
+	d, ok := cctx.Context.Value("driver").(driver.Driver)
+	if !ok {
+		return errors.New("dev error: driver not found")
+	}
+
+	// Load the recipe up front so that mistakes in it are reported before we start loading the
+	// packages to check.
+	rules := cctx.String("rules")
+	_, err := replace.LoadRecipe(rules)
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, err)
+	}
+
+	out, err := d.Fixes("check", map[string]string{"rules": rules}, cctx.Args().Slice())
+	if errors.Is(err, driver.ErrNoResults) {
+		fmt.Println("0 issues found")
+		return nil
+	}
+	if err != nil {
+		return exitcode.Wrap(exitcode.Failure, err)
+	}
+
+	fmt.Print(out.Output())
+	return exitcode.Wrap(exitcode.Outcome, fmt.Errorf("%d issues found", out.Issues()))
+}
+
+// targetSpec returns the spec of the symbol the current command works on: the value of the flag called
+// name, or the spec of the symbol at the position given with --at, which has to be of the given kind.
+func targetSpec(cctx *cli.Context, name string, kind resolve.Kind) (string, error) {
+	at := cctx.String("at")
+	if at == "" {
+		return cctx.String(name), nil
+	}
+	if cctx.String(name) != "" {
+		return "", exitcode.Wrap(exitcode.Usage, fmt.Errorf("--at cannot be combined with --%s", name))
+	}
+
+	spec, got, err := resolve.At("", at)
+	if err != nil {
+		return "", exitcode.Wrap(exitcode.Usage, fmt.Errorf("--at: %w", err))
+	}
+	if got != kind {
+		return "", exitcode.Wrap(exitcode.Usage, fmt.Errorf("--at: %s is a %s, not a %s", spec, got, kind))
+	}
+
+	if cctx.Bool("verbose") {
+		fmt.Printf("%s is %s\n", at, spec)
+	}
+	return spec.String(), nil
+}
+
+// atFlags returns the flag called name set to spec for the analyzer, when spec was found with --at
+// rather than given with that flag.
+func atFlags(cctx *cli.Context, name, spec string) map[string]string {
+	if cctx.String("at") == "" {
+		return map[string]string{}
+	}
+	return map[string]string{name: spec}
+}
+
+// checkSpec checks that the spec given to the flag called name parses and describes a symbol of the
+// given kind, so that a typo is reported before the run rather than matching nothing.
+func checkSpec(name, value string, kind resolve.Kind) error {
+	spec, err := replace.ParseSymbolSpec(value)
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, fmt.Errorf("--%s: %w", name, err))
+	}
+
+	err = resolve.Check("", spec, kind)
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, fmt.Errorf("--%s: %w", name, err))
+	}
+	return nil
+}
+
+// checkPattern checks that the flags of the current command can be used with spec, which may be a
+// pattern for a family of functions.
+func checkPattern(cctx *cli.Context, spec string) error {
+	parsed, err := replace.ParseSymbolSpec(spec)
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, err)
+	}
+	if !parsed.IsPattern() {
+		return nil
+	}
+
+	for _, name := range []string{"delete-original", "update-comments"} {
+		if cctx.Bool(name) {
+			return exitcode.Wrap(exitcode.Usage, fmt.Errorf("--%s cannot be used with a pattern like %s", name, spec))
+		}
+	}
+	return nil
+}
+
+var errLeftovers = exitcode.Wrap(exitcode.Outcome, errors.New("references to the target symbol remain"))
+
+// reportLeftovers prints the references to target that remain after a run, if requested.
+func reportLeftovers(cctx *cli.Context, target string) error {
+	if !cctx.Bool("leftovers") && !cctx.Bool("fail-on-leftovers") {
+		return nil
+	}
+
+	if dryRun(cctx) {
+		// Nothing was rewritten, so everything would be reported.
+		fmt.Println("leftovers are not reported for dry runs")
+		return nil
+	}
+
+	spec, err := replace.ParseSymbolSpec(target)
+	if err != nil {
+		return err
+	}
+
+	found, err := leftovers.Find("", cctx.Args().Slice(), spec)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range found {
+		fmt.Println(l)
+	}
+	fmt.Printf("%d leftover references found\n", len(found))
+
+	if cctx.Bool("fail-on-leftovers") && len(found) > 0 {
+		return errLeftovers
+	}
+
+	return nil
+}
+
+var errOriginalInUse = exitcode.Wrap(exitcode.Outcome, errors.New("references to the target symbol remain, so it wasn't deleted"))
+
+// deleteOriginal deletes the declaration of target once nothing refers to it any more, if requested.
+func deleteOriginal(cctx *cli.Context, target string) error {
+	if !cctx.Bool("delete-original") {
+		return nil
+	}
+
+	if dryRun(cctx) {
+		// Nothing was rewritten, so the references would all still be there.
+		fmt.Println("the original declaration is not deleted for dry runs")
+		return nil
+	}
+
+	spec, err := replace.ParseSymbolSpec(target)
+	if err != nil {
+		return err
+	}
+
+	change, found, err := original.Delete("", cctx.Args().Slice(), spec)
+	if err != nil {
+		return err
+	}
+
+	if len(found) > 0 {
+		for _, l := range found {
+			fmt.Println(l)
+		}
+		return errOriginalInUse
+	}
+
+	err = writeChanges(cctx, change)
+	if err != nil {
+		return err
+	}
+
+	if cctx.Bool("verbose") {
+		fmt.Printf("deleted %s\n", target)
+	}
+	return nil
+}
+
 func loadPackageName(path string) (string, error) {
 	pkgs, err := packages.Load(&packages.Config{
 		Mode: packages.NeedName,
 	}, path)
 	if err != nil {
 		fmt.Println("error loading", path, err)
-		return "", err
+		return "", exitcode.Wrap(exitcode.Load, err)
 	}
 
 	if len(pkgs) != 1 {
-		return "", errors.New("loaded an unexpected number of packages")
+		return "", exitcode.Wrap(exitcode.Load, errors.New("loaded an unexpected number of packages"))
 	}
 
 	pkg := pkgs[0]
@@ -182,7 +1019,7 @@ func loadPackageName(path string) (string, error) {
 			errs = append(errs, e)
 		}
 
-		return "", fmt.Errorf("error(s) loading packages: %w", errors.Join(errs...))
+		return "", exitcode.Wrap(exitcode.Load, fmt.Errorf("error(s) loading packages: %w", errors.Join(errs...)))
 	}
 
 	return pkgs[0].Name, nil
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cszczepaniak/go-refactor/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsVetTool(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{args: nil},
+		{args: []string{"-V=full"}, expected: true},
+		{args: []string{"-flags"}, expected: true},
+		{args: []string{"-check.rules=/recipe.yaml", "/tmp/vet.cfg"}, expected: true},
+		{args: []string{"replacecall", "--func", "example.com/old.F", "./..."}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, isVetTool(tc.args), "%q", tc.args)
+	}
+}
+
+func TestCheckVetRules(t *testing.T) {
+	assert.NoError(t, checkVetRules([]string{"-check.rules=/src/recipe.yaml", "vet.cfg"}))
+	assert.NoError(t, checkVetRules([]string{"--check.rules=/src/recipe.yaml", "vet.cfg"}))
+	assert.NoError(t, checkVetRules([]string{"vet.cfg"}))
+
+	err := checkVetRules([]string{"-check.rules=recipe.yaml", "vet.cfg"})
+	assert.EqualError(t, err, "-check.rules must be an absolute path under go vet, like $PWD/recipe.yaml")
+}
+
+func TestVetStdout(t *testing.T) {
+	dir := t.TempDir()
+
+	f, err := vetStdout("-flags")
+	require.NoError(t, err)
+	assert.Equal(t, os.Stdout, f)
+
+	cfg := filepath.Join(dir, "vet.cfg")
+	require.NoError(t, os.WriteFile(cfg, []byte(`{"Stdout": "`+filepath.Join(dir, "vet.out")+`"}`), 0o644))
+
+	f, err = vetStdout(cfg)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, filepath.Join(dir, "vet.out"), f.Name())
+}
+
+// TestVetTool runs go-refactor under go vet on a module that only imports its own packages, since the
+// driver can't always analyze the standard library of the go vet running the test.
+func TestVetTool(t *testing.T) {
+	bin := build(t)
+	dir := filepath.Join(testutil.Testdata(t), "vettool")
+
+	vet := func(rules string) (string, error) {
+		cmd := exec.Command("go", "vet", "-vettool="+bin, "-check.rules="+rules, "./...")
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	out, err := vet(filepath.Join(dir, "recipe.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, out, "use/use.go:8:9: old-constructor: use newer.New instead (old.NewClient(addr) => newer.New(addr))")
+
+	out, err = vet("recipe.yaml")
+	require.Error(t, err)
+	assert.Contains(t, out, "-check.rules must be an absolute path under go vet")
+	assert.NotContains(t, out, "old-constructor")
+}
+
+func TestCheckCommand(t *testing.T) {
+	bin := build(t)
+
+	cmd := exec.Command(bin, "check", "--rules", "recipe.yaml", "./...")
+	cmd.Dir = filepath.Join(testutil.Testdata(t), "vettool")
+	out, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 6, exitErr.ExitCode())
+
+	// The count is of the diagnostics printed, not of the imports their fixes need.
+	assert.Equal(t, strings.Join([]string{
+		filepath.Join(cmd.Dir, "use", "use.go") + ":8:9: old-constructor: use newer.New instead (old.NewClient(addr) => newer.New(addr))",
+		"error: 1 issues found",
+		"",
+	}, "\n"), string(out))
+}
+
+// build builds go-refactor for tests that run it like a user would.
+func build(t *testing.T) string {
+	t.Helper()
+
+	bin := filepath.Join(t.TempDir(), "go-refactor")
+	out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput()
+	require.NoError(t, err, string(out))
+	return bin
+}
@@ -0,0 +1,3 @@
+module vet.com/m
+
+go 1.23
@@ -0,0 +1,5 @@
+package newer
+
+type Client struct{}
+
+func New(addr string) *Client { return &Client{} }
@@ -0,0 +1,9 @@
+package use
+
+import (
+	"vet.com/m/old"
+)
+
+func Dial(addr string) *old.Client {
+	return old.NewClient(addr)
+}